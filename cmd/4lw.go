@@ -3,18 +3,32 @@ package cmd
 import (
 	"fmt"
 	"io"
-	"net"
-	"time"
+	"sort"
+	"strings"
+	"text/tabwriter"
 
+	"github.com/benzimu/zkcmd/common/flw"
 	"github.com/spf13/cobra"
 )
 
+var flwRaw bool
+
+// flwParsed are the four letter word commands parsed and compared across servers
+var flwParsed = map[string]bool{
+	"mntr": true, "srvr": true, "stat": true, "conf": true, "cons": true, "envi": true, "wchs": true,
+}
+
 func newCmd4lw() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "4lw [flags] 4lwcmd",
 		Short: `Zookeeper the four letter word commands, 4lwcmd like: stat, ruok, conf, isro`,
-		Example: `  zkcmd 4lw stat
+		Long: `Zookeeper the four letter word commands, the servers are queried concurrently.
+  The responses of mntr, srvr, stat, conf, cons, envi and wchs are parsed and compared
+  side by side, one row per server, use --raw for the raw responses.`,
+		Example: `  zkcmd 4lw srvr
+	  zkcmd 4lw mntr -o json
 	  zkcmd 4lw conf
+	  zkcmd 4lw stat --raw
 
 	  For more the four letter word commands, see:
 		https://zookeeper.apache.org/doc/current/zookeeperAdmin.html#sc_4lw`,
@@ -25,39 +39,298 @@ func newCmd4lw() *cobra.Command {
 		Run:  cmdRun4lw,
 	}
 
+	cmd.Flags().BoolVarP(&flwRaw, "raw", "", false, "print the raw responses of the servers")
+
 	return cmd
 }
 
 func cmdRun4lw(cmd *cobra.Command, args []string) {
 	fourlwcmd := args[0]
 
-	for _, srvAddr := range zkcmdConf.Server {
-		fmt.Printf("############### Server: %s ###############\n", srvAddr)
+	doc := &flwDoc{
+		Command: fourlwcmd,
+		Servers: make([]*flwServer, 0),
+		raw:     flwRaw || !flwParsed[fourlwcmd],
+	}
+	for _, res := range flw.ExecAll(zkcmdConf.Server, fourlwcmd, flw.DefaultTimeout) {
+		doc.Servers = append(doc.Servers, newFlwServer(fourlwcmd, res, doc.raw))
+	}
+
+	printOutput(doc)
+}
+
+// flwServer is the parsed response of a server, only the field of the command is set
+type flwServer struct {
+	Server   string            `json:"server" yaml:"server"`
+	Error    string            `json:"error,omitempty" yaml:"error,omitempty"`
+	Response string            `json:"response,omitempty" yaml:"response,omitempty"`
+	Mntr     *flw.Mntr         `json:"mntr,omitempty" yaml:"mntr,omitempty"`
+	Srvr     *flw.Srvr         `json:"srvr,omitempty" yaml:"srvr,omitempty"`
+	Stat     *flw.Stat         `json:"stat,omitempty" yaml:"stat,omitempty"`
+	Cons     []*flw.Connection `json:"cons,omitempty" yaml:"cons,omitempty"`
+	Conf     flw.Properties    `json:"conf,omitempty" yaml:"conf,omitempty"`
+	Envi     flw.Properties    `json:"envi,omitempty" yaml:"envi,omitempty"`
+	Wchs     *flw.Wchs         `json:"wchs,omitempty" yaml:"wchs,omitempty"`
+}
+
+func newFlwServer(command string, res *flw.Response, raw bool) *flwServer {
+	s := &flwServer{Server: res.Server}
+	if res.Err != nil {
+		s.Error = res.Err.Error()
+		return s
+	}
+
+	if raw {
+		s.Response = res.Output
+		return s
+	}
+
+	var err error
+	switch command {
+	case "mntr":
+		s.Mntr, err = flw.ParseMntr(res.Output)
+	case "srvr":
+		s.Srvr, err = flw.ParseSrvr(res.Output)
+	case "stat":
+		s.Stat, err = flw.ParseStat(res.Output)
+	case "cons":
+		s.Cons, err = flw.ParseCons(res.Output)
+	case "conf":
+		s.Conf, err = flw.ParseProperties(res.Output)
+	case "envi":
+		s.Envi, err = flw.ParseProperties(res.Output)
+	case "wchs":
+		s.Wchs, err = flw.ParseWchs(res.Output)
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
 
-		conn, err := net.DialTimeout("tcp", srvAddr, 3*time.Second)
-		if err != nil {
-			fmt.Println(err)
+	return s
+}
+
+// srvr return the srvr of srvr or stat
+func (s *flwServer) srvr() *flw.Srvr {
+	if s.Stat != nil {
+		return &s.Stat.Srvr
+	}
+
+	return s.Srvr
+}
+
+// flwDoc is the output of 4lw, the responses are not parsed if raw
+type flwDoc struct {
+	Command string       `json:"command" yaml:"command"`
+	Servers []*flwServer `json:"servers" yaml:"servers"`
+
+	raw bool
+}
+
+// printRaw print the raw responses behind the server banners
+func (d *flwDoc) printRaw(w io.Writer) {
+	for _, s := range d.Servers {
+		fmt.Fprintf(w, "############### Server: %s ###############\n", s.Server)
+		if s.Error != "" {
+			fmt.Fprintln(w, s.Error)
 			continue
 		}
 
-		err = conn.SetDeadline(time.Now().Add(8 * time.Second))
-		if err != nil {
-			fmt.Println(err)
-			continue
+		fmt.Fprintln(w, s.Response)
+	}
+}
+
+func (d *flwDoc) printTable(w io.Writer) {
+	if d.raw {
+		d.printRaw(w)
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 6, 4, 3, ' ', 0)
+
+	switch d.Command {
+	case "mntr":
+		d.printMntrTable(tw)
+	case "srvr", "stat":
+		d.printSrvrTable(tw)
+	case "cons":
+		d.printConsTable(tw)
+	case "conf", "envi":
+		d.printPropertiesTable(tw)
+	case "wchs":
+		d.printWchsTable(tw)
+	}
+
+	tw.Flush()
+
+	for _, s := range d.Servers {
+		if s.Error != "" {
+			fmt.Fprintf(w, "Error: %s: %s\n", s.Server, s.Error)
 		}
+	}
+}
 
-		_, err = conn.Write([]byte(fourlwcmd))
-		if err != nil {
-			fmt.Println(err)
+func (d *flwDoc) printMntrTable(w io.Writer) {
+	fmt.Fprintln(w, "Server\tState\tLatency(min/avg/max)\tOutstanding\tConnections\tZnodes\tWatches\tEphemerals\tFollowers(synced/total)\t")
+	for _, s := range d.Servers {
+		m := s.Mntr
+		if m == nil {
 			continue
 		}
 
-		resData, err := io.ReadAll(conn)
-		if err != nil {
-			fmt.Println(err)
+		followers := "-"
+		if m.ServerState == "leader" {
+			followers = fmt.Sprintf("%d/%d", m.SyncedFollowers, m.Followers)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%s\t\n",
+			s.Server, m.ServerState, formatLatency(m.MinLatency, m.AvgLatency, m.MaxLatency),
+			m.OutstandingRequests, m.NumAliveConnections, m.ZnodeCount, m.WatchCount, m.EphemeralsCount, followers)
+	}
+}
+
+// printSrvrTable print a row per server, the lag is the number of
+// transactions behind the latest zxid of the servers
+func (d *flwDoc) printSrvrTable(w io.Writer) {
+	var latest int64
+	for _, s := range d.Servers {
+		if srvr := s.srvr(); srvr != nil && srvr.Zxid > latest {
+			latest = srvr.Zxid
+		}
+	}
+
+	fmt.Fprintln(w, "Server\tMode\tZxid\tLag\tLatency(min/avg/max)\tOutstanding\tConnections\tNodes\t")
+	for _, s := range d.Servers {
+		srvr := s.srvr()
+		if srvr == nil {
 			continue
 		}
 
-		fmt.Println(string(resData))
+		lag := "old epoch"
+		if srvr.Epoch() == latest>>32 {
+			lag = fmt.Sprint(latest - srvr.Zxid)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t0x%x\t%s\t%s\t%d\t%d\t%d\t\n",
+			s.Server, srvr.Mode, srvr.Zxid, lag, formatLatency(srvr.MinLatency, srvr.AvgLatency, srvr.MaxLatency),
+			srvr.Outstanding, srvr.Connections, srvr.NodeCount)
+	}
+}
+
+func (d *flwDoc) printConsTable(w io.Writer) {
+	fmt.Fprintln(w, "Server\tClient\tSessionID\tQueued\tReceived\tSent\tLastOp\tLatency(min/avg/max)\t")
+	for _, s := range d.Servers {
+		for _, c := range s.Cons {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%s\t%d/%d/%d\t\n",
+				s.Server, c.Address, orDash(c.SessionID), c.Queued, c.Received, c.Sent, orDash(c.LastOp),
+				c.MinLatency, c.AvgLatency, c.MaxLatency)
+		}
+	}
+}
+
+// printPropertiesTable print a row per key and a column per server, the keys
+// with different values are marked by "*"
+func (d *flwDoc) printPropertiesTable(w io.Writer) {
+	keys := make(map[string]bool)
+	for _, s := range d.Servers {
+		for k := range s.properties() {
+			keys[k] = true
+		}
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	header := []string{" ", "Key"}
+	for _, s := range d.Servers {
+		if s.Error == "" {
+			header = append(header, s.Server)
+		}
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t")+"\t")
+
+	for _, k := range sorted {
+		row := []string{" ", k}
+		for _, s := range d.Servers {
+			if s.Error != "" {
+				continue
+			}
+
+			v, ok := s.properties()[k]
+			if !ok {
+				v = "-"
+			}
+			if len(row) > 2 && v != row[2] {
+				row[0] = "*"
+			}
+			row = append(row, v)
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t")+"\t")
 	}
 }
+
+func (s *flwServer) properties() flw.Properties {
+	if s.Conf != nil {
+		return s.Conf
+	}
+
+	return s.Envi
+}
+
+func (d *flwDoc) printWchsTable(w io.Writer) {
+	fmt.Fprintln(w, "Server\tConnections\tPaths\tWatches\t")
+	for _, s := range d.Servers {
+		if s.Wchs != nil {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t\n", s.Server, s.Wchs.Connections, s.Wchs.Paths, s.Wchs.Watches)
+		}
+	}
+}
+
+func (d *flwDoc) printPlain(w io.Writer) {
+	if d.raw {
+		d.printRaw(w)
+		return
+	}
+
+	for _, s := range d.Servers {
+		switch {
+		case s.Error != "":
+			fmt.Fprintf(w, "%s\terror\t%s\n", s.Server, s.Error)
+		case s.Mntr != nil:
+			fmt.Fprintf(w, "%s\t%s\n", s.Server, s.Mntr.ServerState)
+		case s.srvr() != nil:
+			fmt.Fprintf(w, "%s\t%s\t0x%x\n", s.Server, s.srvr().Mode, s.srvr().Zxid)
+		case s.Cons != nil:
+			for _, c := range s.Cons {
+				fmt.Fprintf(w, "%s\t%s\n", s.Server, c.Address)
+			}
+		case s.Wchs != nil:
+			fmt.Fprintf(w, "%s\t%d\n", s.Server, s.Wchs.Watches)
+		default:
+			props := s.properties()
+			keys := make([]string, 0, len(props))
+			for k := range props {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			for _, k := range keys {
+				fmt.Fprintf(w, "%s\t%s=%s\n", s.Server, k, props[k])
+			}
+		}
+	}
+}
+
+func formatLatency(min, avg, max float64) string {
+	return fmt.Sprintf("%g/%g/%g", min, avg, max)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
@@ -1,26 +1,36 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/benzimu/zkcmd/common/zookeeper"
 	"github.com/go-zookeeper/zk"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
+var (
+	aclRecursive bool
+	aclMode      string
+	digestPerms  string
+)
+
 func newCmdACL() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "acl",
-		Short: "Znode ACL command",
-		PersistentPreRun: func(cmd *cobra.Command, args []string) {
-			zkcli = newZKClient()
-		},
+		Use:              "acl",
+		Short:            "Znode ACL command",
+		PersistentPreRun: connectZK,
 	}
 
 	cmd.AddCommand(newCmdACLGet())
 	cmd.AddCommand(newCmdACLSet())
+	cmd.AddCommand(newCmdACLAudit())
+	cmd.AddCommand(newCmdACLDigest())
 
 	return cmd
 }
@@ -29,11 +39,17 @@ func newCmdACLGet() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "get [flags] path",
 		Short: "Get znode acl",
-		Args:  cobra.ExactArgs(1),
-		Run:   cmdRunACLGet,
+		Long: `Get znode acl, with -R the ACL of the path and its descendants are grouped by the identical
+  ACL sets.`,
+		Example: `  zkcmd acl get /test
+  zkcmd acl get -R /app --max-depth 2`,
+		Args: cobra.ExactArgs(1),
+		Run:  cmdRunACLGet,
 	}
 
 	cmd.Flags().BoolVarP(&isStat, "stat", "s", false, "znode stat info")
+	cmd.Flags().BoolVarP(&aclRecursive, "recursive", "R", false, "get the ACL of the path and its descendants")
+	addWalkFlags(cmd)
 
 	return cmd
 }
@@ -42,46 +58,322 @@ func newCmdACLSet() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "set [flags] path acl",
 		Short: "Set znode acl",
-		Args:  cobra.ExactArgs(2),
-		Run:   cmdRunACLSet,
+		Long: `Set znode acl, the acl is scheme:id:perms, multiple ACL with a comma.
+  The schemes are world, auth, digest, ip, x509 and sasl, the perms are the letters of crdwa.
+  The id of x509 is the distinguished name of the client certificate, it may contain commas,
+  the id of sasl is the SASL user. digest-plain:user:password:perms is hashed to the digest ACL,
+  and auth::perms is expanded to the digest ACL of the digest credentials client side.
+
+  --mode replace sets the ACL, add adds the entries or the perms of the existing entries with
+  the same scheme and id, and remove removes the perms from the entries, the entries without
+  perms are removed. With -R the ACL of the path and its descendants are set with their ACL
+  versions, the zookeeper internal znodes are skipped if the path is /.`,
+		Example: `  zkcmd acl set /test world:anyone:cdrwa
+  zkcmd acl set /test digest:user:smGaoVKd/cQkjm7b88GyorAUz20=:cdrwa,ip:10.0.0.0/8:r
+  zkcmd acl set /test "x509:CN=client,OU=zk,O=example:cdrwa"
+  zkcmd acl set /test sasl:admin:cdrwa
+  zkcmd acl set /test digest-plain:user:password:cdrwa,world:anyone:r
+  zkcmd acl set -R /app --mode add ip:10.0.0.0/8:r --dry-run
+  zkcmd acl set -R /app --mode remove world:anyone:w`,
+		Args: cobra.ExactArgs(2),
+		Run:  cmdRunACLSet,
 	}
 
 	cmd.Flags().BoolVarP(&isStat, "stat", "s", false, "znode stat info")
-	cmd.Flags().StringVarP(&dataVersion, "version", "v", "", "znode data version")
+	cmd.Flags().StringVarP(&dataVersion, "version", "v", "", "znode ACL version")
+	cmd.Flags().BoolVarP(&aclRecursive, "recursive", "R", false, "set the ACL of the path and its descendants")
+	cmd.Flags().StringVarP(&aclMode, "mode", "", zookeeper.ACLModeReplace, "how to apply the ACL, one of: replace, add, remove")
+	cmd.Flags().BoolVarP(&dryRun, "dry-run", "", false, "list the ACL changes without setting")
+	addWalkFlags(cmd)
 
 	return cmd
 }
 
 func cmdRunACLGet(cmd *cobra.Command, args []string) {
-	acls, stat, err := zkcli.GetACL(args[0])
-	checkError(err)
+	path := znodePath(args[0])
+
+	if aclRecursive {
+		cmdRunACLGetRecursive(path)
+		return
+	}
 
-	w := tabwriter.NewWriter(os.Stdout, 6, 4, 3, '\t', 0)
-	fmt.Fprintf(w, "ChildrenNum:\t%v\t\n", stat.NumChildren)
-	fmt.Fprintf(w, "ACL:        \t%v\t\n", zookeeper.FormatACLs(acls))
-	w.Flush()
+	acls, stat, err := zkcli.GetACL(path)
+	checkError(err)
 
+	doc := newACLDoc(path, acls, stat)
 	if isStat {
-		outputStat(stat)
+		doc.Stat = newZnodeStat(stat)
 	}
+
+	printOutput(doc)
+}
+
+func cmdRunACLGetRecursive(path string) {
+	opts := walkOptions()
+	opts.ACL = true
+
+	doc := &aclGroupsDoc{Path: path, Groups: make([]*aclGroup, 0)}
+	groups := make(map[string]*aclGroup)
+
+	err := zkcli.Walk(path, opts, func(n *zookeeper.WalkNode) error {
+		key := zookeeper.ACLKey(n.ACL)
+
+		g, ok := groups[key]
+		if !ok {
+			g = &aclGroup{ACL: newACLEntries(n.ACL), acls: n.ACL}
+			groups[key] = g
+			doc.Groups = append(doc.Groups, g)
+		}
+		g.Znodes = append(g.Znodes, n.Path)
+
+		return nil
+	})
+	checkError(err)
+
+	printOutput(doc)
 }
 
 func cmdRunACLSet(cmd *cobra.Command, args []string) {
-	acls, err := zookeeper.ParseACL(args[1])
+	path := znodePath(args[0])
+
+	acls := parseACLArg(args[1])
+
+	if aclRecursive {
+		if dataVersion != "" {
+			checkError(errors.New("--version can not be used with -R"))
+		}
+
+		changes, err := zkcli.SetACLs(path, walkOptions(), acls, aclMode, dryRun)
+		checkError(err)
+
+		doc := newACLChangesDoc(path, changes, dryRun)
+		printOutput(doc)
+		if doc.Failed > 0 {
+			checkError(errors.Errorf("failed to set the ACL of %d znodes", doc.Failed))
+		}
+		return
+	}
+
+	cur, stat, err := zkcli.GetACL(path)
 	checkError(err)
 
-	exist, stat, err := zkcli.Exists(args[0])
+	acls, err = zookeeper.MergeACL(cur, acls, aclMode)
 	checkError(err)
 
-	if !exist {
-		checkError(zk.ErrNoNode)
+	version := checkDataVersion(stat.Aversion)
+	if dryRun {
+		changes := make([]*zookeeper.ACLChange, 0)
+		if !zookeeper.EqualACL(cur, acls) {
+			changes = append(changes, &zookeeper.ACLChange{Path: path, Aversion: version, Old: cur, New: acls})
+		}
+
+		printOutput(newACLChangesDoc(path, changes, dryRun))
+		return
 	}
 
-	version := checkDataVersion(stat.Aversion)
-	stat, err = zkcli.SetACL(args[0], acls, version)
+	stat, err = zkcli.SetACL(path, acls, version)
 	checkError(err)
 
 	if isStat {
-		outputStat(stat)
+		printOutput(&znodeStatDoc{Path: path, Stat: newZnodeStat(stat)})
 	}
 }
+
+// parseACLArg parse the ACL argument, the auth ACL is expanded to the digest
+// ACL of the digest credentials client side, see zookeeper.Client.ExpandAuthACL
+func parseACLArg(acl string) []zk.ACL {
+	acls, err := zookeeper.ParseACL(acl)
+	checkError(err)
+
+	acls, err = zkcli.ExpandAuthACL(acls)
+	checkError(err)
+
+	return acls
+}
+
+// aclGroupsDoc is the output of acl get -R, the znodes are grouped by the
+// identical ACL sets in the order of the first znode
+type aclGroupsDoc struct {
+	Path   string      `json:"path" yaml:"path"`
+	Groups []*aclGroup `json:"groups" yaml:"groups"`
+}
+
+type aclGroup struct {
+	ACL    []aclEntry `json:"acl" yaml:"acl"`
+	Znodes []string   `json:"znodes" yaml:"znodes"`
+
+	acls []zk.ACL
+}
+
+func (d *aclGroupsDoc) printTable(w io.Writer) {
+	for i, g := range d.Groups {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+
+		fmt.Fprintf(w, "ACL: %s (%d znodes)\n", zookeeper.FormatACLs(g.acls), len(g.Znodes))
+		for _, p := range g.Znodes {
+			fmt.Fprintf(w, "  %s\n", p)
+		}
+	}
+}
+
+func (d *aclGroupsDoc) printPlain(w io.Writer) {
+	for _, g := range d.Groups {
+		for _, p := range g.Znodes {
+			fmt.Fprintf(w, "%s\t%s\n", p, zookeeper.FormatACLs(g.acls))
+		}
+	}
+}
+
+// aclChangesDoc is the output of acl set -R and --dry-run
+type aclChangesDoc struct {
+	Path    string      `json:"path" yaml:"path"`
+	DryRun  bool        `json:"dryRun" yaml:"dryRun"`
+	Changes []aclChange `json:"changes" yaml:"changes"`
+	Changed int         `json:"changed" yaml:"changed"`
+	Failed  int         `json:"failed" yaml:"failed"`
+}
+
+type aclChange struct {
+	Path     string     `json:"path" yaml:"path"`
+	Aversion int32      `json:"aversion" yaml:"aversion"`
+	Old      []aclEntry `json:"old" yaml:"old"`
+	New      []aclEntry `json:"new" yaml:"new"`
+	Error    string     `json:"error,omitempty" yaml:"error,omitempty"`
+
+	old, new []zk.ACL
+}
+
+func newACLChangesDoc(path string, changes []*zookeeper.ACLChange, dryRun bool) *aclChangesDoc {
+	d := &aclChangesDoc{Path: path, DryRun: dryRun, Changes: make([]aclChange, 0, len(changes))}
+	for _, ch := range changes {
+		c := aclChange{
+			Path:     ch.Path,
+			Aversion: ch.Aversion,
+			Old:      newACLEntries(ch.Old),
+			New:      newACLEntries(ch.New),
+			old:      ch.Old,
+			new:      ch.New,
+		}
+
+		if ch.Err != nil {
+			c.Error = ch.Err.Error()
+			d.Failed++
+		} else if !dryRun {
+			d.Changed++
+		}
+
+		d.Changes = append(d.Changes, c)
+	}
+
+	return d
+}
+
+func (d *aclChangesDoc) printTable(w io.Writer) {
+	if len(d.Changes) != 0 {
+		tw := tabwriter.NewWriter(w, 6, 4, 3, ' ', 0)
+		fmt.Fprintf(tw, "Path\tAversion\tOld\tNew\tResult\t\n")
+		for _, c := range d.Changes {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t\n", c.Path, c.Aversion,
+				zookeeper.FormatACLs(c.old), zookeeper.FormatACLs(c.new), d.result(c))
+		}
+		tw.Flush()
+	}
+
+	if d.DryRun {
+		fmt.Fprintf(w, "%d znodes would be changed, %d failed\n", len(d.Changes)-d.Failed, d.Failed)
+	} else {
+		fmt.Fprintf(w, "%d znodes changed, %d failed\n", d.Changed, d.Failed)
+	}
+}
+
+func (d *aclChangesDoc) result(c aclChange) string {
+	switch {
+	case c.Error != "":
+		return c.Error
+	case d.DryRun:
+		return "dry-run"
+	}
+
+	return "changed"
+}
+
+func (d *aclChangesDoc) printPlain(w io.Writer) {
+	for _, c := range d.Changes {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", c.Path, zookeeper.FormatACLs(c.new), d.result(c))
+	}
+}
+
+func newCmdACLDigest() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "digest [flags] user[:password]...",
+		Short: "Generate the digest id of the user and password for the digest ACL",
+		Long: `Generate the digest id user:base64(sha1(user:password)) for the digest ACL, the password is
+  read from stdin if only the user is given, so it is not saved in the shell history. The plain
+  output is the ACL which can be used by acl set and znode create.
+  The ACL can also be written as digest-plain:user:password:perms, it is hashed client side.`,
+		Example: `  zkcmd acl digest app:secret
+  zkcmd acl digest app --perms r < password.txt
+  zkcmd acl set /app "$(zkcmd acl digest app:secret admin:passwd -o plain)"`,
+		Args: cobra.MinimumNArgs(1),
+		Run:  cmdRunACLDigest,
+		// no connection is needed
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	}
+
+	cmd.Flags().StringVarP(&digestPerms, "perms", "p", "cdrwa", "the perms of the ACL")
+
+	return cmd
+}
+
+func cmdRunACLDigest(cmd *cobra.Command, args []string) {
+	perms, err := zookeeper.ParsePerms(digestPerms)
+	checkError(err)
+
+	reader := bufio.NewReader(os.Stdin)
+
+	doc := &digestDoc{Digests: make([]digestEntry, 0, len(args))}
+	for _, a := range args {
+		user, password, ok := strings.Cut(a, ":")
+		if !ok {
+			line, err := reader.ReadString('\n')
+			if err != nil && (err != io.EOF || line == "") {
+				checkError(errors.Wrapf(err, "read the password of %s", user))
+			}
+			password = strings.TrimRight(line, "\r\n")
+		}
+
+		acl := zk.ACL{Perms: perms, Scheme: zookeeper.SchemeDigest, ID: zookeeper.DigestID(user, password)}
+		doc.Digests = append(doc.Digests, digestEntry{User: user, ID: acl.ID, ACL: zookeeper.FormatACLs([]zk.ACL{acl})})
+		doc.acls = append(doc.acls, acl)
+	}
+
+	printOutput(doc)
+}
+
+// digestDoc is the output of acl digest
+type digestDoc struct {
+	Digests []digestEntry `json:"digests" yaml:"digests"`
+
+	acls []zk.ACL
+}
+
+type digestEntry struct {
+	User string `json:"user" yaml:"user"`
+	ID   string `json:"id" yaml:"id"`
+	ACL  string `json:"acl" yaml:"acl"`
+}
+
+func (d *digestDoc) printTable(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 6, 4, 3, ' ', 0)
+	fmt.Fprintf(tw, "User\tDigest ID\tACL\t\n")
+	for _, e := range d.Digests {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t\n", e.User, e.ID, e.ACL)
+	}
+	tw.Flush()
+}
+
+func (d *digestDoc) printPlain(w io.Writer) {
+	fmt.Fprintln(w, zookeeper.FormatACLs(d.acls))
+}
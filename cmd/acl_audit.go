@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/benzimu/zkcmd/common/version"
+	"github.com/benzimu/zkcmd/common/zookeeper"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditKnownUsers   []string
+	auditKnownDigests []string
+	auditSARIF        bool
+)
+
+func newCmdACLAudit() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit [flags] [path]",
+		Short: "Audit the ACL of the znode and its descendants, the path default: / or the shell working directory",
+		Long: `Audit the ACL of the znode and its descendants, the findings are ranked by severity:
+
+  critical  world-all                 world:anyone:cdrwa
+  high      world-writable            world:anyone has the create, delete, write or admin permission
+  high      no-admin                  no one has the admin permission, only a superuser can recover it
+  medium    unknown-digest            the digest id does not match the known users
+  medium    unreadable                not readable with the current credentials
+  low       acl-differs-from-parent   the ACL differs from the parent
+
+  The known users are the digest credentials of the config, --known-user and --known-digest,
+  the digest ids are not checked if there is no known user. --sarif prints the report in SARIF
+  for the code scanning tools.`,
+		Example: `  zkcmd acl audit /app
+  zkcmd acl audit / --exclude /zookeeper --known-user app:secret -o json
+  zkcmd acl audit /app --known-digest 'app:F6Y+U5gIoCbNNoLQExmvxRFeatA=' --sarif > acl.sarif`,
+		Args: cobra.MaximumNArgs(1),
+		Run:  cmdRunACLAudit,
+	}
+
+	cmd.Flags().StringSliceVarP(&auditKnownUsers, "known-user", "", nil, "the known digest users, like user:password")
+	cmd.Flags().StringSliceVarP(&auditKnownDigests, "known-digest", "", nil, "the known digest ids, like user:base64(sha1(user:password))")
+	cmd.Flags().BoolVarP(&auditSARIF, "sarif", "", false, "print the report in SARIF 2.1.0, --output is ignored")
+	addWalkFlags(cmd)
+
+	return cmd
+}
+
+func cmdRunACLAudit(cmd *cobra.Command, args []string) {
+	root := workDir
+	if len(args) > 0 {
+		root = znodePath(args[0])
+	}
+
+	opts := zookeeper.AuditOptions{KnownDigests: auditKnownDigests}
+	for _, u := range append(currentCluster().ACL, auditKnownUsers...) {
+		user, password, ok := strings.Cut(u, ":")
+		if !ok {
+			checkError(errors.Errorf("invalid digest user %s, must be user:password", u))
+		}
+
+		opts.KnownDigests = append(opts.KnownDigests, zookeeper.DigestID(user, password))
+	}
+
+	report, err := zkcli.Audit(root, walkOptions(), opts)
+	checkError(err)
+
+	if auditSARIF {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		checkError(enc.Encode(newSARIFLog(report)))
+		return
+	}
+
+	printOutput(&auditDoc{report})
+}
+
+// auditDoc is the output of acl audit
+type auditDoc struct {
+	*zookeeper.AuditReport `yaml:",inline"`
+}
+
+func (d *auditDoc) printTable(w io.Writer) {
+	if len(d.Findings) != 0 {
+		tw := tabwriter.NewWriter(w, 6, 4, 3, ' ', 0)
+		fmt.Fprintf(tw, "Severity\tRule\tPath\tMessage\t\n")
+		for _, f := range d.Findings {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", strings.ToUpper(f.Severity), f.Rule, f.Path, f.Message)
+		}
+		tw.Flush()
+		fmt.Fprintln(w)
+	}
+
+	counts := make(map[string]int)
+	for _, f := range d.Findings {
+		counts[f.Severity]++
+	}
+
+	fmt.Fprintf(w, "%d znodes audited, %d findings: %d critical, %d high, %d medium, %d low\n", d.Znodes, len(d.Findings),
+		counts[zookeeper.SeverityCritical], counts[zookeeper.SeverityHigh], counts[zookeeper.SeverityMedium], counts[zookeeper.SeverityLow])
+}
+
+func (d *auditDoc) printPlain(w io.Writer) {
+	for _, f := range d.Findings {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", f.Severity, f.Rule, f.Path)
+	}
+}
+
+// sarifLog is the minimal SARIF 2.1.0 log of the audit report, the znode path
+// is the logical location of the result
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string            `json:"id"`
+	ShortDescription     sarifMessage      `json:"shortDescription"`
+	DefaultConfiguration sarifRuleConfig   `json:"defaultConfiguration"`
+	Properties           map[string]string `json:"properties"`
+}
+
+type sarifRuleConfig struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations"`
+	Properties map[string]string `json:"properties"`
+}
+
+type sarifLocation struct {
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// sarifLevel map the severity to the SARIF level
+func sarifLevel(severity string) string {
+	switch severity {
+	case zookeeper.SeverityCritical, zookeeper.SeverityHigh:
+		return "error"
+	case zookeeper.SeverityMedium:
+		return "warning"
+	}
+
+	return "note"
+}
+
+func newSARIFLog(report *zookeeper.AuditReport) *sarifLog {
+	driver := sarifDriver{
+		Name:           "zkcmd",
+		Version:        version.Get().Version,
+		InformationURI: "https://github.com/benzimu/zkcmd",
+		Rules:          make([]sarifRule, 0, len(zookeeper.AuditRules)),
+	}
+	for _, r := range zookeeper.AuditRules {
+		driver.Rules = append(driver.Rules, sarifRule{
+			ID:                   r.ID,
+			ShortDescription:     sarifMessage{r.Description},
+			DefaultConfiguration: sarifRuleConfig{sarifLevel(r.Severity)},
+			Properties:           map[string]string{"severity": r.Severity},
+		})
+	}
+
+	results := make([]sarifResult, 0, len(report.Findings))
+	for _, f := range report.Findings {
+		results = append(results, sarifResult{
+			RuleID:     f.Rule,
+			Level:      sarifLevel(f.Severity),
+			Message:    sarifMessage{f.Path + ": " + f.Message},
+			Locations:  []sarifLocation{{[]sarifLogicalLocation{{FullyQualifiedName: f.Path, Kind: "znode"}}}},
+			Properties: map[string]string{"severity": f.Severity},
+		})
+	}
+
+	return &sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{{Tool: sarifTool{driver}, Results: results}},
+	}
+}
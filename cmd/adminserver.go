@@ -1,15 +1,30 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
 	"strings"
-	"time"
+	"sync"
+	"text/tabwriter"
 
-	"github.com/go-resty/resty/v2"
+	"github.com/benzimu/zkcmd/common/adminserver"
+	"github.com/benzimu/zkcmd/common/flw"
+	"github.com/benzimu/zkcmd/common/zookeeper"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
+var (
+	adminParams []string
+	adminMethod string
+	adminData   string
+)
+
 func newCmdAdminServer() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "adminsrv",
@@ -39,72 +54,316 @@ func newCmdAdminServerExec() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "exec [flags] command",
 		Short: `exec AdminServer command, command like: stats/stat, ruok, configuration/conf/config, is_read_only/isro`,
+		Long: `exec AdminServer command on all the AdminServers concurrently, the JSON responses are flattened
+  to key/value tables, use -o json or yaml for the documents. The error of non-2xx response and
+  the error field of the response are reported.`,
 		Example: `  zkcmd adminsrv exec stat
 	  zkcmd adminsrv exec conf
+	  zkcmd adminsrv exec watches_by_path -p path=/app
+	  zkcmd adminsrv exec snapshot -p streaming=false --adminAuth "digest root:passwd"
+	  zkcmd adminsrv exec monitor --adminServer https://10.0.0.1:8443 --adminCA ca.pem
 
 	  For more commands, see: https://zookeeper.apache.org/doc/current/zookeeperAdmin.html#sc_adminserver`,
 		Args: cobra.ExactArgs(1),
 		Run:  cmdRunAdminServerExec,
 	}
 
+	cmd.Flags().StringArrayVarP(&adminParams, "param", "p", nil, "the query parameter of the command, key=value, it can be repeated")
+	cmd.Flags().StringVarP(&adminMethod, "method", "X", "GET", "the HTTP method of the command, POST for the commands with body like restore")
+	cmd.Flags().StringVarP(&adminData, "data", "d", "", `the body of the command, "@file" to read the file and "@-" to read stdin`)
 	cmdAdminServerCommonFlags(cmd)
 
 	return cmd
 }
 
 func cmdAdminServerCommonFlags(cmd *cobra.Command) {
-	cmd.Flags().StringVarP(&zkcmdConf.AdminCommandURL, "adminCommandURL", "", "", fmt.Sprintf(`The AdminServer URL for listing and issuing commands relative to the root URL. (default "%s")`, defaultAdminCommandURL))
-	cmd.Flags().StringSliceVarP(&zkcmdConf.AdminServer, "adminServer", "", nil, fmt.Sprintf("zookeeper AdminServer address, multiple addresses with a comma. (default [%s])", defaultAdminServer))
-	_ = viper.BindPFlag("adminCommandURL", cmd.Flags().Lookup("adminCommandURL"))
-	_ = viper.BindPFlag("adminServer", cmd.Flags().Lookup("adminServer"))
+	cmd.Flags().StringP("adminCommandURL", "", "", fmt.Sprintf(`The AdminServer URL for listing and issuing commands relative to the root URL. (default "%s")`, defaultAdminCommandURL))
+	cmd.Flags().StringSliceP("adminServer", "", nil, fmt.Sprintf("zookeeper AdminServer address, multiple addresses with a comma, https://host:port for the TLS AdminServer. (default [%s])", defaultAdminServer))
+	cmd.Flags().StringP("adminAuth", "", "", `the Authorization header of the AdminServer auth, like: "digest user:password"`)
+	cmd.Flags().StringP("adminCA", "", "", "TLS CA bundle file to verify the AdminServer certificate. (default system CAs)")
+	cmd.Flags().StringP("adminCert", "", "", "TLS client certificate file of the AdminServer")
+	cmd.Flags().StringP("adminKey", "", "", "TLS client key file of the AdminServer")
+	cmd.Flags().BoolP("adminInsecure", "", false, "skip verifying the AdminServer certificate")
 	viper.SetDefault("adminCommandURL", defaultAdminCommandURL)
 	viper.SetDefault("adminServer", []string{defaultAdminServer})
 }
 
+// applyAdminServerFlags override the config by the AdminServer flags of the
+// command, the flags are shared by several commands so they are not bound to viper
+func applyAdminServerFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	if flags.Changed("adminCommandURL") {
+		zkcmdConf.AdminCommandURL, _ = flags.GetString("adminCommandURL")
+	}
+	if flags.Changed("adminServer") {
+		zkcmdConf.AdminServer, _ = flags.GetStringSlice("adminServer")
+	}
+	if flags.Changed("adminAuth") {
+		zkcmdConf.AdminAuth, _ = flags.GetString("adminAuth")
+	}
+	if flags.Changed("adminCA") {
+		zkcmdConf.AdminTLS.CA, _ = flags.GetString("adminCA")
+	}
+	if flags.Changed("adminCert") {
+		zkcmdConf.AdminTLS.Cert, _ = flags.GetString("adminCert")
+	}
+	if flags.Changed("adminKey") {
+		zkcmdConf.AdminTLS.Key, _ = flags.GetString("adminKey")
+	}
+	if flags.Changed("adminInsecure") {
+		zkcmdConf.AdminTLS.InsecureSkipVerify, _ = flags.GetBool("adminInsecure")
+	}
+}
+
+// newAdminServerClient new the client of the AdminServer by the config
+func newAdminServerClient(server string) *adminserver.Client {
+	opts := adminserver.Options{
+		CommandURL:    zkcmdConf.AdminCommandURL,
+		Authorization: zkcmdConf.AdminAuth,
+	}
+
+	if t := zkcmdConf.AdminTLS; t.enabled() {
+		cfg, err := zookeeper.NewTLSConfig(t.Cert, t.Key, t.CA, t.ServerName, t.InsecureSkipVerify)
+		checkError(errors.Wrap(err, "AdminServer TLS"))
+
+		opts.TLSConfig = cfg
+	}
+
+	return adminserver.New(server, opts)
+}
+
+// eachAdminServer call fn for all the AdminServers concurrently, the results
+// are in the order of the AdminServers
+func eachAdminServer(fn func(c *adminserver.Client) *adminServerResult) []*adminServerResult {
+	res := make([]*adminServerResult, len(zkcmdConf.AdminServer))
+
+	var wg sync.WaitGroup
+	for i, srv := range zkcmdConf.AdminServer {
+		c := newAdminServerClient(srv)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			res[i] = fn(c)
+		}(i)
+	}
+	wg.Wait()
+
+	return res
+}
+
 func cmdRunAdminServerList(cmd *cobra.Command, args []string) {
-	for _, srv := range zkcmdConf.AdminServer {
-		fmt.Printf("############### AdminServer: %s ###############\n", srv)
+	applyAdminServerFlags(cmd)
+
+	results := eachAdminServer(func(c *adminserver.Client) *adminServerResult {
+		r := &adminServerResult{Server: c.Server()}
 
-		srvAddr := fmt.Sprintf("%s%s", srv, zkcmdConf.AdminCommandURL)
-		res, err := doAdminServerReq(srvAddr)
+		commands, err := c.Commands()
 		if err != nil {
-			fmt.Println(err)
-			continue
+			r.Error = err.Error()
 		}
+		r.Commands = commands
 
-		fmt.Println(res)
-	}
+		return r
+	})
+
+	printOutput(&adminServerDoc{Command: "list", Servers: results})
 }
 
 func cmdRunAdminServerExec(cmd *cobra.Command, args []string) {
+	applyAdminServerFlags(cmd)
+
 	command := args[0]
 
-	for _, srv := range zkcmdConf.AdminServer {
-		fmt.Printf("############### AdminServer: %s ###############\n", srv)
+	params := url.Values{}
+	for _, p := range adminParams {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok {
+			checkError(errors.Errorf("invalid param %s, must be key=value", p))
+		}
+
+		params.Add(k, v)
+	}
+
+	var body []byte
+	if adminData != "" {
+		body = []byte(adminData)
+		if strings.HasPrefix(adminData, "@") {
+			body = readInputFile(adminData[1:])
+		}
+	}
+
+	results := eachAdminServer(func(c *adminserver.Client) *adminServerResult {
+		r := &adminServerResult{Server: c.Server()}
 
-		srvAddr := fmt.Sprintf("%s%s/%s", srv, zkcmdConf.AdminCommandURL, command)
-		res, err := doAdminServerReq(srvAddr)
+		res, err := c.Do(strings.ToUpper(adminMethod), command, params, body)
 		if err != nil {
-			fmt.Println(err)
-			continue
+			r.Error = err.Error()
+			return r
 		}
 
-		fmt.Println(res)
+		r.Response, r.raw = res.Fields, res.Raw
+		return r
+	})
+
+	printOutput(&adminServerDoc{Command: command, Servers: results})
+}
+
+type adminServerResult struct {
+	Server   string                 `json:"server" yaml:"server"`
+	Error    string                 `json:"error,omitempty" yaml:"error,omitempty"`
+	Commands []string               `json:"commands,omitempty" yaml:"commands,omitempty"`
+	Response map[string]interface{} `json:"response,omitempty" yaml:"response,omitempty"`
+
+	// raw is the response which is not JSON, like the streamed snapshot
+	raw []byte
+}
+
+// adminServerDoc is the output of adminsrv
+type adminServerDoc struct {
+	Command string               `json:"command" yaml:"command"`
+	Servers []*adminServerResult `json:"servers" yaml:"servers"`
+}
+
+func (d *adminServerDoc) printTable(w io.Writer) {
+	for _, s := range d.Servers {
+		fmt.Fprintf(w, "############### AdminServer: %s ###############\n", s.Server)
+
+		switch {
+		case s.Error != "":
+			fmt.Fprintln(w, s.Error)
+		case s.Commands != nil:
+			for _, c := range s.Commands {
+				fmt.Fprintln(w, c)
+			}
+		case s.raw != nil:
+			_, _ = w.Write(s.raw)
+		default:
+			tw := tabwriter.NewWriter(w, 6, 4, 3, ' ', 0)
+			for _, kv := range flattenJSON("", s.Response) {
+				fmt.Fprintf(tw, "%s\t%s\t\n", kv[0], kv[1])
+			}
+			tw.Flush()
+		}
+	}
+}
+
+func (d *adminServerDoc) printPlain(w io.Writer) {
+	for _, s := range d.Servers {
+		switch {
+		case s.Error != "":
+			fmt.Fprintf(w, "%s\terror\t%s\n", s.Server, s.Error)
+		case s.Commands != nil:
+			for _, c := range s.Commands {
+				fmt.Fprintf(w, "%s\t%s\n", s.Server, c)
+			}
+		case s.raw != nil:
+			_, _ = w.Write(s.raw)
+		default:
+			for _, kv := range flattenJSON("", s.Response) {
+				fmt.Fprintf(w, "%s\t%s=%s\n", s.Server, kv[0], kv[1])
+			}
+		}
+	}
+}
+
+// flattenJSON flatten the JSON value to the sorted key/value pairs, the keys
+// of the nested values are joined by "."
+func flattenJSON(prefix string, v interface{}) [][2]string {
+	join := func(k string) string {
+		if prefix == "" {
+			return k
+		}
+
+		return prefix + "." + k
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		res := make([][2]string, 0, len(val))
+		for _, k := range keys {
+			res = append(res, flattenJSON(join(k), val[k])...)
+		}
+
+		return res
+	case []interface{}:
+		res := make([][2]string, 0, len(val))
+		for i, e := range val {
+			res = append(res, flattenJSON(join(fmt.Sprint(i)), e)...)
+		}
+
+		return res
+	case nil:
+		return [][2]string{{prefix, "null"}}
+	case json.Number, string, bool:
+		return [][2]string{{prefix, fmt.Sprint(val)}}
+	}
+
+	b, _ := json.Marshal(v)
+	return [][2]string{{prefix, string(b)}}
+}
+
+// adminServerOf return the AdminServer of the same index as the server if
+// they are configured in pairs, otherwise the AdminServer of the same host
+func adminServerOf(server string) string {
+	if len(zkcmdConf.AdminServer) == len(zkcmdConf.Server) {
+		for i, s := range zkcmdConf.Server {
+			if s == server {
+				return zkcmdConf.AdminServer[i]
+			}
+		}
+	}
+
+	host := hostOf(server)
+	for _, admin := range zkcmdConf.AdminServer {
+		if hostOf(admin) == host {
+			return admin
+		}
 	}
+
+	return ""
 }
 
-func doAdminServerReq(addr string) (string, error) {
-	if !strings.HasPrefix(addr, "http://") && !strings.HasPrefix(addr, "https://") {
-		addr = "http://" + addr
+// hostOf return the host of the address, the scheme and path are ignored
+func hostOf(addr string) string {
+	if i := strings.Index(addr, "://"); i >= 0 {
+		addr = addr[i+3:]
+	}
+	if i := strings.IndexByte(addr, '/'); i >= 0 {
+		addr = addr[:i]
 	}
 
-	resp, err := resty.New().
-		SetTimeout(5 * time.Second).
-		R().
-		Get(addr)
+	host, _, err := net.SplitHostPort(addr)
 	if err != nil {
-		return "", err
+		return addr
+	}
+
+	return host
+}
+
+// adminServerMonitor get the metrics of the server by the monitor command of its AdminServer
+func adminServerMonitor(server string) (*flw.Mntr, error) {
+	admin := adminServerOf(server)
+	if admin == "" {
+		return nil, errors.Errorf("no AdminServer of %s", server)
+	}
+
+	return newAdminServerClient(admin).Monitor()
+}
+
+// adminServerRuok check the server by the ruok command of its AdminServer
+func adminServerRuok(server string) error {
+	admin := adminServerOf(server)
+	if admin == "" {
+		return errors.Errorf("no AdminServer of %s", server)
 	}
 
-	return resp.String(), nil
+	return newAdminServerClient(admin).Ruok()
 }
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/benzimu/zkcmd/common/codec"
+	"github.com/spf13/cobra"
+)
+
+const decodeAuto = "auto"
+
+var (
+	decodeCodecs    string
+	encodeCodecs    string
+	protoDescriptor string
+	protoType       string
+)
+
+func addDecodeFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&decodeCodecs, "decode", "d", decodeAuto,
+		fmt.Sprintf("decode the data by the codecs in order, multiple codecs with a comma, like: gzip,json. auto or: %s", strings.Join(codec.Names(), ", ")))
+	addProtoFlags(cmd)
+}
+
+func addEncodeFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&encodeCodecs, "encode", "", "",
+		fmt.Sprintf("encode the data by the codecs in reverse order, so it is got by --decode of the same codecs. one or more of: %s", strings.Join(codec.Names(), ", ")))
+	addProtoFlags(cmd)
+}
+
+func addProtoFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&protoDescriptor, "proto-descriptor", "", "", "the FileDescriptorSet file of the protobuf codec, generated by: protoc --include_imports --descriptor_set_out")
+	cmd.Flags().StringVarP(&protoType, "proto-type", "", "", "the full name of the protobuf message type. (default the only message of the descriptor set)")
+}
+
+// parseCodecs parse the codecs, the protobuf codec is registered by the descriptor set flags
+func parseCodecs(spec string) codec.Chain {
+	pb := &codec.Protobuf{}
+	if protoDescriptor != "" {
+		var err error
+		pb, err = codec.NewProtobuf(readInputFile(protoDescriptor), protoType)
+		checkError(err)
+	}
+	codec.Register(pb)
+
+	chain, err := codec.Parse(spec)
+	checkError(err)
+
+	return chain
+}
+
+// decodeData decode the data of the path by --decode, the codecs are detected
+// if auto, a warning is printed if the decoded data is not text: it is not
+// valid UTF-8, or it is detected as binary and shown as hex dump
+func decodeData(path string, data []byte) (codec.Chain, []byte) {
+	var (
+		chain   codec.Chain
+		decoded []byte
+		err     error
+	)
+
+	if decodeCodecs == decodeAuto {
+		chain, decoded = codec.Detect(data)
+	} else {
+		chain = parseCodecs(decodeCodecs)
+		decoded, err = chain.Decode(data)
+		checkError(err)
+	}
+
+	binary := decodeCodecs == decodeAuto && len(chain) > 0 && chain[len(chain)-1].Name() == "hex"
+	if binary || !utf8.Valid(decoded) {
+		fmt.Fprintf(os.Stderr, "Warning: the data of %s is not text, decoded by: %s\n", path, chain)
+	}
+
+	return chain, decoded
+}
+
+// encodeData encode the data by --encode
+func encodeData(data []byte) []byte {
+	if encodeCodecs == "" {
+		return data
+	}
+
+	data, err := parseCodecs(encodeCodecs).Encode(data)
+	checkError(err)
+
+	return data
+}
@@ -3,12 +3,16 @@ package cmd
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
 )
 
@@ -16,25 +20,190 @@ const (
 	defaultServer          = "127.0.0.1:2181"
 	defaultAdminServer     = "127.0.0.1:8080"
 	defaultAdminCommandURL = "/commands"
+
+	// defaultContext is the implicit context of the top level cluster config
+	defaultContext = "default"
 )
 
-var zkcmdConf = &zkcmdConfig{}
+var (
+	zkcmdConf = &zkcmdConfig{}
+
+	ctxAdminServer     []string
+	ctxAdminCommandURL string
+)
 
 type zkcmdConfig struct {
-	Server          []string `yaml:"server"`
-	ACL             []string `yaml:"acl"`
-	AdminServer     []string `yaml:"adminServer"`
-	AdminCommandURL string   `yaml:"adminCommandURL"`
+	Server          []string       `yaml:"server"`
+	ACL             []string       `yaml:"acl"`
+	AdminServer     []string       `yaml:"adminServer"`
+	AdminCommandURL string         `yaml:"adminCommandURL"`
+	AdminAuth       string         `yaml:"adminAuth,omitempty"`
+	AdminTLS        zkcmdTLS       `yaml:"adminTLS,omitempty"`
+	TLS             zkcmdTLS       `yaml:"tls,omitempty"`
+	SASL            zkcmdSASL      `yaml:"sasl,omitempty"`
+	JuteMaxBuffer   int            `yaml:"juteMaxBuffer,omitempty"`
+	CurrentContext  string         `yaml:"currentContext,omitempty"`
+	Contexts        []zkcmdContext `yaml:"contexts,omitempty"`
+}
+
+// zkcmdTLS is the TLS config of zookeeper connection, it is enabled if any
+// field is set
+type zkcmdTLS struct {
+	Enabled            bool   `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	Cert               string `json:"cert,omitempty" yaml:"cert,omitempty"`
+	Key                string `json:"key,omitempty" yaml:"key,omitempty"`
+	CA                 string `json:"ca,omitempty" yaml:"ca,omitempty"`
+	ServerName         string `json:"serverName,omitempty" yaml:"serverName,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty" yaml:"insecureSkipVerify,omitempty"`
+}
+
+// zkcmdSASL is the SASL DIGEST-MD5 credentials of zookeeper connection
+type zkcmdSASL struct {
+	User     string `json:"user,omitempty" yaml:"user,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+}
+
+func (t *zkcmdTLS) enabled() bool {
+	return *t != zkcmdTLS{}
+}
+
+// settings return the non-empty TLS config as viper config map
+func (t *zkcmdTLS) settings() map[string]interface{} {
+	m := make(map[string]interface{})
+	if t.Enabled {
+		m["enabled"] = t.Enabled
+	}
+	if t.Cert != "" {
+		m["cert"] = t.Cert
+	}
+	if t.Key != "" {
+		m["key"] = t.Key
+	}
+	if t.CA != "" {
+		m["ca"] = t.CA
+	}
+	if t.ServerName != "" {
+		m["serverName"] = t.ServerName
+	}
+	if t.InsecureSkipVerify {
+		m["insecureSkipVerify"] = t.InsecureSkipVerify
+	}
+
+	return m
+}
+
+// identitySettings return the TLS settings with the client certificate, it is
+// cleared if not set, the other fields are inherited from the top level config
+func (t *zkcmdTLS) identitySettings() map[string]interface{} {
+	m := map[string]interface{}{"cert": "", "key": ""}
+	if t == nil {
+		return m
+	}
+
+	for k, v := range t.settings() {
+		m[k] = v
+	}
+
+	return m
+}
+
+// redactedSecret replace the passwords in the output
+const redactedSecret = "******"
+
+// zkcmdContext is a named cluster config, non-empty fields override the top
+// level config except the credentials, which are used only by their context
+type zkcmdContext struct {
+	Name            string     `json:"name" yaml:"name"`
+	Server          []string   `json:"server,omitempty" yaml:"server,omitempty"`
+	ACL             []string   `json:"acl,omitempty" yaml:"acl,omitempty"`
+	AdminServer     []string   `json:"adminServer,omitempty" yaml:"adminServer,omitempty"`
+	AdminCommandURL string     `json:"adminCommandURL,omitempty" yaml:"adminCommandURL,omitempty"`
+	AdminAuth       string     `json:"adminAuth,omitempty" yaml:"adminAuth,omitempty"`
+	AdminTLS        *zkcmdTLS  `json:"adminTLS,omitempty" yaml:"adminTLS,omitempty"`
+	TLS             *zkcmdTLS  `json:"tls,omitempty" yaml:"tls,omitempty"`
+	SASL            *zkcmdSASL `json:"sasl,omitempty" yaml:"sasl,omitempty"`
+	JuteMaxBuffer   int        `json:"juteMaxBuffer,omitempty" yaml:"juteMaxBuffer,omitempty"`
+}
+
+func (c *zkcmdConfig) getContext(name string) (*zkcmdContext, int) {
+	for i := range c.Contexts {
+		if c.Contexts[i].Name == name {
+			return &c.Contexts[i], i
+		}
+	}
+
+	return nil, -1
+}
+
+// settings return the context config as viper config map, the credentials
+// not set by the context are cleared, so the credentials of the top level
+// config are not sent to other clusters
+func (c *zkcmdContext) settings() map[string]interface{} {
+	m := map[string]interface{}{
+		"acl":       []string{},
+		"adminAuth": "",
+		"sasl":      map[string]interface{}{"user": "", "password": ""},
+	}
+	if len(c.Server) > 0 {
+		m["server"] = c.Server
+	}
+	if len(c.ACL) > 0 {
+		m["acl"] = c.ACL
+	}
+	if len(c.AdminServer) > 0 {
+		m["adminServer"] = c.AdminServer
+	}
+	if c.AdminCommandURL != "" {
+		m["adminCommandURL"] = c.AdminCommandURL
+	}
+	if c.AdminAuth != "" {
+		m["adminAuth"] = c.AdminAuth
+	}
+	m["adminTLS"] = c.AdminTLS.identitySettings()
+	m["tls"] = c.TLS.identitySettings()
+	if c.SASL != nil && c.SASL.User != "" {
+		m["sasl"] = map[string]interface{}{"user": c.SASL.User, "password": c.SASL.Password}
+	}
+	if c.JuteMaxBuffer > 0 {
+		m["juteMaxBuffer"] = c.JuteMaxBuffer
+	}
+
+	return m
+}
+
+// redacted return the context with the passwords masked
+func (c zkcmdContext) redacted() zkcmdContext {
+	if len(c.ACL) > 0 {
+		acls := make([]string, len(c.ACL))
+		for i, a := range c.ACL {
+			user, _, _ := strings.Cut(a, ":")
+			acls[i] = user + ":" + redactedSecret
+		}
+		c.ACL = acls
+	}
+	if c.AdminAuth != "" {
+		scheme, _, _ := strings.Cut(c.AdminAuth, " ")
+		c.AdminAuth = scheme + " " + redactedSecret
+	}
+	if c.SASL != nil && c.SASL.Password != "" {
+		c.SASL = &zkcmdSASL{User: c.SASL.User, Password: redactedSecret}
+	}
+
+	return c
 }
 
 func newCmdConfig() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "config",
-		Short: "zkcmd config init and cat",
+		Short: "zkcmd config init and cat, manage cluster contexts",
 	}
 
 	cmd.AddCommand(newCmdConfigInit())
 	cmd.AddCommand(newCmdConfigCat())
+	cmd.AddCommand(newCmdConfigGetContexts())
+	cmd.AddCommand(newCmdConfigUseContext())
+	cmd.AddCommand(newCmdConfigSetContext())
+	cmd.AddCommand(newCmdConfigDeleteContext())
 
 	return cmd
 }
@@ -59,22 +228,78 @@ func newCmdConfigCat() *cobra.Command {
 	return cmd
 }
 
+func newCmdConfigGetContexts() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get-contexts",
+		Short: "list all cluster contexts, the current context is marked with *",
+		Args:  cobra.ExactArgs(0),
+		Run:   cmdRunConfigGetContexts,
+	}
+
+	return cmd
+}
+
+func newCmdConfigUseContext() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "use-context name",
+		Short:             "set the current context",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeContextNames,
+		Run:               cmdRunConfigUseContext,
+	}
+
+	return cmd
+}
+
+func newCmdConfigSetContext() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set-context [flags] name",
+		Short: "create or update a context",
+		Example: `  zkcmd config set-context prod --server 10.0.0.1:2181,10.0.0.2:2181 --acl user:password
+	  zkcmd config set-context prod --adminServer 10.0.0.1:8080
+	  zkcmd config set-context secure --tls-ca ca.pem --tls-cert client.pem --tls-key client-key.pem
+	  zkcmd config set-context sasl --sasl-user admin --sasl-password secret`,
+		Args: cobra.ExactArgs(1),
+		Run:  cmdRunConfigSetContext,
+	}
+
+	cmd.Flags().StringSliceVarP(&ctxAdminServer, "adminServer", "", nil, "zookeeper AdminServer address of the context, multiple addresses with a comma")
+	cmd.Flags().StringVarP(&ctxAdminCommandURL, "adminCommandURL", "", "", "the AdminServer URL for listing and issuing commands of the context")
+
+	return cmd
+}
+
+func newCmdConfigDeleteContext() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "delete-context name",
+		Short:             "delete a context",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeContextNames,
+		Run:               cmdRunConfigDeleteContext,
+	}
+
+	return cmd
+}
+
 func cmdRunConfigInit(cmd *cobra.Command, args []string) {
 	reader := bufio.NewReader(os.Stdin)
 
+	// the config file without the flags and the current context merged
+	c := loadConfigFile()
+
 	// input cluster address
-	zkcmdConf.Server = inputClusterAddress(reader)
+	c.Server = inputClusterAddress(reader)
 
 	// input cluster ACL
-	zkcmdConf.ACL = inputClusterACL(reader)
+	c.ACL = inputClusterACL(reader)
 
 	// input cluster AdminServer address
-	zkcmdConf.AdminServer = inputAdminServerAddress(reader)
+	c.AdminServer = inputAdminServerAddress(reader)
 
 	// input cluster AdminServer command root URL
-	zkcmdConf.AdminCommandURL = inputAdminServerCommandURL(reader)
+	c.AdminCommandURL = inputAdminServerCommandURL(reader)
 
-	saveConfigFile(zkcmdConf)
+	saveConfigFile(c)
 
 	fmt.Println("########################################")
 	fmt.Println("zkcmd config path:", getConfigFilePath())
@@ -97,6 +322,255 @@ func cmdRunConfigCat(cmd *cobra.Command, args []string) {
 	fmt.Println(string(f))
 }
 
+func cmdRunConfigGetContexts(cmd *cobra.Command, args []string) {
+	c := loadConfigFile()
+
+	current := c.CurrentContext
+	if current == "" {
+		current = defaultContext
+	}
+
+	doc := &contextsDoc{
+		CurrentContext: current,
+		Contexts: []zkcmdContext{{
+			Name:            defaultContext,
+			Server:          c.Server,
+			ACL:             c.ACL,
+			AdminServer:     c.AdminServer,
+			AdminCommandURL: c.AdminCommandURL,
+		}},
+	}
+	doc.Contexts = append(doc.Contexts, c.Contexts...)
+	for i := range doc.Contexts {
+		doc.Contexts[i] = doc.Contexts[i].redacted()
+	}
+
+	printOutput(doc)
+}
+
+func cmdRunConfigUseContext(cmd *cobra.Command, args []string) {
+	name := args[0]
+	c := loadConfigFile()
+
+	if name == defaultContext {
+		name = ""
+	} else if ctx, _ := c.getContext(name); ctx == nil {
+		checkError(errors.Errorf("context %s not found", name))
+	}
+
+	c.CurrentContext = name
+	saveConfigFile(c)
+
+	fmt.Println("Switched to context:", args[0])
+}
+
+func cmdRunConfigSetContext(cmd *cobra.Command, args []string) {
+	name := args[0]
+	if name == defaultContext {
+		checkError(errors.Errorf("context %s is the top level config, please use: zkcmd config init", defaultContext))
+	}
+
+	c := loadConfigFile()
+
+	ctx, _ := c.getContext(name)
+	if ctx == nil {
+		c.Contexts = append(c.Contexts, zkcmdContext{Name: name})
+		ctx = &c.Contexts[len(c.Contexts)-1]
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("server") {
+		ctx.Server, _ = flags.GetStringSlice("server")
+	}
+	if flags.Changed("acl") {
+		ctx.ACL, _ = flags.GetStringSlice("acl")
+	}
+	if flags.Changed("adminServer") {
+		ctx.AdminServer = ctxAdminServer
+	}
+	if flags.Changed("adminCommandURL") {
+		ctx.AdminCommandURL = ctxAdminCommandURL
+	}
+	if changedTLSFlags(flags) {
+		if ctx.TLS == nil {
+			ctx.TLS = &zkcmdTLS{}
+		}
+		setTLSFlags(flags, ctx.TLS)
+	}
+	if flags.Changed("sasl-user") || flags.Changed("sasl-password") {
+		if ctx.SASL == nil {
+			ctx.SASL = &zkcmdSASL{}
+		}
+		if flags.Changed("sasl-user") {
+			ctx.SASL.User, _ = flags.GetString("sasl-user")
+		}
+		if flags.Changed("sasl-password") {
+			ctx.SASL.Password, _ = flags.GetString("sasl-password")
+		}
+	}
+
+	saveConfigFile(c)
+
+	fmt.Println("Context saved:", name)
+}
+
+func cmdRunConfigDeleteContext(cmd *cobra.Command, args []string) {
+	name := args[0]
+	c := loadConfigFile()
+
+	_, i := c.getContext(name)
+	if i < 0 {
+		checkError(errors.Errorf("context %s not found", name))
+	}
+
+	c.Contexts = append(c.Contexts[:i], c.Contexts[i+1:]...)
+	if c.CurrentContext == name {
+		c.CurrentContext = ""
+	}
+
+	saveConfigFile(c)
+
+	fmt.Println("Context deleted:", name)
+}
+
+func completeContextNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	names := []string{defaultContext}
+	for _, ctx := range loadConfigFile().Contexts {
+		names = append(names, ctx.Name)
+	}
+
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// useContext merge the named context config over the top level config
+func useContext(name string) error {
+	if name == "" || name == defaultContext {
+		return nil
+	}
+
+	ctx, _ := zkcmdConf.getContext(name)
+	if ctx == nil {
+		return errors.Errorf("context %s not found", name)
+	}
+
+	if err := viper.MergeConfigMap(ctx.settings()); err != nil {
+		return errors.Wrap(err, "merge context config")
+	}
+
+	// the empty ACL of the context does not replace the slice by unmarshaling
+	acl := viper.GetStringSlice("acl")
+	if err := viper.Unmarshal(zkcmdConf); err != nil {
+		return err
+	}
+	zkcmdConf.ACL = acl
+
+	return nil
+}
+
+// contextCluster return the cluster config of the context, the flags and the
+// current context are not merged
+func contextCluster(name string) (*zkcmdContext, error) {
+	c := loadConfigFile()
+	cluster := &zkcmdContext{Name: name, Server: c.Server, ACL: c.ACL, TLS: &c.TLS, SASL: &c.SASL}
+
+	if name != defaultContext {
+		ctx, _ := c.getContext(name)
+		if ctx == nil {
+			return nil, errors.Errorf("context %s not found", name)
+		}
+
+		// the credentials of the top level config are not inherited
+		tls := c.TLS
+		tls.Cert, tls.Key = "", ""
+		cluster.ACL, cluster.TLS, cluster.SASL = nil, &tls, &zkcmdSASL{}
+
+		if len(ctx.Server) > 0 {
+			cluster.Server = ctx.Server
+		}
+		if len(ctx.ACL) > 0 {
+			cluster.ACL = ctx.ACL
+		}
+		if ctx.TLS != nil {
+			cluster.TLS = ctx.TLS
+		}
+		if ctx.SASL != nil {
+			cluster.SASL = ctx.SASL
+		}
+	}
+
+	if len(cluster.Server) == 0 {
+		cluster.Server = []string{defaultServer}
+	}
+
+	return cluster, nil
+}
+
+// currentCluster return the cluster config of the current context with flags merged
+func currentCluster() *zkcmdContext {
+	return &zkcmdContext{
+		Name:   contextName,
+		Server: zkcmdConf.Server,
+		ACL:    zkcmdConf.ACL,
+		TLS:    &zkcmdConf.TLS,
+		SASL:   &zkcmdConf.SASL,
+	}
+}
+
+var tlsFlags = []string{"tls", "tls-cert", "tls-key", "tls-ca", "tls-server-name", "tls-insecure"}
+
+func changedTLSFlags(flags *pflag.FlagSet) bool {
+	for _, name := range tlsFlags {
+		if flags.Changed(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setTLSFlags set the changed TLS flags to the TLS config
+func setTLSFlags(flags *pflag.FlagSet, t *zkcmdTLS) {
+	if flags.Changed("tls") {
+		t.Enabled, _ = flags.GetBool("tls")
+	}
+	if flags.Changed("tls-cert") {
+		t.Cert, _ = flags.GetString("tls-cert")
+	}
+	if flags.Changed("tls-key") {
+		t.Key, _ = flags.GetString("tls-key")
+	}
+	if flags.Changed("tls-ca") {
+		t.CA, _ = flags.GetString("tls-ca")
+	}
+	if flags.Changed("tls-server-name") {
+		t.ServerName, _ = flags.GetString("tls-server-name")
+	}
+	if flags.Changed("tls-insecure") {
+		t.InsecureSkipVerify, _ = flags.GetBool("tls-insecure")
+	}
+}
+
+// loadConfigFile load config file without flags and context merged, return
+// empty config if the config file does not exist
+func loadConfigFile() *zkcmdConfig {
+	c := &zkcmdConfig{}
+
+	f, err := os.ReadFile(getConfigFilePath())
+	if os.IsNotExist(err) {
+		return c
+	}
+	checkError(err)
+
+	err = yaml.Unmarshal(f, c)
+	checkError(errors.Wrap(err, "invalid config file"))
+
+	return c
+}
+
 func saveConfigFile(c *zkcmdConfig) {
 	cfgFilePath := getConfigFilePath()
 
@@ -111,6 +585,10 @@ func saveConfigFile(c *zkcmdConfig) {
 }
 
 func getConfigFilePath() string {
+	if cfgFile != "" {
+		return cfgFile
+	}
+
 	home, err := os.UserHomeDir()
 	checkError(errors.Wrap(err, "fail to get homedir"))
 
@@ -181,3 +659,30 @@ func inputAdminServerCommandURL(reader *bufio.Reader) string {
 
 	return commandURLTrim
 }
+
+// contextsDoc is the output of config get-contexts
+type contextsDoc struct {
+	CurrentContext string         `json:"currentContext" yaml:"currentContext"`
+	Contexts       []zkcmdContext `json:"contexts" yaml:"contexts"`
+}
+
+func (d *contextsDoc) printTable(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 6, 4, 3, ' ', 0)
+	fmt.Fprintf(tw, "Current\tName\tServer\tAdminServer\t\n")
+	for _, ctx := range d.Contexts {
+		var current string
+		if ctx.Name == d.CurrentContext {
+			current = "*"
+		}
+
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t\n", current, ctx.Name,
+			strings.Join(ctx.Server, ","), strings.Join(ctx.AdminServer, ","))
+	}
+	tw.Flush()
+}
+
+func (d *contextsDoc) printPlain(w io.Writer) {
+	for _, ctx := range d.Contexts {
+		fmt.Fprintln(w, ctx.Name)
+	}
+}
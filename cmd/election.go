@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/benzimu/zkcmd/common/recipe"
+	"github.com/benzimu/zkcmd/common/zookeeper"
+	"github.com/spf13/cobra"
+)
+
+var (
+	electionID     string
+	electionRejoin bool
+)
+
+func newCmdElection() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "election",
+		Short: "Leader election command",
+		Long: `Leader election command, the leader election recipe of zookeeper: every candidate creates
+  an ephemeral sequential znode under the election path, the lowest is the leader and the
+  others watch the znode before them. The candidate znode is deleted if the session is
+  expired, the id and the holder metadata are saved in the znode data.`,
+		PersistentPreRun: connectZK,
+	}
+
+	cmd.AddCommand(newCmdElectionRun())
+	cmd.AddCommand(newCmdElectionStatus())
+
+	return cmd
+}
+
+func newCmdElectionRun() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "run [flags] path -- command [args...]",
+		Short: "Contend for the leadership and run the command while leading",
+		Long: `Contend for the leadership and run the command while leading, the leadership is resigned
+  when the command exits and zkcmd exits with its exit code. The command is terminated if the
+  leadership is lost, like the session is expired, the candidate znode is deleted, or zkcmd
+  is disconnected from the servers longer than the session timeout, another candidate may be
+  elected meanwhile. It is killed if it is still running after --kill-after. With --rejoin the
+  candidate contends again after the leadership is lost and the client is reconnected. SIGINT
+  and SIGTERM are forwarded to the command.`,
+		Example: `  zkcmd election run /election/scheduler --id $(hostname) -- ./scheduler
+  zkcmd election run /election/scheduler --id node1 --rejoin -- ./scheduler --port 8080`,
+		Args: cobra.MinimumNArgs(2),
+		Run:  cmdRunElectionRun,
+	}
+
+	cmd.Flags().StringVarP(&electionID, "id", "", "", "the candidate id saved in the candidate znode (default hostname)")
+	cmd.Flags().BoolVarP(&electionRejoin, "rejoin", "", false, "contend again after the leadership is lost")
+	addKillAfterFlag(cmd)
+
+	return cmd
+}
+
+func newCmdElectionStatus() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status [flags] path",
+		Short: "List the candidates in order and the leader payload, -o plain prints the leader id",
+		Example: `  zkcmd election status /election/scheduler
+  zkcmd election status /election/scheduler -o plain`,
+		Args: cobra.ExactArgs(1),
+		Run:  cmdRunElectionStatus,
+	}
+
+	return cmd
+}
+
+func cmdRunElectionRun(cmd *cobra.Command, args []string) {
+	electionPath, command := znodePath(args[0]), args[1:]
+
+	id := electionID
+	if id == "" {
+		id, _ = os.Hostname()
+	}
+
+	for {
+		data, err := json.Marshal(recipe.NewHolder(id, strings.Join(command, " ")))
+		checkError(err)
+
+		e, err := recipe.NewElection(zkcli, electionPath, data)
+		checkError(err)
+
+		fmt.Fprintf(os.Stderr, "campaign for the leadership of %s as %s\n", electionPath, id)
+		if !campaign(e) {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "elected as the leader of %s, %s\n", electionPath, e.Node())
+
+		code, err := runChild(command, e.Lost())
+		if rerr := e.Resign(); rerr == recipe.ErrLeadershipLost {
+			fmt.Fprintf(os.Stderr, "the leadership of %s is lost\n", electionPath)
+			if electionRejoin && err == nil {
+				continue
+			}
+			if code == 0 {
+				code = 1
+			}
+		} else if err == nil {
+			err = rerr
+		}
+		checkError(err)
+
+		exitChild(code)
+		return
+	}
+}
+
+// campaign wait until elected, it return false if interrupted
+func campaign(e *recipe.Election) bool {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// rejoin after reconnected if the leadership is lost by a network partition
+	err := zkcli.WaitSession(ctx)
+	if err == nil {
+		err = e.Campaign(ctx)
+	}
+	if err == context.Canceled {
+		return false
+	}
+	checkError(err)
+
+	return true
+}
+
+func cmdRunElectionStatus(cmd *cobra.Command, args []string) {
+	electionPath := znodePath(args[0])
+
+	candidates, err := recipe.Candidates(zkcli, electionPath)
+	checkError(err)
+
+	printOutput(newElectionDoc(electionPath, candidates))
+}
+
+// electionDoc is the output of election status
+type electionDoc struct {
+	Path       string          `json:"path" yaml:"path"`
+	Leader     *electionLeader `json:"leader" yaml:"leader"`
+	Candidates []candidate     `json:"candidates" yaml:"candidates"`
+}
+
+type electionLeader struct {
+	Node            string `json:"node" yaml:"node"`
+	ID              string `json:"id,omitempty" yaml:"id,omitempty"`
+	SessionID       string `json:"sessionID" yaml:"sessionID"`
+	Payload         string `json:"payload" yaml:"payload"`
+	PayloadEncoding string `json:"payloadEncoding" yaml:"payloadEncoding"`
+}
+
+type candidate struct {
+	Node      string         `json:"node" yaml:"node"`
+	Sequence  int64          `json:"sequence" yaml:"sequence"`
+	State     string         `json:"state" yaml:"state"`
+	SessionID string         `json:"sessionID" yaml:"sessionID"`
+	Holder    *recipe.Holder `json:"holder,omitempty" yaml:"holder,omitempty"`
+}
+
+func newElectionDoc(p string, candidates []*recipe.Contender) *electionDoc {
+	d := &electionDoc{Path: p, Candidates: make([]candidate, 0, len(candidates))}
+	for _, c := range candidates {
+		state := "candidate"
+		if c.Held {
+			state = "leader"
+
+			d.Leader = &electionLeader{Node: c.Node, SessionID: fmt.Sprintf("0x%x", c.Session)}
+			d.Leader.Payload, d.Leader.PayloadEncoding = zookeeper.EncodeData(c.Data)
+			if c.Holder != nil {
+				d.Leader.ID = c.Holder.ID
+			}
+		}
+
+		d.Candidates = append(d.Candidates, candidate{
+			Node:      c.Node,
+			Sequence:  c.Sequence,
+			State:     state,
+			SessionID: fmt.Sprintf("0x%x", c.Session),
+			Holder:    c.Holder,
+		})
+	}
+
+	return d
+}
+
+func (d *electionDoc) printTable(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 6, 4, 3, ' ', 0)
+	fmt.Fprintf(tw, "Seq\tState\tSession\tID\tHost\tPID\tSince\tNode\t\n")
+	for _, c := range d.Candidates {
+		id, host, pid, since := "-", "-", "-", "-"
+		if h := c.Holder; h != nil {
+			id, host, pid, since = h.ID, h.Host, fmt.Sprint(h.PID), h.Time.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", c.Sequence, c.State, c.SessionID, id, host, pid, since, path.Base(c.Node))
+	}
+	tw.Flush()
+
+	if d.Leader != nil {
+		fmt.Fprintf(w, "\nLeader payload: %s\n", d.Leader.Payload)
+	}
+}
+
+func (d *electionDoc) printPlain(w io.Writer) {
+	if d.Leader == nil {
+		return
+	}
+
+	if d.Leader.ID != "" {
+		fmt.Fprintln(w, d.Leader.ID)
+	} else {
+		fmt.Fprintln(w, d.Leader.Payload)
+	}
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/benzimu/zkcmd/common/exporter"
+	"github.com/benzimu/zkcmd/common/flw"
+	"github.com/benzimu/zkcmd/common/zookeeper"
+	"github.com/go-zookeeper/zk"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exporterListen      string
+	exporterMetricsPath string
+	exporterInterval    time.Duration
+	exporterAdminServer bool
+	exporterZnodes      []string
+)
+
+func newCmdExporter() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "exporter [flags]",
+		Short: "Export the metrics of zookeeper cluster to Prometheus",
+		Long: `Export the metrics of zookeeper cluster to Prometheus, the mntr of every server is polled periodically
+  and exported as zookeeper_<metric> with the server and role labels. The AdminServer monitor command
+  is polled too if --with-adminserver, and the children, data length and mtime age of the --znode
+  paths are exported as zookeeper_znode_<metric> with the path label.`,
+		Example: `  zkcmd exporter --listen :9141
+  zkcmd exporter --listen :9141 --interval 30s --with-adminserver --adminServer 10.0.0.1:8080,10.0.0.2:8080
+  zkcmd exporter --znode /app/locks --znode /app/queue`,
+		Args: cobra.ExactArgs(0),
+		Run:  cmdRunExporter,
+	}
+
+	cmd.Flags().StringVarP(&exporterListen, "listen", "", ":9141", "the address to listen")
+	cmd.Flags().StringVarP(&exporterMetricsPath, "metrics-path", "", "/metrics", "the path of the metrics")
+	cmd.Flags().DurationVarP(&exporterInterval, "interval", "", exporter.DefaultInterval, "the interval of polling")
+	cmd.Flags().BoolVarP(&exporterAdminServer, "with-adminserver", "", false, "poll the AdminServer monitor command too")
+	cmd.Flags().StringSliceVarP(&exporterZnodes, "znode", "", nil, "export the children, data length and mtime age of the znode, multiple paths with a comma")
+	cmdAdminServerCommonFlags(cmd)
+
+	return cmd
+}
+
+func cmdRunExporter(cmd *cobra.Command, args []string) {
+	applyAdminServerFlags(cmd)
+
+	opts := exporter.Options{
+		Servers:  zkcmdConf.Server,
+		Interval: exporterInterval,
+		Timeout:  flw.DefaultTimeout,
+		Znodes:   exporterZnodes,
+		OnError: func(err error) {
+			if verbose {
+				log.Println(err)
+			}
+		},
+	}
+
+	if exporterAdminServer {
+		opts.Monitor = adminServerMonitor
+	}
+
+	if len(exporterZnodes) > 0 {
+		for _, p := range exporterZnodes {
+			checkError(zookeeper.ValidatePath(p, false))
+		}
+
+		connectZK(cmd, args)
+		defer zkcli.Close()
+
+		opts.Stat = func(path string) (*zk.Stat, error) {
+			exist, stat, err := zkcli.Exists(path)
+			if !exist {
+				return nil, err
+			}
+
+			return stat, err
+		}
+	}
+
+	exp := exporter.New(opts)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go exp.Run(stop)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		exp,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	http.Handle(exporterMetricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+
+	log.Printf("Listening on %s, metrics path %s", exporterListen, exporterMetricsPath)
+	checkError(http.ListenAndServe(exporterListen, nil))
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/benzimu/zkcmd/common/flw"
+	"github.com/benzimu/zkcmd/common/health"
+	"github.com/spf13/cobra"
+)
+
+var healthThresholds = health.DefaultThresholds
+
+func newCmdHealth() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "health [flags]",
+		Short: "Check the health of zookeeper cluster, exit 0/1/2 for OK/WARN/CRIT",
+		Long: `Check the health of zookeeper cluster by ruok, srvr and mntr of every server, the AdminServer
+  ruok and monitor commands are used if ruok and mntr are not in the whitelist, and the server is
+  checked by srvr if neither ruok is allowed. It checks that the majority of servers are serving,
+  there is exactly one leader, the followers are synced, and the zxid skew, average latency,
+  outstanding requests and open file descriptor ratio are below the thresholds. A threshold of 0 disables it.
+  Exit 0 if OK, 1 if WARN and 2 if CRIT, like the monitoring plugins.`,
+		Example: `  zkcmd health
+  zkcmd health -o json
+  zkcmd health --latency-warn 50 --latency-crit 200 --zxid-skew-crit 0`,
+		Args: cobra.ExactArgs(0),
+		Run:  cmdRunHealth,
+	}
+
+	t := &healthThresholds
+	cmd.Flags().Int64VarP(&t.ZxidSkewWarn, "zxid-skew-warn", "", t.ZxidSkewWarn, "WARN if a server is behind the latest zxid by the transactions")
+	cmd.Flags().Int64VarP(&t.ZxidSkewCrit, "zxid-skew-crit", "", t.ZxidSkewCrit, "CRIT if a server is behind the latest zxid by the transactions")
+	cmd.Flags().Float64VarP(&t.LatencyWarn, "latency-warn", "", t.LatencyWarn, "WARN if the average latency in milliseconds is reached")
+	cmd.Flags().Float64VarP(&t.LatencyCrit, "latency-crit", "", t.LatencyCrit, "CRIT if the average latency in milliseconds is reached")
+	cmd.Flags().Int64VarP(&t.OutstandingWarn, "outstanding-warn", "", t.OutstandingWarn, "WARN if the outstanding requests are reached")
+	cmd.Flags().Int64VarP(&t.OutstandingCrit, "outstanding-crit", "", t.OutstandingCrit, "CRIT if the outstanding requests are reached")
+	cmd.Flags().Float64VarP(&t.FDRatioWarn, "fd-ratio-warn", "", t.FDRatioWarn, "WARN if the ratio of open to max file descriptors is reached")
+	cmd.Flags().Float64VarP(&t.FDRatioCrit, "fd-ratio-crit", "", t.FDRatioCrit, "CRIT if the ratio of open to max file descriptors is reached")
+	cmdAdminServerCommonFlags(cmd)
+
+	return cmd
+}
+
+func cmdRunHealth(cmd *cobra.Command, args []string) {
+	applyAdminServerFlags(cmd)
+
+	servers := health.Collect(zkcmdConf.Server, health.CollectOptions{
+		Timeout: flw.DefaultTimeout,
+		Monitor: adminServerMonitor,
+		Ruok:    adminServerRuok,
+	})
+
+	report := health.Evaluate(servers, healthThresholds)
+	printOutput(&healthDoc{report})
+
+	os.Exit(int(report.Status))
+}
+
+// healthDoc is the output of health
+type healthDoc struct {
+	*health.Report `yaml:",inline"`
+}
+
+func (d *healthDoc) printTable(w io.Writer) {
+	fmt.Fprintln(w, d.Summary)
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 6, 4, 3, ' ', 0)
+	fmt.Fprintln(tw, "Status\tCheck\tServer\tMessage\t")
+	for _, c := range d.Checks {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", c.Status, c.Name, orDash(c.Server), c.Message)
+	}
+	tw.Flush()
+}
+
+func (d *healthDoc) printPlain(w io.Writer) {
+	fmt.Fprintln(w, d.Summary)
+}
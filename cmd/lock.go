@@ -0,0 +1,384 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/benzimu/zkcmd/common/recipe"
+	"github.com/go-zookeeper/zk"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	lockShared  bool
+	lockTimeout time.Duration
+	killAfter   time.Duration
+)
+
+// heldLocks are the locks acquired in the shell by the path, they are held
+// by the shell session until released
+var heldLocks = make(map[string]*recipe.Lock)
+
+func newCmdLock() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lock",
+		Short: "Distributed lock command",
+		Long: `Distributed lock command, the lock recipe of zookeeper: every contender creates an ephemeral
+  sequential znode under the lock path and waits for the znode before it, the lowest holds
+  the lock. The shared locks are held together until an exclusive lock is before them, like
+  the read and write locks. The lock znodes are deleted if the session is expired, the holder
+  metadata is saved in the znode data.`,
+		PersistentPreRun: connectZK,
+	}
+
+	cmd.AddCommand(newCmdLockAcquire())
+	cmd.AddCommand(newCmdLockRelease())
+	cmd.AddCommand(newCmdLockStatus())
+	cmd.AddCommand(newCmdLockExec())
+
+	return cmd
+}
+
+func newCmdLockAcquire() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "acquire [flags] path",
+		Short: "Acquire the lock and hold it until interrupted, the shell holds it until released",
+		Example: `  zkcmd lock acquire /locks/deploy
+  zkcmd lock acquire /locks/db --shared --timeout 30s`,
+		Args: cobra.ExactArgs(1),
+		Run:  cmdRunLockAcquire,
+	}
+
+	addLockFlags(cmd)
+
+	return cmd
+}
+
+func newCmdLockRelease() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "release [flags] path",
+		Short:   "Release the lock held by the shell, with --force delete the znodes of the current holders",
+		Example: `  zkcmd lock release /locks/deploy --force`,
+		Args:    cobra.ExactArgs(1),
+		Run:     cmdRunLockRelease,
+	}
+
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "delete the znodes of the current holders")
+
+	return cmd
+}
+
+func newCmdLockStatus() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status [flags] path",
+		Short: "List the holders and the waiters of the lock in order",
+		Example: `  zkcmd lock status /locks/deploy
+  zkcmd lock status /locks/deploy -o json`,
+		Args: cobra.ExactArgs(1),
+		Run:  cmdRunLockStatus,
+	}
+
+	return cmd
+}
+
+func newCmdLockExec() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "exec [flags] path -- command [args...]",
+		Short: "Run the command while holding the lock",
+		Long: `Run the command while holding the lock, the lock is released when the command exits and
+  zkcmd exits with its exit code. The command is terminated if the lock is lost, like the
+  session is expired, the lock znode is deleted, or zkcmd is disconnected from the servers
+  longer than the session timeout, the server may grant the lock to others meanwhile. It is
+  killed if it is still running after --kill-after. SIGINT and SIGTERM are forwarded to the
+  command.`,
+		Example: `  zkcmd lock exec /locks/deploy -- ./deploy.sh production
+  zkcmd lock exec /locks/db --shared --timeout 1m -- pg_dump app`,
+		Args: cobra.MinimumNArgs(2),
+		Run:  cmdRunLockExec,
+	}
+
+	addLockFlags(cmd)
+	addKillAfterFlag(cmd)
+
+	return cmd
+}
+
+func addLockFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&lockShared, "shared", "", false, "acquire the shared lock instead of the exclusive lock")
+	cmd.Flags().DurationVarP(&lockTimeout, "timeout", "t", 0, "the timeout of acquiring the lock, 0 means no timeout")
+}
+
+func addKillAfterFlag(cmd *cobra.Command) {
+	cmd.Flags().DurationVarP(&killAfter, "kill-after", "", 10*time.Second, "kill the command if it is still running after terminated")
+}
+
+// acquireLock acquire the lock of the path by the flags, command is saved in
+// the holder metadata
+func acquireLock(lockPath, command string) *recipe.Lock {
+	mode := recipe.LockExclusive
+	if lockShared {
+		mode = recipe.LockShared
+	}
+
+	data, err := json.Marshal(recipe.NewHolder("", command))
+	checkError(err)
+
+	l, err := recipe.NewLock(zkcli, lockPath, mode, data)
+	checkError(err)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if lockTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, lockTimeout)
+		defer cancel()
+	}
+
+	err = l.Acquire(ctx)
+	if err == context.DeadlineExceeded {
+		err = errors.Errorf("timeout to acquire the lock %s after %s", lockPath, lockTimeout)
+	}
+	checkError(err)
+
+	return l
+}
+
+func cmdRunLockAcquire(cmd *cobra.Command, args []string) {
+	lockPath := znodePath(args[0])
+	if inShell && heldLocks[lockPath] != nil {
+		checkError(errors.Errorf("the lock %s is already held by the shell", lockPath))
+	}
+
+	l := acquireLock(lockPath, "")
+	doc := &lockDoc{Path: lockPath, Node: l.Node(), SessionID: fmt.Sprintf("0x%x", zkcli.SessionID())}
+
+	if inShell {
+		heldLocks[lockPath] = l
+		printOutput(doc)
+		return
+	}
+
+	doc.Hint = "hold until interrupted"
+	printOutput(doc)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case <-ctx.Done():
+		checkError(l.Release())
+	case <-l.Lost():
+		checkError(recipe.ErrLockLost)
+	}
+}
+
+func cmdRunLockRelease(cmd *cobra.Command, args []string) {
+	lockPath := znodePath(args[0])
+
+	if l := heldLocks[lockPath]; l != nil {
+		delete(heldLocks, lockPath)
+		checkError(l.Release())
+		return
+	}
+
+	if !force {
+		checkError(errors.Errorf("the lock %s is not held by the shell, use --force to delete the znodes of the holders", lockPath))
+	}
+
+	contenders, err := recipe.LockStatus(zkcli, lockPath)
+	checkError(err)
+
+	for _, c := range contenders {
+		if !c.Held {
+			continue
+		}
+
+		err = zkcli.Delete(c.Node, -1)
+		if err == zk.ErrNoNode {
+			continue
+		}
+		checkError(errors.Wrapf(err, "delete %s", c.Node))
+		fmt.Printf("deleted %s\n", c.Node)
+	}
+}
+
+func cmdRunLockStatus(cmd *cobra.Command, args []string) {
+	lockPath := znodePath(args[0])
+
+	contenders, err := recipe.LockStatus(zkcli, lockPath)
+	checkError(err)
+
+	printOutput(newContendersDoc(lockPath, contenders))
+}
+
+func cmdRunLockExec(cmd *cobra.Command, args []string) {
+	lockPath, command := znodePath(args[0]), args[1:]
+
+	l := acquireLock(lockPath, strings.Join(command, " "))
+
+	code, err := runChild(command, l.Lost())
+	if rerr := l.Release(); rerr == recipe.ErrLockLost {
+		fmt.Fprintf(os.Stderr, "the lock %s is lost while running the command\n", lockPath)
+		if code == 0 {
+			code = 1
+		}
+	} else if err == nil {
+		err = rerr
+	}
+	checkError(err)
+
+	exitChild(code)
+}
+
+// runChild run the command with the stdio and forward SIGINT and SIGTERM to
+// it, the command is terminated if stop is closed, and killed if it is still
+// running after killAfter. It return the exit code of the command.
+func runChild(command []string, stop <-chan struct{}) (int, error) {
+	child := exec.Command(command[0], command[1:]...)
+	child.Stdin, child.Stdout, child.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	// catch the signals before starting so they are not missed
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	if err := child.Start(); err != nil {
+		return 0, errors.Wrapf(err, "start %s", command[0])
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- child.Wait()
+	}()
+
+	var kill <-chan time.Time
+	for {
+		select {
+		case err := <-done:
+			return exitCode(child, err)
+		case sig := <-sigs:
+			_ = child.Process.Signal(sig)
+		case <-stop:
+			stop = nil
+			fmt.Fprintf(os.Stderr, "terminate %s, pid %d\n", command[0], child.Process.Pid)
+			_ = child.Process.Signal(syscall.SIGTERM)
+			kill = time.After(killAfter)
+		case <-kill:
+			fmt.Fprintf(os.Stderr, "kill %s, pid %d\n", command[0], child.Process.Pid)
+			_ = child.Process.Kill()
+		}
+	}
+}
+
+// exitCode return the exit code of the exited command, it is 128 plus the
+// signal number if the command is killed by a signal
+func exitCode(child *exec.Cmd, err error) (int, error) {
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		return 0, err
+	}
+
+	if ws, ok := child.ProcessState.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal()), nil
+	}
+
+	return child.ProcessState.ExitCode(), nil
+}
+
+// exitChild exit with the exit code of the command, the shell is not exited
+func exitChild(code int) {
+	if code == 0 {
+		return
+	}
+
+	if inShell {
+		checkError(errors.Errorf("the command exited with code %d", code))
+	}
+	os.Exit(code)
+}
+
+// lockDoc is the output of lock acquire
+type lockDoc struct {
+	Path      string `json:"path" yaml:"path"`
+	Node      string `json:"node" yaml:"node"`
+	SessionID string `json:"sessionID" yaml:"sessionID"`
+	Hint      string `json:"-" yaml:"-"`
+}
+
+func (d *lockDoc) printTable(w io.Writer) {
+	fmt.Fprintf(w, "acquired %s, session %s", d.Node, d.SessionID)
+	if d.Hint != "" {
+		fmt.Fprintf(w, ", %s", d.Hint)
+	}
+	fmt.Fprintln(w)
+}
+
+func (d *lockDoc) printPlain(w io.Writer) {
+	fmt.Fprintln(w, d.Node)
+}
+
+// contendersDoc is the output of lock status and election status
+type contendersDoc struct {
+	Path       string      `json:"path" yaml:"path"`
+	Contenders []contender `json:"contenders" yaml:"contenders"`
+}
+
+type contender struct {
+	Node      string         `json:"node" yaml:"node"`
+	Sequence  int64          `json:"sequence" yaml:"sequence"`
+	Mode      string         `json:"mode,omitempty" yaml:"mode,omitempty"`
+	State     string         `json:"state" yaml:"state"`
+	SessionID string         `json:"sessionID" yaml:"sessionID"`
+	Holder    *recipe.Holder `json:"holder,omitempty" yaml:"holder,omitempty"`
+}
+
+func newContendersDoc(p string, contenders []*recipe.Contender) *contendersDoc {
+	d := &contendersDoc{Path: p, Contenders: make([]contender, 0, len(contenders))}
+	for _, c := range contenders {
+		state := "waiting"
+		if c.Held {
+			state = "held"
+		}
+
+		d.Contenders = append(d.Contenders, contender{
+			Node:      c.Node,
+			Sequence:  c.Sequence,
+			Mode:      c.Mode,
+			State:     state,
+			SessionID: fmt.Sprintf("0x%x", c.Session),
+			Holder:    c.Holder,
+		})
+	}
+
+	return d
+}
+
+func (d *contendersDoc) printTable(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 6, 4, 3, ' ', 0)
+	fmt.Fprintf(tw, "Seq\tMode\tState\tSession\tHost\tPID\tSince\tNode\t\n")
+	for _, c := range d.Contenders {
+		host, pid, since := "-", "-", "-"
+		if h := c.Holder; h != nil {
+			host, pid, since = h.Host, fmt.Sprint(h.PID), h.Time.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", c.Sequence, c.Mode, c.State, c.SessionID, host, pid, since, path.Base(c.Node))
+	}
+	tw.Flush()
+}
+
+func (d *contendersDoc) printPlain(w io.Writer) {
+	for _, c := range d.Contenders {
+		fmt.Fprintf(w, "%s\t%s\n", c.State, c.Node)
+	}
+}
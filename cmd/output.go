@@ -0,0 +1,310 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/benzimu/zkcmd/common/codec"
+	"github.com/benzimu/zkcmd/common/zookeeper"
+	"github.com/go-zookeeper/zk"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+	outputPlain = "plain"
+)
+
+var outputFormat string
+
+// outputDocument is a command result, json and yaml output are encoded
+// from the document itself, table and plain output are written by it.
+type outputDocument interface {
+	printTable(w io.Writer)
+	printPlain(w io.Writer)
+}
+
+func validateOutputFormat() error {
+	switch outputFormat {
+	case "", outputTable, outputJSON, outputYAML, outputPlain:
+		return nil
+	}
+
+	return errors.Errorf("invalid output format: %s, must be one of: %s, %s, %s, %s",
+		outputFormat, outputTable, outputJSON, outputYAML, outputPlain)
+}
+
+// printOutput print document to stdout by the output format
+func printOutput(doc outputDocument) {
+	switch outputFormat {
+	case outputJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		checkError(enc.Encode(doc))
+	case outputYAML:
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		checkError(enc.Encode(doc))
+		checkError(enc.Close())
+	case outputPlain:
+		doc.printPlain(os.Stdout)
+	default:
+		doc.printTable(os.Stdout)
+	}
+}
+
+type znodeStat struct {
+	Czxid          int64     `json:"czxid" yaml:"czxid"`
+	Mzxid          int64     `json:"mzxid" yaml:"mzxid"`
+	Pzxid          int64     `json:"pzxid" yaml:"pzxid"`
+	Ctime          time.Time `json:"ctime" yaml:"ctime"`
+	Mtime          time.Time `json:"mtime" yaml:"mtime"`
+	DataVersion    int32     `json:"dataVersion" yaml:"dataVersion"`
+	Cversion       int32     `json:"cversion" yaml:"cversion"`
+	AclVersion     int32     `json:"aclVersion" yaml:"aclVersion"`
+	EphemeralOwner int64     `json:"ephemeralOwner" yaml:"ephemeralOwner"`
+	DataLength     int32     `json:"dataLength" yaml:"dataLength"`
+	NumChildren    int32     `json:"numChildren" yaml:"numChildren"`
+}
+
+func newZnodeStat(stat *zk.Stat) *znodeStat {
+	if stat == nil {
+		return nil
+	}
+
+	return &znodeStat{
+		Czxid:          stat.Czxid,
+		Mzxid:          stat.Mzxid,
+		Pzxid:          stat.Pzxid,
+		Ctime:          time.UnixMilli(stat.Ctime),
+		Mtime:          time.UnixMilli(stat.Mtime),
+		DataVersion:    stat.Version,
+		Cversion:       stat.Cversion,
+		AclVersion:     stat.Aversion,
+		EphemeralOwner: stat.EphemeralOwner,
+		DataLength:     stat.DataLength,
+		NumChildren:    stat.NumChildren,
+	}
+}
+
+func (s *znodeStat) printTable(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 6, 4, 3, ' ', 0)
+	fmt.Fprintf(tw, "----------\t\n")
+	fmt.Fprintf(tw, "Czxid\t%#x\t\n", s.Czxid)
+	fmt.Fprintf(tw, "Mzxid\t%#x\t\n", s.Mzxid)
+	fmt.Fprintf(tw, "Pzxid\t%#x\t\n", s.Pzxid)
+	fmt.Fprintf(tw, "Ctime\t%v\t\n", s.Ctime.Truncate(time.Second))
+	fmt.Fprintf(tw, "Mtime\t%v\t\n", s.Mtime.Truncate(time.Second))
+	fmt.Fprintf(tw, "DataVersion\t%v\t\n", s.DataVersion)
+	fmt.Fprintf(tw, "Cversion\t%v\t\n", s.Cversion)
+	fmt.Fprintf(tw, "AclVersion\t%v\t\n", s.AclVersion)
+	fmt.Fprintf(tw, "EphemeralOwner\t%v\t\n", s.EphemeralOwner)
+	fmt.Fprintf(tw, "DataLength\t%v\t\n", s.DataLength)
+	tw.Flush()
+}
+
+func (s *znodeStat) printPlain(w io.Writer) {
+	fmt.Fprintf(w, "czxid=%#x\n", s.Czxid)
+	fmt.Fprintf(w, "mzxid=%#x\n", s.Mzxid)
+	fmt.Fprintf(w, "pzxid=%#x\n", s.Pzxid)
+	fmt.Fprintf(w, "ctime=%d\n", s.Ctime.UnixMilli())
+	fmt.Fprintf(w, "mtime=%d\n", s.Mtime.UnixMilli())
+	fmt.Fprintf(w, "dataVersion=%d\n", s.DataVersion)
+	fmt.Fprintf(w, "cversion=%d\n", s.Cversion)
+	fmt.Fprintf(w, "aclVersion=%d\n", s.AclVersion)
+	fmt.Fprintf(w, "ephemeralOwner=%d\n", s.EphemeralOwner)
+	fmt.Fprintf(w, "dataLength=%d\n", s.DataLength)
+	fmt.Fprintf(w, "numChildren=%d\n", s.NumChildren)
+}
+
+// znodeStatDoc is the output of commands which only print znode stat
+type znodeStatDoc struct {
+	Path string     `json:"path" yaml:"path"`
+	Stat *znodeStat `json:"stat" yaml:"stat"`
+}
+
+func (d *znodeStatDoc) printTable(w io.Writer) {
+	d.Stat.printTable(w)
+}
+
+func (d *znodeStatDoc) printPlain(w io.Writer) {
+	d.Stat.printPlain(w)
+}
+
+type znodeChild struct {
+	Path        string `json:"path" yaml:"path"`
+	NumChildren int32  `json:"numChildren" yaml:"numChildren"`
+}
+
+// znodeChildrenDoc is the output of znode ls
+type znodeChildrenDoc struct {
+	Path     string       `json:"path" yaml:"path"`
+	Children []znodeChild `json:"children" yaml:"children"`
+	Stat     *znodeStat   `json:"stat,omitempty" yaml:"stat,omitempty"`
+}
+
+func (d *znodeChildrenDoc) printTable(w io.Writer) {
+	if len(d.Children) != 0 {
+		tw := tabwriter.NewWriter(w, 6, 4, 3, ' ', 0)
+		fmt.Fprintf(tw, "ID\tPath\tChildrenNum\t\n")
+		for i, c := range d.Children {
+			fmt.Fprintf(tw, "%v\t%v\t%v\t\n", i+1, c.Path, c.NumChildren)
+		}
+		tw.Flush()
+	}
+
+	if d.Stat != nil {
+		d.Stat.printTable(w)
+	}
+}
+
+func (d *znodeChildrenDoc) printPlain(w io.Writer) {
+	for _, c := range d.Children {
+		fmt.Fprintln(w, c.Path)
+	}
+}
+
+// znodeLeavesDoc is the output of znode ll
+type znodeLeavesDoc struct {
+	Path   string   `json:"path" yaml:"path"`
+	Znodes []string `json:"znodes" yaml:"znodes"`
+}
+
+func (d *znodeLeavesDoc) printTable(w io.Writer) {
+	if len(d.Znodes) == 0 {
+		return
+	}
+
+	d.printTableHeader(w)
+	for i, n := range d.Znodes {
+		d.printTableRow(w, i+1, n)
+	}
+}
+
+// printTableHeader and printTableRow print the table while walking, the rows
+// are aligned by the ID column width
+func (d *znodeLeavesDoc) printTableHeader(w io.Writer) {
+	fmt.Fprintf(w, "%-8v%v\n", "ID", "Path")
+}
+
+func (d *znodeLeavesDoc) printTableRow(w io.Writer, id int, path string) {
+	fmt.Fprintf(w, "%-8v%v\n", id, path)
+}
+
+func (d *znodeLeavesDoc) printPlain(w io.Writer) {
+	for _, n := range d.Znodes {
+		fmt.Fprintln(w, n)
+	}
+}
+
+// znodeDataDoc is the output of znode get, data is the raw data and it is
+// base64 encoded when it is not valid UTF-8, decoded is the data decoded by
+// codec, it is omitted if no codec is applied
+type znodeDataDoc struct {
+	Path            string     `json:"path" yaml:"path"`
+	NumChildren     int32      `json:"numChildren" yaml:"numChildren"`
+	Data            string     `json:"data" yaml:"data"`
+	DataEncoding    string     `json:"dataEncoding" yaml:"dataEncoding"`
+	Codec           string     `json:"codec,omitempty" yaml:"codec,omitempty"`
+	Decoded         string     `json:"decoded,omitempty" yaml:"decoded,omitempty"`
+	DecodedEncoding string     `json:"decodedEncoding,omitempty" yaml:"decodedEncoding,omitempty"`
+	Stat            *znodeStat `json:"stat,omitempty" yaml:"stat,omitempty"`
+
+	raw []byte
+}
+
+func newZnodeDataDoc(path string, data []byte, stat *zk.Stat) *znodeDataDoc {
+	d := &znodeDataDoc{
+		Path:        path,
+		NumChildren: stat.NumChildren,
+		raw:         data,
+	}
+	d.Data, d.DataEncoding = zookeeper.EncodeData(data)
+
+	return d
+}
+
+// setDecoded set the decoded data, it is printed by table and plain, and it
+// is set apart from the raw data of json and yaml unless the codec is raw
+func (d *znodeDataDoc) setDecoded(chain codec.Chain, data []byte) {
+	d.raw = data
+	if s := chain.String(); s != "raw" {
+		d.Codec = s
+		d.Decoded, d.DecodedEncoding = zookeeper.EncodeData(data)
+	}
+}
+
+func (d *znodeDataDoc) printTable(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 6, 4, 3, '\t', 0)
+	fmt.Fprintf(tw, "ChildrenNum:\t%v\t\n", d.NumChildren)
+	fmt.Fprintf(tw, "Value:      \t\n%v\t\n", string(d.raw))
+	tw.Flush()
+
+	if d.Stat != nil {
+		d.Stat.printTable(w)
+	}
+}
+
+func (d *znodeDataDoc) printPlain(w io.Writer) {
+	_, _ = w.Write(d.raw)
+}
+
+type aclEntry struct {
+	Scheme string `json:"scheme" yaml:"scheme"`
+	ID     string `json:"id" yaml:"id"`
+	Perms  string `json:"perms" yaml:"perms"`
+}
+
+func newACLEntries(acls []zk.ACL) []aclEntry {
+	entries := make([]aclEntry, len(acls))
+	for i, a := range acls {
+		entries[i] = aclEntry{
+			Scheme: a.Scheme,
+			ID:     a.ID,
+			Perms:  zookeeper.FormatPerms(a.Perms),
+		}
+	}
+
+	return entries
+}
+
+// aclDoc is the output of acl get
+type aclDoc struct {
+	Path        string     `json:"path" yaml:"path"`
+	NumChildren int32      `json:"numChildren" yaml:"numChildren"`
+	ACL         []aclEntry `json:"acl" yaml:"acl"`
+	Stat        *znodeStat `json:"stat,omitempty" yaml:"stat,omitempty"`
+
+	acls []zk.ACL
+}
+
+func newACLDoc(path string, acls []zk.ACL, stat *zk.Stat) *aclDoc {
+	return &aclDoc{
+		Path:        path,
+		NumChildren: stat.NumChildren,
+		ACL:         newACLEntries(acls),
+		acls:        acls,
+	}
+}
+
+func (d *aclDoc) printTable(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 6, 4, 3, '\t', 0)
+	fmt.Fprintf(tw, "ChildrenNum:\t%v\t\n", d.NumChildren)
+	fmt.Fprintf(tw, "ACL:        \t%v\t\n", zookeeper.FormatACLs(d.acls))
+	tw.Flush()
+
+	if d.Stat != nil {
+		d.Stat.printTable(w)
+	}
+}
+
+func (d *aclDoc) printPlain(w io.Writer) {
+	fmt.Fprintln(w, zookeeper.FormatACLs(d.acls))
+}
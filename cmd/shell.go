@@ -0,0 +1,364 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/chzyer/readline"
+	"github.com/go-zookeeper/zk"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const shellHistoryFile = ".zkcmd_history"
+
+var (
+	// inShell is true if the commands are run by the interactive shell
+	inShell bool
+	// workDir is the shell working directory, relative paths are relative to it
+	workDir = "/"
+)
+
+// shellError is the error of a shell command, checkError panic with it in the
+// shell so that the shell is not exited
+type shellError struct {
+	err error
+}
+
+func newCmdShell() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "shell",
+		Short: "Interactive shell with one zookeeper session",
+		Long: `Interactive shell with one zookeeper session.
+  All znode and acl commands are supported, the znode commands can be run without the "znode" prefix.
+  Relative paths are relative to the working directory, use "cd" and "pwd" to change and show it.
+  Press TAB to complete commands and znode paths, Ctrl-D or "exit" to quit.`,
+		Example: `  zkcmd shell
+  zkcmd shell --server 127.0.0.1:2181 -o json`,
+		Args: cobra.NoArgs,
+		Run:  cmdRunShell,
+	}
+
+	return cmd
+}
+
+func cmdRunShell(cmd *cobra.Command, args []string) {
+	connectZK(cmd, args)
+	defer zkcli.Close()
+
+	var historyFile string
+	if home, err := os.UserHomeDir(); err == nil {
+		historyFile = filepath.Join(home, shellHistoryFile)
+	}
+
+	defaultOutput := outputFormat
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:          shellPrompt(),
+		HistoryFile:     historyFile,
+		AutoComplete:    &shellCompleter{defaultOutput: defaultOutput},
+		InterruptPrompt: "^C",
+		EOFPrompt:       "exit",
+	})
+	checkError(err)
+	defer rl.Close()
+
+	// the running command is interrupted by ctrl-c, not the shell
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	defer signal.Stop(sigs)
+
+	inShell = true
+	defer func() { inShell = false }()
+
+	for {
+		rl.SetPrompt(shellPrompt())
+
+		line, err := rl.Readline()
+		if err == readline.ErrInterrupt {
+			continue
+		}
+		if err == io.EOF {
+			return
+		}
+		checkError(err)
+
+		args, err := splitShellLine(line)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		if !runShellLine(defaultOutput, args) {
+			return
+		}
+
+		select {
+		case <-sigs:
+		default:
+		}
+	}
+}
+
+// runShellLine run the command line, return false if the shell should exit
+func runShellLine(defaultOutput string, args []string) (next bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			se, ok := r.(shellError)
+			if !ok {
+				panic(r)
+			}
+
+			fmt.Println(se.err)
+			next = true
+		}
+	}()
+
+	exit := false
+	root := newShellRootCommand(defaultOutput, &exit)
+
+	if c, _, err := root.Find(args); err != nil || (c == root && !strings.HasPrefix(args[0], "-")) {
+		fmt.Printf("unknown command %q, run \"help\" for usage\n", args[0])
+		return true
+	}
+
+	root.SetArgs(args)
+	_ = root.Execute()
+
+	return !exit
+}
+
+// newShellRootCommand new the command tree of the shell, it is created for
+// every line so that the flags are reset to default values
+func newShellRootCommand(defaultOutput string, exit *bool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "",
+		CompletionOptions: cobra.CompletionOptions{DisableDefaultCmd: true},
+	}
+
+	cmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", defaultOutput, "output format, one of: table, json, yaml, plain")
+
+	cmd.AddCommand(newCmdZnode().Commands()...)
+	cmd.AddCommand(newCmdZnode())
+	cmd.AddCommand(newCmdACL())
+	cmd.AddCommand(newCmdLock())
+	cmd.AddCommand(newCmdElection())
+	cmd.AddCommand(&cobra.Command{
+		Use:   "cd [path]",
+		Short: "Change the working directory, the path default: /",
+		Args:  cobra.MaximumNArgs(1),
+		Run:   cmdRunShellCd,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "pwd",
+		Short: "Print the working directory",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Println(workDir)
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "session",
+		Short: "Print the session id, connected server and state",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			printOutput(&sessionDoc{
+				SessionID: fmt.Sprintf("0x%x", zkcli.SessionID()),
+				Server:    zkcli.Server(),
+				State:     strings.TrimPrefix(zkcli.State().String(), "State"),
+			})
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:     "exit",
+		Aliases: []string{"quit"},
+		Short:   "Exit the shell",
+		Args:    cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			*exit = true
+		},
+	})
+	cmd.InitDefaultHelpCmd()
+
+	return cmd
+}
+
+func cmdRunShellCd(cmd *cobra.Command, args []string) {
+	dir := "/"
+	if len(args) > 0 {
+		dir = path.Clean(znodePath(args[0]))
+	}
+
+	exist, _, err := zkcli.Exists(dir)
+	checkError(err)
+
+	if !exist {
+		checkError(errors.Wrap(zk.ErrNoNode, dir))
+	}
+
+	workDir = dir
+}
+
+func shellPrompt() string {
+	return fmt.Sprintf("zkcmd:%s> ", workDir)
+}
+
+// splitShellLine split the line to args like a shell, the args can be
+// quoted by single or double quotes, and backslash escape the next char
+func splitShellLine(line string) ([]string, error) {
+	var (
+		args    []string
+		arg     strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			arg.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote or escape")
+	}
+
+	if inArg {
+		args = append(args, arg.String())
+	}
+
+	return args, nil
+}
+
+// shellCompleter complete the command names and the znode paths, the
+// children of znode are fetched when TAB is pressed
+type shellCompleter struct {
+	defaultOutput string
+}
+
+func (c *shellCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	words := strings.Fields(string(line[:pos]))
+	// the word to complete is empty if the line ends with space
+	if pos == 0 || line[pos-1] == ' ' || line[pos-1] == '\t' {
+		words = append(words, "")
+	}
+
+	word := words[len(words)-1]
+	root := newShellRootCommand(c.defaultOutput, new(bool))
+
+	if len(words) == 1 {
+		return completeCommands(root, word), len([]rune(word))
+	}
+
+	if len(words) == 2 {
+		if sub, _, err := root.Find(words[:1]); err == nil && sub.HasSubCommands() {
+			return completeCommands(sub, word), len([]rune(word))
+		}
+	}
+
+	if strings.HasPrefix(word, "-") {
+		return nil, 0
+	}
+
+	return completePath(word)
+}
+
+func completeCommands(cmd *cobra.Command, prefix string) [][]rune {
+	var candidates [][]rune
+	for _, sub := range cmd.Commands() {
+		if sub.Hidden {
+			continue
+		}
+
+		for _, name := range append([]string{sub.Name()}, sub.Aliases...) {
+			if strings.HasPrefix(name, prefix) {
+				candidates = append(candidates, []rune(name[len(prefix):]+" "))
+			}
+		}
+	}
+
+	return candidates
+}
+
+// completePath complete the last name of the path by the children of its
+// parent, a "/" is appended if there is only one candidate and it has children
+func completePath(word string) ([][]rune, int) {
+	dir, prefix := workDir, word
+	if i := strings.LastIndex(word, "/"); i >= 0 {
+		dir, prefix = znodePath(word[:i+1]), word[i+1:]
+	}
+
+	children, _, err := zkcli.Children(dir)
+	if err != nil {
+		return nil, 0
+	}
+
+	var names []string
+	for _, child := range children {
+		if strings.HasPrefix(child, prefix) {
+			names = append(names, child)
+		}
+	}
+
+	if len(names) == 1 {
+		_, stat, err := zkcli.Exists(path.Join(dir, names[0]))
+		if err == nil && stat.NumChildren > 0 {
+			return [][]rune{[]rune(names[0][len(prefix):] + "/")}, len([]rune(prefix))
+		}
+
+		return [][]rune{[]rune(names[0][len(prefix):] + " ")}, len([]rune(prefix))
+	}
+
+	candidates := make([][]rune, len(names))
+	for i, name := range names {
+		candidates[i] = []rune(name[len(prefix):])
+	}
+
+	return candidates, len([]rune(prefix))
+}
+
+// sessionDoc is the output of the shell session command
+type sessionDoc struct {
+	SessionID string `json:"sessionID" yaml:"sessionID"`
+	Server    string `json:"server" yaml:"server"`
+	State     string `json:"state" yaml:"state"`
+}
+
+func (d *sessionDoc) printTable(w io.Writer) {
+	fmt.Fprintf(w, "SessionID:  %s\n", d.SessionID)
+	fmt.Fprintf(w, "Server:     %s\n", d.Server)
+	fmt.Fprintf(w, "State:      %s\n", d.State)
+}
+
+func (d *sessionDoc) printPlain(w io.Writer) {
+	fmt.Fprintln(w, d.SessionID)
+}
@@ -0,0 +1,382 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/benzimu/zkcmd/common/snapshot"
+	"github.com/benzimu/zkcmd/common/zookeeper"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	snapshotZxid   int64
+	snapshotLogDir string
+
+	txnsFromZxid int64
+	txnsToZxid   int64
+	txnsSession  int64
+	txnsOps      []string
+	txnsPath     string
+)
+
+func newCmdSnapshot() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Read the snapshots and transaction logs of the zookeeper data dir offline",
+		Long: `Read the snapshot.* and log.* files of the zookeeper data dir offline, the source is a snapshot file
+  or the data dir (or its version-2 sub directory). For the data dir, the latest snapshot is loaded and
+  the transaction logs after it are applied, or the tree is reconstructed at --zxid. The logs are
+  read from --log-dir if they are in another dir, like the dataLogDir of the server. The gzip and
+  snappy snapshots are supported.`,
+	}
+
+	cmd.AddCommand(newCmdSnapshotLs())
+	cmd.AddCommand(newCmdSnapshotGet())
+	cmd.AddCommand(newCmdSnapshotTree())
+	cmd.AddCommand(newCmdSnapshotExport())
+	cmd.AddCommand(newCmdSnapshotTxns())
+
+	return cmd
+}
+
+// addSnapshotLoadFlags add the flags of the zxid to reconstruct the tree and
+// the dir of the transaction logs
+func addSnapshotLoadFlags(cmd *cobra.Command) {
+	cmd.Flags().Int64VarP(&snapshotZxid, "zxid", "z", 0, "reconstruct the tree at the zxid by applying the transaction logs, like 0x100000123. (default 0, the latest)")
+	cmd.Flags().StringVarP(&snapshotLogDir, "log-dir", "", "", "the dir of the transaction logs, like the dataLogDir of the server, the logs are applied to the snapshot file too. (default the dir of the source)")
+}
+
+func newCmdSnapshotLs() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ls [flags] source [path]",
+		Short: "List the children of the znode in the snapshot, the path default: /",
+		Example: `  zkcmd snapshot ls /var/lib/zookeeper /app
+  zkcmd snapshot ls /var/lib/zookeeper/version-2/snapshot.100000000 /app -s`,
+		Args: cobra.RangeArgs(1, 2),
+		Run:  cmdRunSnapshotLs,
+	}
+
+	cmd.Flags().BoolVarP(&isStat, "stat", "s", false, "znode stat info")
+	addSnapshotLoadFlags(cmd)
+
+	return cmd
+}
+
+func newCmdSnapshotGet() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get [flags] source path",
+		Short: "Get the data of the znode in the snapshot",
+		Example: `  zkcmd snapshot get /var/lib/zookeeper /app/config
+  zkcmd snapshot get /var/lib/zookeeper /app/config --zxid 0x100000123 -s`,
+		Args: cobra.ExactArgs(2),
+		Run:  cmdRunSnapshotGet,
+	}
+
+	cmd.Flags().BoolVarP(&isStat, "stat", "s", false, "znode stat info")
+	addSnapshotLoadFlags(cmd)
+	addDecodeFlags(cmd)
+
+	return cmd
+}
+
+func newCmdSnapshotTree() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tree [flags] source [path]",
+		Short: "Print the znode hierarchy of the snapshot, the path default: /",
+		Example: `  zkcmd snapshot tree /var/lib/zookeeper /app --max-depth 2 --size
+  zkcmd snapshot tree /var/lib/zookeeper --zxid 0x100000123 -o json`,
+		Args: cobra.RangeArgs(1, 2),
+		Run:  cmdRunSnapshotTree,
+	}
+
+	cmd.Flags().BoolVarP(&treeSize, "size", "", false, "show the data length")
+	cmd.Flags().BoolVarP(&treeChildren, "children", "", false, "show the number of children")
+	addSnapshotLoadFlags(cmd)
+	addWalkFilterFlags(cmd)
+
+	return cmd
+}
+
+func newCmdSnapshotExport() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export [flags] source [path]",
+		Short: "Export the znode and all its descendants of the snapshot to a dump file of znode import, the path default: /",
+		Example: `  zkcmd snapshot export /var/lib/zookeeper /app -f app.json
+  zkcmd snapshot export /var/lib/zookeeper / --zxid 0x100000123 -f before.yaml`,
+		Args: cobra.RangeArgs(1, 2),
+		Run:  cmdRunSnapshotExport,
+	}
+
+	cmd.Flags().StringVarP(&dumpFile, "file", "f", "", "the dump file, format by the file extension: .json, .yaml or .yml. (default stdout, format by --output)")
+	addSnapshotLoadFlags(cmd)
+	addWalkFilterFlags(cmd)
+
+	return cmd
+}
+
+func newCmdSnapshotTxns() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "txns [flags] source...",
+		Short: "Dump the transactions of the transaction logs",
+		Long: `Dump the transactions of the log.* files, the source is a log file or the data dir. The ops of
+  multi are dumped as multi/<op>, and --op and --path match them too.`,
+		Example: `  zkcmd snapshot txns /var/lib/zookeeper
+  zkcmd snapshot txns /var/lib/zookeeper/version-2/log.100000001 --from-zxid 0x100000100 --to-zxid 0x100000200
+  zkcmd snapshot txns /var/lib/zookeeper --session 0x100000123 --op create,delete --path '/app/*'`,
+		Args: cobra.MinimumNArgs(1),
+		Run:  cmdRunSnapshotTxns,
+	}
+
+	cmd.Flags().Int64VarP(&txnsFromZxid, "from-zxid", "", 0, "only the transactions from the zxid")
+	cmd.Flags().Int64VarP(&txnsToZxid, "to-zxid", "", 0, "only the transactions until the zxid. (default 0, no limit)")
+	cmd.Flags().Int64VarP(&txnsSession, "session", "", 0, "only the transactions of the session id")
+	cmd.Flags().StringSliceVarP(&txnsOps, "op", "", nil, "only the transactions of the ops, like: create, delete, setData, setACL, multi, createSession, closeSession")
+	cmd.Flags().StringVarP(&txnsPath, "path", "", "", `only the transactions of the paths matched by the glob pattern, the pattern starting with "/" matches the path, otherwise the znode name`)
+
+	return cmd
+}
+
+// loadSnapshot load the data tree of the source at --zxid, the path default: /
+func loadSnapshot(args []string) (*snapshot.DataTree, string) {
+	t, err := snapshot.Load(args[0], snapshotLogDir, snapshotZxid)
+	checkError(err)
+
+	if t.MissingTxns {
+		fmt.Fprintf(os.Stderr, "Warning: no transaction log covers the snapshot 0x%x, the transactions after it may be missing\n", t.Zxid)
+	}
+
+	p := "/"
+	if len(args) > 1 {
+		p = args[1]
+	}
+	checkError(zookeeper.ValidatePath(p, false))
+
+	return t, p
+}
+
+func cmdRunSnapshotLs(cmd *cobra.Command, args []string) {
+	t, p := loadSnapshot(args)
+
+	doc := &znodeChildrenDoc{
+		Path:     p,
+		Children: make([]znodeChild, 0),
+	}
+
+	err := t.Walk(p, zookeeper.WalkOptions{MaxDepth: 1}, func(n *zookeeper.WalkNode) error {
+		if n.Depth == 0 {
+			if isStat {
+				doc.Stat = newZnodeStat(n.Stat)
+			}
+			return nil
+		}
+
+		doc.Children = append(doc.Children, znodeChild{Path: n.Path, NumChildren: n.Stat.NumChildren})
+		return nil
+	})
+	checkError(err)
+
+	printOutput(doc)
+}
+
+func cmdRunSnapshotGet(cmd *cobra.Command, args []string) {
+	t, p := loadSnapshot(args)
+
+	d, stat, err := t.Get(p)
+	checkError(err)
+
+	doc := newZnodeDataDoc(p, d, stat)
+	doc.setDecoded(decodeData(p, d))
+	if isStat {
+		doc.Stat = newZnodeStat(stat)
+	}
+
+	printOutput(doc)
+}
+
+func cmdRunSnapshotTree(cmd *cobra.Command, args []string) {
+	t, p := loadSnapshot(args)
+
+	b := newTreeBuilder()
+	err := t.Walk(p, walkOptions(), b.add)
+	checkError(err)
+
+	printOutput(b.root)
+}
+
+func cmdRunSnapshotExport(cmd *cobra.Command, args []string) {
+	t, p := loadSnapshot(args)
+
+	d, err := t.Export(p, walkOptions())
+	checkError(err)
+
+	if dumpFile == "" || dumpFile == "-" {
+		writeDump(os.Stdout, d, outputFormat)
+		return
+	}
+
+	format := dumpFormat(dumpFile)
+
+	f, err := os.Create(dumpFile)
+	checkError(err)
+	defer f.Close()
+
+	writeDump(f, d, format)
+
+	fmt.Printf("Exported %d znodes of zxid %#x to %s\n", len(d.Znodes), t.Zxid, dumpFile)
+}
+
+func cmdRunSnapshotTxns(cmd *cobra.Command, args []string) {
+	var logs []string
+	for _, source := range args {
+		l, err := snapshot.TxnLogs(source)
+		checkError(err)
+
+		logs = append(logs, l...)
+	}
+
+	doc := &txnsDoc{Txns: make([]txnRow, 0)}
+
+	err := snapshot.ReadTxns(logs, func(txn *snapshot.Txn) error {
+		if txn.Zxid < txnsFromZxid {
+			return nil
+		}
+		if txnsToZxid != 0 && txn.Zxid > txnsToZxid {
+			return errStopTxns
+		}
+		if txnsSession != 0 && txn.Session != txnsSession {
+			return nil
+		}
+
+		rows := newTxnRows(txn)
+		for _, r := range rows {
+			if matchTxnRow(r) {
+				doc.Txns = append(doc.Txns, rows...)
+				break
+			}
+		}
+
+		return nil
+	})
+	if err != errStopTxns {
+		checkError(err)
+	}
+
+	printOutput(doc)
+}
+
+// errStopTxns stop reading the transactions after --to-zxid
+var errStopTxns = errors.New("stop")
+
+// matchTxnRow report whether the row matches --op and --path
+func matchTxnRow(r txnRow) bool {
+	if len(txnsOps) > 0 {
+		op := r.Op[strings.LastIndex(r.Op, "/")+1:]
+
+		matched := false
+		for _, o := range txnsOps {
+			if strings.EqualFold(o, op) || strings.EqualFold(o, r.Op) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return txnsPath == "" || (r.Path != "" && zookeeper.MatchPattern(txnsPath, r.Path))
+}
+
+// newTxnRows return the row of the transaction, and the rows of the multi ops
+func newTxnRows(txn *snapshot.Txn) []txnRow {
+	rows := []txnRow{newTxnRow(txn, txn.Op())}
+	for _, op := range txn.Ops {
+		rows = append(rows, newTxnRow(op, txn.Op()+"/"+op.Op()))
+	}
+
+	return rows
+}
+
+func newTxnRow(txn *snapshot.Txn, op string) txnRow {
+	r := txnRow{
+		Zxid:    txn.Zxid,
+		Time:    time.UnixMilli(txn.Time),
+		Session: txn.Session,
+		Cxid:    txn.Cxid,
+		Op:      op,
+		Path:    txn.Path,
+	}
+
+	var detail []string
+	switch txn.Op() {
+	case "create", "create2", "createContainer", "createTTL":
+		detail = append(detail, fmt.Sprintf("%d bytes", len(txn.Data)), "acl="+zookeeper.FormatACLs(txn.ACL))
+		if txn.Ephemeral {
+			detail = append(detail, "ephemeral")
+		}
+		if txn.TTL != 0 {
+			detail = append(detail, fmt.Sprintf("ttl=%dms", txn.TTL))
+		}
+	case "setData", "reconfig":
+		detail = append(detail, fmt.Sprintf("%d bytes", len(txn.Data)), fmt.Sprintf("version=%d", txn.Version))
+	case "setACL":
+		detail = append(detail, "acl="+zookeeper.FormatACLs(txn.ACL), fmt.Sprintf("aversion=%d", txn.Version))
+	case "check":
+		detail = append(detail, fmt.Sprintf("version=%d", txn.Version))
+	case "createSession":
+		detail = append(detail, fmt.Sprintf("timeout=%dms", txn.Timeout))
+	case "error":
+		detail = append(detail, fmt.Sprintf("err=%d", txn.Err))
+	case "multi":
+		detail = append(detail, fmt.Sprintf("%d ops", len(txn.Ops)))
+		if txn.Failed() {
+			detail = append(detail, "failed")
+		}
+	}
+	r.Detail = strings.Join(detail, " ")
+
+	if txn.Data != nil {
+		r.Data, r.DataEncoding = zookeeper.EncodeData(txn.Data)
+	}
+
+	return r
+}
+
+// txnsDoc is the output of snapshot txns
+type txnsDoc struct {
+	Txns []txnRow `json:"txns" yaml:"txns"`
+}
+
+type txnRow struct {
+	Zxid         int64     `json:"zxid" yaml:"zxid"`
+	Time         time.Time `json:"time" yaml:"time"`
+	Session      int64     `json:"session" yaml:"session"`
+	Cxid         int32     `json:"cxid" yaml:"cxid"`
+	Op           string    `json:"op" yaml:"op"`
+	Path         string    `json:"path,omitempty" yaml:"path,omitempty"`
+	Detail       string    `json:"detail,omitempty" yaml:"detail,omitempty"`
+	Data         string    `json:"data,omitempty" yaml:"data,omitempty"`
+	DataEncoding string    `json:"dataEncoding,omitempty" yaml:"dataEncoding,omitempty"`
+}
+
+func (d *txnsDoc) printTable(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 6, 4, 3, ' ', 0)
+	fmt.Fprintf(tw, "Zxid\tTime\tSession\tCxid\tOp\tPath\tDetail\t\n")
+	for _, r := range d.Txns {
+		fmt.Fprintf(tw, "%#x\t%v\t%#x\t%d\t%s\t%s\t%s\t\n",
+			r.Zxid, r.Time.Format("2006-01-02 15:04:05.000"), r.Session, r.Cxid, r.Op, r.Path, r.Detail)
+	}
+	tw.Flush()
+}
+
+func (d *txnsDoc) printPlain(w io.Writer) {
+	for _, r := range d.Txns {
+		fmt.Fprintf(w, "%#x\t%#x\t%s\t%s\n", r.Zxid, r.Session, r.Op, r.Path)
+	}
+}
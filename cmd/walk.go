@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"github.com/benzimu/zkcmd/common/zookeeper"
+	"github.com/spf13/cobra"
+)
+
+var (
+	walkConcurrency int
+	walkMaxDepth    int
+	walkInclude     []string
+	walkExclude     []string
+)
+
+// addWalkFlags add the flags of walking the znode tree
+func addWalkFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVarP(&walkConcurrency, "concurrency", "", zookeeper.DefaultWalkConcurrency, "the number of concurrent requests to walk the znode tree")
+	addWalkFilterFlags(cmd)
+}
+
+// addWalkFilterFlags add the flags of walking without the concurrency
+func addWalkFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVarP(&walkMaxDepth, "max-depth", "", 0, "the max depth to walk, the children of the path are 1. (default 0, no limit)")
+	cmd.Flags().StringSliceVarP(&walkInclude, "include", "", nil, `only the znodes matched by the glob patterns, the pattern starting with "/" matches the path, otherwise the znode name`)
+	cmd.Flags().StringSliceVarP(&walkExclude, "exclude", "", nil, "skip the znodes matched by the glob patterns and their descendants")
+}
+
+func walkOptions() zookeeper.WalkOptions {
+	return zookeeper.WalkOptions{
+		Concurrency: walkConcurrency,
+		MaxDepth:    walkMaxDepth,
+		Include:     walkInclude,
+		Exclude:     walkExclude,
+	}
+}
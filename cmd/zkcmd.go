@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path"
+	"strings"
 
 	"github.com/benzimu/zkcmd/common/zookeeper"
 
@@ -13,10 +15,14 @@ import (
 )
 
 var (
-	cfgFile string
-	verbose bool
+	cfgFile     string
+	contextName string
+	verbose     bool
 
 	zkcli *zookeeper.Client
+
+	// errorExitCode is the exit code of checkError
+	errorExitCode = 1
 )
 
 func Execute() {
@@ -34,11 +40,29 @@ func newRootCommand() *cobra.Command {
 	}
 
 	cmd.PersistentFlags().StringVarP(&cfgFile, "config", "", "", `config file. (default "$HOME/.zkcmd.yaml")`)
+	cmd.PersistentFlags().StringVarP(&contextName, "context", "", "", "the config context to use. (default current context of config file)")
 	cmd.PersistentFlags().StringSliceVarP(&zkcmdConf.Server, "server", "", nil, fmt.Sprintf("zookeeper server address, multiple addresses with a comma. (default [%s])", defaultServer))
 	cmd.PersistentFlags().StringSliceVarP(&zkcmdConf.ACL, "acl", "", nil, `zookeeper cluster ACL, multiple ACL with a comma. EX: "user:password"`)
+	cmd.PersistentFlags().BoolVarP(&zkcmdConf.TLS.Enabled, "tls", "", false, "connect zookeeper by TLS, it is enabled if any TLS flag is set")
+	cmd.PersistentFlags().StringVarP(&zkcmdConf.TLS.Cert, "tls-cert", "", "", "TLS client certificate file")
+	cmd.PersistentFlags().StringVarP(&zkcmdConf.TLS.Key, "tls-key", "", "", "TLS client key file")
+	cmd.PersistentFlags().StringVarP(&zkcmdConf.TLS.CA, "tls-ca", "", "", "TLS CA bundle file to verify the server certificate. (default system CAs)")
+	cmd.PersistentFlags().StringVarP(&zkcmdConf.TLS.ServerName, "tls-server-name", "", "", "the server name to verify the server certificate. (default host of the server address)")
+	cmd.PersistentFlags().BoolVarP(&zkcmdConf.TLS.InsecureSkipVerify, "tls-insecure", "", false, "skip verifying the server certificate")
+	cmd.PersistentFlags().StringVarP(&zkcmdConf.SASL.User, "sasl-user", "", "", "SASL DIGEST-MD5 user")
+	cmd.PersistentFlags().StringVarP(&zkcmdConf.SASL.Password, "sasl-password", "", "", "SASL DIGEST-MD5 password")
 	cmd.PersistentFlags().BoolVarP(&verbose, "verbose", "V", false, "whether to print verbose log")
+	cmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputTable, "output format, one of: table, json, yaml, plain")
 	_ = viper.BindPFlag("server", cmd.PersistentFlags().Lookup("server"))
 	_ = viper.BindPFlag("acl", cmd.PersistentFlags().Lookup("acl"))
+	_ = viper.BindPFlag("tls.enabled", cmd.PersistentFlags().Lookup("tls"))
+	_ = viper.BindPFlag("tls.cert", cmd.PersistentFlags().Lookup("tls-cert"))
+	_ = viper.BindPFlag("tls.key", cmd.PersistentFlags().Lookup("tls-key"))
+	_ = viper.BindPFlag("tls.ca", cmd.PersistentFlags().Lookup("tls-ca"))
+	_ = viper.BindPFlag("tls.serverName", cmd.PersistentFlags().Lookup("tls-server-name"))
+	_ = viper.BindPFlag("tls.insecureSkipVerify", cmd.PersistentFlags().Lookup("tls-insecure"))
+	_ = viper.BindPFlag("sasl.user", cmd.PersistentFlags().Lookup("sasl-user"))
+	_ = viper.BindPFlag("sasl.password", cmd.PersistentFlags().Lookup("sasl-password"))
 	viper.SetDefault("server", []string{defaultServer})
 
 	cobra.OnInitialize(initConfig)
@@ -47,6 +71,12 @@ func newRootCommand() *cobra.Command {
 	cmd.AddCommand(newCmdACL())
 	cmd.AddCommand(newCmdAdminServer())
 	cmd.AddCommand(newCmdConfig())
+	cmd.AddCommand(newCmdElection())
+	cmd.AddCommand(newCmdExporter())
+	cmd.AddCommand(newCmdHealth())
+	cmd.AddCommand(newCmdLock())
+	cmd.AddCommand(newCmdShell())
+	cmd.AddCommand(newCmdSnapshot())
 	cmd.AddCommand(newCmdVersion())
 	cmd.AddCommand(newCmdZnode())
 
@@ -55,6 +85,13 @@ func newRootCommand() *cobra.Command {
 
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
+	checkError(validateOutputFormat())
+
+	// the config is read when the shell started
+	if inShell {
+		return
+	}
+
 	if cfgFile != "" {
 		// Use config file from the flag.
 		viper.SetConfigFile(cfgFile)
@@ -77,17 +114,50 @@ func initConfig() {
 
 		err = viper.Unmarshal(zkcmdConf)
 		checkError(err)
+
+		if contextName == "" {
+			contextName = zkcmdConf.CurrentContext
+		}
+
+		if verbose && contextName != "" {
+			log.Println("Using config context:", contextName)
+		}
+
+		checkError(useContext(contextName))
+		return
+	}
+
+	if contextName != "" && contextName != defaultContext {
+		checkError(errors.Errorf("context %s not found, the config file does not exist", contextName))
 	}
 }
 
-// newZKClient new zookeeper client, if server non-empty
+// newZKClient new zookeeper client by the cluster config of the current context
 func newZKClient() *zookeeper.Client {
-	zkcli, err := zookeeper.New(zkcmdConf.Server)
+	return newZKClientWith(currentCluster())
+}
+
+// newZKClientWith new zookeeper client by the cluster config, the TLS and SASL
+// are used if configured, and the digest ACL are added as auth
+func newZKClientWith(cluster *zkcmdContext) *zookeeper.Client {
+	var opts []zookeeper.Option
+	if cluster.TLS != nil && cluster.TLS.enabled() {
+		t := cluster.TLS
+		cfg, err := zookeeper.NewTLSConfig(t.Cert, t.Key, t.CA, t.ServerName, t.InsecureSkipVerify)
+		checkError(err)
+
+		opts = append(opts, zookeeper.WithTLS(cfg))
+	}
+	if cluster.SASL != nil && cluster.SASL.User != "" {
+		opts = append(opts, zookeeper.WithSASL(cluster.SASL.User, cluster.SASL.Password))
+	}
+
+	zkcli, err := zookeeper.New(cluster.Server, opts...)
 	checkError(errors.Wrap(err, "new zk client"))
 
 	zkcli.EnableLogging(verbose)
 
-	for _, a := range zkcmdConf.ACL {
+	for _, a := range cluster.ACL {
 		err = zkcli.AddAuth("digest", []byte(a))
 		checkError(errors.Wrap(err, "add auth error"))
 	}
@@ -95,9 +165,50 @@ func newZKClient() *zookeeper.Client {
 	return zkcli
 }
 
+// clusterClient return the client of the context or the servers, it is the
+// current client if neither the context nor the servers is specified
+func clusterClient(ctxName string, servers []string) (*zookeeper.Client, []string) {
+	if ctxName == "" && len(servers) == 0 {
+		return zkcli, zkcmdConf.Server
+	}
+
+	cluster := currentCluster()
+	if ctxName != "" {
+		var err error
+		cluster, err = contextCluster(ctxName)
+		checkError(err)
+	}
+
+	if len(servers) > 0 {
+		cluster.Server = servers
+	}
+
+	return newZKClientWith(cluster), cluster.Server
+}
+
+// connectZK connect zookeeper if not connected, the shell shares one client for all commands
+func connectZK(cmd *cobra.Command, args []string) {
+	if zkcli == nil {
+		zkcli = newZKClient()
+	}
+}
+
+// znodePath return the absolute path, relative path is relative to the shell working directory
+func znodePath(p string) string {
+	if strings.HasPrefix(p, "/") {
+		return p
+	}
+
+	return path.Join(workDir, p)
+}
+
 func checkError(err error) {
 	if err != nil {
+		if inShell {
+			panic(shellError{err})
+		}
+
 		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(errorExitCode)
 	}
 }
@@ -3,11 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"path/filepath"
-	"sort"
 	"strconv"
-	"text/tabwriter"
-	"time"
 
 	"github.com/benzimu/zkcmd/common/zookeeper"
 	"github.com/go-zookeeper/zk"
@@ -23,15 +19,20 @@ var (
 
 	ephemeral bool
 	sequence  bool
+
+	dataFile string
+)
+
+const (
+	// defaultJuteMaxBuffer is the default jute.maxbuffer of the server, 0xfffff bytes
+	defaultJuteMaxBuffer = 1048575
 )
 
 func newCmdZnode() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "znode",
-		Short: "Znode command",
-		PersistentPreRun: func(cmd *cobra.Command, args []string) {
-			zkcli = newZKClient()
-		},
+		Use:              "znode",
+		Short:            "Znode command",
+		PersistentPreRun: connectZK,
 	}
 
 	cmd.AddCommand(newCmdZnodeLs())
@@ -40,6 +41,18 @@ func newCmdZnode() *cobra.Command {
 	cmd.AddCommand(newCmdZnodeDelete())
 	cmd.AddCommand(newCmdZnodeSet())
 	cmd.AddCommand(newCmdZnodeCreate())
+	cmd.AddCommand(newCmdZnodeEdit())
+	cmd.AddCommand(newCmdZnodeFind())
+	cmd.AddCommand(newCmdZnodeGrep())
+	cmd.AddCommand(newCmdZnodeTree())
+	cmd.AddCommand(newCmdZnodeDu())
+	cmd.AddCommand(newCmdZnodeWatch())
+	cmd.AddCommand(newCmdZnodeExport())
+	cmd.AddCommand(newCmdZnodeImport())
+	cmd.AddCommand(newCmdZnodeTxn())
+	cmd.AddCommand(newCmdZnodeDiff())
+	cmd.AddCommand(newCmdZnodeCopy())
+	cmd.AddCommand(newCmdZnodeMove())
 
 	return cmd
 }
@@ -47,7 +60,7 @@ func newCmdZnode() *cobra.Command {
 func newCmdZnodeLs() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "ls [flags] [path]",
-		Short: "List znode children, the path default: /",
+		Short: "List znode children, the path default: / or the shell working directory",
 		Args:  cobra.MinimumNArgs(0),
 		Run:   cmdRunZnodeLs,
 	}
@@ -60,11 +73,13 @@ func newCmdZnodeLs() *cobra.Command {
 func newCmdZnodeLsn() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "ll [flags] [path]",
-		Short: "List all znode has no children, the path default: /",
+		Short: "List all znode has no children, the path default: / or the shell working directory",
 		Args:  cobra.MinimumNArgs(0),
 		Run:   cmdRunZnodeLsn,
 	}
 
+	addWalkFlags(cmd)
+
 	return cmd
 }
 
@@ -72,39 +87,57 @@ func newCmdZnodeGet() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "get [flags] path",
 		Short: "Get znode value",
-		Args:  cobra.ExactArgs(1),
-		Run:   cmdRunZnodeGet,
+		Long: `Get znode value, the value is decoded by the codecs of --decode. By default the gzip, zstd,
+  snappy framing and Java serialized String are detected and decoded, then JSON is indented,
+  the other binary data is shown as hex dump.`,
+		Example: `  zkcmd znode get /test
+	  zkcmd znode get /test --decode raw -o plain > data.bin
+	  zkcmd znode get /test --decode snappy,json
+	  zkcmd znode get /test --decode protobuf --proto-descriptor app.pb --proto-type app.Config`,
+		Args: cobra.ExactArgs(1),
+		Run:  cmdRunZnodeGet,
 	}
 
 	cmd.Flags().BoolVarP(&isStat, "stat", "s", false, "znode stat info")
+	addDecodeFlags(cmd)
 
 	return cmd
 }
 
 func newCmdZnodeSet() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "set [flags] path data",
-		Short: "Update znode value",
-		Args:  cobra.ExactArgs(2),
-		Run:   cmdRunZnodeSet,
+		Use:   "set [flags] path [data|-]",
+		Short: "Update znode value, the data is read from stdin if it is -",
+		Example: `  zkcmd znode set /test 'data'
+	  zkcmd znode set /test -F config.json
+	  cat config.json | zkcmd znode set /test -
+	  zkcmd znode set /test '{"a": 1}' --encode gzip,json
+	  zkcmd znode set /test 'deadbeef' --encode hex
+	  zkcmd znode set /test '{"name": "a"}' --encode protobuf --proto-descriptor app.pb --proto-type app.Config`,
+		Args: cobra.RangeArgs(1, 2),
+		Run:  cmdRunZnodeSet,
 	}
 
 	cmd.Flags().BoolVarP(&setCreate, "create", "c", false, "will create znode if znode does not exist, but does not directly create multi-level znode")
 	cmd.Flags().BoolVarP(&force, "force", "f", false, "will force create multi-level znode if znode does not exist")
 	cmd.Flags().StringVarP(&dataVersion, "version", "v", "", "znode data version")
 	cmd.Flags().BoolVarP(&isStat, "stat", "s", false, "znode stat info")
+	cmd.Flags().StringVarP(&dataFile, "file", "F", "", `read the data from the file, "-" is stdin`)
+	addEncodeFlags(cmd)
 
 	return cmd
 }
 
 func newCmdZnodeCreate() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "create [flags] path [data] [acl]",
-		Short: "Create znode",
+		Use:   "create [flags] path [data|-] [acl]",
+		Short: "Create znode, the data is read from stdin if it is -",
 		Example: `  zkcmd znode create /test
 	  zkcmd znode create -f /test/1/2
 	  zkcmd znode create -f /test/1/2 'data'
-	  zkcmd znode create -f /test/1/2 'data' world:anyone:cdrwa`,
+	  zkcmd znode create -f /test/1/2 'data' world:anyone:cdrwa
+	  zkcmd znode create /test -F data.bin world:anyone:cdrwa
+	  zkcmd znode create /test - < data.bin`,
 		Args: cobra.MinimumNArgs(1),
 		Run:  cmdRunZnodeCreate,
 	}
@@ -112,6 +145,8 @@ func newCmdZnodeCreate() *cobra.Command {
 	cmd.Flags().BoolVarP(&force, "force", "f", false, "will force create multi-level znode if znode does not exist")
 	cmd.Flags().BoolVarP(&ephemeral, "ephemeral", "e", false, "create ephemeral znode")
 	cmd.Flags().BoolVarP(&sequence, "sequence", "s", false, "create sequence znode")
+	cmd.Flags().StringVarP(&dataFile, "file", "F", "", `read the data from the file, "-" is stdin, the acl is the second argument`)
+	addEncodeFlags(cmd)
 
 	return cmd
 }
@@ -131,131 +166,148 @@ func newCmdZnodeDelete() *cobra.Command {
 }
 
 func cmdRunZnodeLs(cmd *cobra.Command, args []string) {
-	path := "/"
+	path := workDir
 	if len(args) > 0 {
-		path = args[0]
+		path = znodePath(args[0])
 	}
 
-	cs, stat, err := zkcli.Children(path)
-	checkError(err)
-
-	sort.Strings(cs)
-
-	w := tabwriter.NewWriter(os.Stdout, 6, 4, 3, ' ', 0)
-	fmt.Fprintf(w, "ID\tPath\tChildrenNum\t\n")
-
-	if stat.NumChildren != 0 {
-		for i, c := range cs {
-			p := filepath.Join(path, c)
-			_, stat, err := zkcli.Children(p)
-			checkError(err)
+	doc := &znodeChildrenDoc{
+		Path:     path,
+		Children: make([]znodeChild, 0),
+	}
 
-			fmt.Fprintf(w, "%v\t%v\t%v\t\n", i+1, p, stat.NumChildren)
+	err := zkcli.Walk(path, zookeeper.WalkOptions{MaxDepth: 1}, func(n *zookeeper.WalkNode) error {
+		if n.Depth == 0 {
+			if isStat {
+				doc.Stat = newZnodeStat(n.Stat)
+			}
+			return nil
 		}
-		w.Flush()
-	}
 
-	if isStat {
-		outputStat(stat)
-	}
+		doc.Children = append(doc.Children, znodeChild{Path: n.Path, NumChildren: n.Stat.NumChildren})
+		return nil
+	})
+	checkError(err)
+
+	printOutput(doc)
 }
 
 func cmdRunZnodeLsn(cmd *cobra.Command, args []string) {
-	path := "/"
+	path := workDir
 	if len(args) > 0 {
-		path = args[0]
+		path = znodePath(args[0])
 	}
 
-	_, stat, err := zkcli.Children(path)
-	checkError(err)
+	doc := &znodeLeavesDoc{
+		Path:   path,
+		Znodes: make([]string, 0),
+	}
 
-	w := tabwriter.NewWriter(os.Stdout, 6, 4, 3, ' ', 0)
-	fmt.Fprintf(w, "ID\tPath\t\n")
+	// the table and plain output are printed while walking
+	stream := outputFormat != outputJSON && outputFormat != outputYAML
 
-	if stat.NumChildren != 0 {
-		ns, err := zkcli.GetZnodes(path)
-		checkError(err)
-		for i, n := range ns {
-			fmt.Fprintf(w, "%v\t%v\t\n", i+1, n)
+	err := zkcli.Walk(path, walkOptions(), func(n *zookeeper.WalkNode) error {
+		if n.Depth == 0 || n.Stat.NumChildren != 0 {
+			return nil
+		}
+
+		if !stream {
+			doc.Znodes = append(doc.Znodes, n.Path)
+			return nil
+		}
+
+		if len(doc.Znodes) == 0 && outputFormat != outputPlain {
+			doc.printTableHeader(os.Stdout)
+		}
+		doc.Znodes = append(doc.Znodes, n.Path)
+
+		if outputFormat == outputPlain {
+			fmt.Println(n.Path)
+		} else {
+			doc.printTableRow(os.Stdout, len(doc.Znodes), n.Path)
 		}
-		w.Flush()
+
+		return nil
+	})
+	checkError(err)
+
+	if !stream {
+		printOutput(doc)
 	}
 }
 
 func cmdRunZnodeGet(cmd *cobra.Command, args []string) {
-	d, stat, err := zkcli.Get(args[0])
-	checkError(err)
+	path := znodePath(args[0])
 
-	w := tabwriter.NewWriter(os.Stdout, 6, 4, 3, '\t', 0)
-	fmt.Fprintf(w, "ChildrenNum:\t%v\t\n", stat.NumChildren)
-	fmt.Fprintf(w, "Value:      \t\n%v\t\n", string(d))
-	w.Flush()
+	d, stat, err := zkcli.Get(path)
+	checkError(err)
 
+	doc := newZnodeDataDoc(path, d, stat)
+	doc.setDecoded(decodeData(path, d))
 	if isStat {
-		outputStat(stat)
+		doc.Stat = newZnodeStat(stat)
 	}
+
+	printOutput(doc)
 }
 
 func cmdRunZnodeSet(cmd *cobra.Command, args []string) {
-	path := args[0]
-	data := args[1]
+	path := znodePath(args[0])
+	if len(args) < 2 && dataFile == "" {
+		checkError(errors.New("the data or --file is required"))
+	}
+	data, rest := znodeData(args[1:])
+	if len(rest) > 0 {
+		checkError(errors.Errorf("unexpected arguments: %v", rest))
+	}
 
 	exist, stat, err := zkcli.Exists(path)
 	checkError(err)
 
-	if exist {
+	switch {
+	case exist:
 		version := checkDataVersion(stat.Version)
 
-		_, err = zkcli.Set(path, []byte(data), version)
+		_, err = zkcli.Set(path, data, version)
 		checkError(err)
-		return
-	}
-
-	if force {
+	case force:
 		err = zookeeper.ValidatePath(path, false)
 		checkError(err)
 
-		err = zkcli.ForceCreate(path, []byte(data), 0, zk.WorldACL(zk.PermAll))
+		err = zkcli.ForceCreate(path, data, 0, zk.WorldACL(zk.PermAll))
 		checkError(err)
-		return
-	}
-
-	if setCreate {
-		_, err = zkcli.DefaultCreate(path, []byte(data))
+	case setCreate:
+		_, err = zkcli.DefaultCreate(path, data)
 		checkError(err)
-		return
+	default:
+		checkError(zk.ErrNoNode)
 	}
 
-	exist, stat, err = zkcli.Exists(path)
-	checkError(err)
+	if isStat {
+		_, stat, err = zkcli.Exists(path)
+		checkError(err)
 
-	if exist && isStat {
-		outputStat(stat)
+		printOutput(&znodeStatDoc{Path: path, Stat: newZnodeStat(stat)})
 	}
-
-	checkError(zk.ErrNoNode)
 }
 
 func cmdRunZnodeCreate(cmd *cobra.Command, args []string) {
-	path := args[0]
+	path := znodePath(args[0])
 
-	var data string
-	if len(args) == 2 {
-		data = args[1]
-	}
+	data, rest := znodeData(args[1:])
 
 	// check acl
 	var acl string
-	if len(args) > 2 {
-		data = args[1]
-		acl = args[2]
+	if len(rest) > 1 {
+		checkError(errors.Errorf("unexpected arguments: %v", rest[1:]))
+	}
+	if len(rest) > 0 {
+		acl = rest[0]
 	}
 
 	acls := zk.WorldACL(zk.PermAll)
-	var err error
 	if acl != "" {
-		acls, err = zookeeper.ParseACL(acl)
-		checkError(err)
+		acls = parseACLArg(acl)
 	}
 
 	// check exist
@@ -288,25 +340,61 @@ func cmdRunZnodeCreate(cmd *cobra.Command, args []string) {
 		err = zookeeper.ValidatePath(path, false)
 		checkError(err)
 
-		err = zkcli.ForceCreate(path, []byte(data), flags, acls)
+		err = zkcli.ForceCreate(path, data, flags, acls)
 		checkError(err)
 		return
 	}
 
-	_, err = zkcli.Create(path, []byte(data), flags, acls)
+	_, err = zkcli.Create(path, data, flags, acls)
 	checkError(err)
 }
 
+// znodeData return the data of --file or the first argument, "-" is stdin,
+// and the rest arguments. The data is encoded by --encode and must not exceed
+// the jute.maxbuffer of the server
+func znodeData(args []string) ([]byte, []string) {
+	data := []byte{}
+	switch {
+	case dataFile != "":
+		data = readInputFile(dataFile)
+	case len(args) > 0 && args[0] == "-":
+		data, args = readInputFile("-"), args[1:]
+	case len(args) > 0:
+		data, args = []byte(args[0]), args[1:]
+	}
+
+	data = encodeData(data)
+
+	if max := juteMaxBuffer(); len(data) > max {
+		checkError(errors.Errorf("the data is %d bytes, exceeds jute.maxbuffer %d of the server", len(data), max))
+	}
+
+	return data, args
+}
+
+// juteMaxBuffer return the jute.maxbuffer of the config, the default is
+// 1048575. It is a JVM system property of the server, so it is not reported by
+// the conf command.
+func juteMaxBuffer() int {
+	if zkcmdConf.JuteMaxBuffer > 0 {
+		return zkcmdConf.JuteMaxBuffer
+	}
+
+	return defaultJuteMaxBuffer
+}
+
 func cmdRunZnodeDelete(cmd *cobra.Command, args []string) {
-	exist, stat, err := zkcli.Exists(args[0])
+	path := znodePath(args[0])
+
+	exist, stat, err := zkcli.Exists(path)
 	checkError(err)
 
 	if exist {
 		if force {
-			err = zookeeper.ValidatePath(args[0], false)
+			err = zookeeper.ValidatePath(path, false)
 			checkError(err)
 
-			err = zkcli.ForceDelete(args[0])
+			err = zkcli.ForceDelete(path)
 			checkError(err)
 
 			return
@@ -314,7 +402,7 @@ func cmdRunZnodeDelete(cmd *cobra.Command, args []string) {
 
 		version := checkDataVersion(stat.Version)
 
-		err = zkcli.Delete(args[0], version)
+		err = zkcli.Delete(path, version)
 		checkError(err)
 
 		return
@@ -323,22 +411,6 @@ func cmdRunZnodeDelete(cmd *cobra.Command, args []string) {
 	checkError(zk.ErrNoNode)
 }
 
-func outputStat(stat *zk.Stat) {
-	w := tabwriter.NewWriter(os.Stdout, 6, 4, 3, ' ', 0)
-	fmt.Fprintf(w, "----------\t\n")
-	fmt.Fprintf(w, "Czxid\t%#x\t\n", stat.Czxid)
-	fmt.Fprintf(w, "Mzxid\t%#x\t\n", stat.Mzxid)
-	fmt.Fprintf(w, "Pzxid\t%#x\t\n", stat.Pzxid)
-	fmt.Fprintf(w, "Ctime\t%v\t\n", time.Unix(stat.Ctime/1000, 0))
-	fmt.Fprintf(w, "Mtime\t%v\t\n", time.Unix(stat.Mtime/1000, 0))
-	fmt.Fprintf(w, "DataVersion\t%v\t\n", stat.Version)
-	fmt.Fprintf(w, "Cversion\t%v\t\n", stat.Cversion)
-	fmt.Fprintf(w, "AclVersion\t%v\t\n", stat.Aversion)
-	fmt.Fprintf(w, "EphemeralOwner\t%v\t\n", stat.EphemeralOwner)
-	fmt.Fprintf(w, "DataLength\t%v\t\n", stat.DataLength)
-	w.Flush()
-}
-
 func checkDataVersion(curVersion int32) int32 {
 	if dataVersion != "" {
 		dv, err := strconv.Atoi(dataVersion)
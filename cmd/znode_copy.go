@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/benzimu/zkcmd/common/zookeeper"
+	"github.com/go-zookeeper/zk"
+	"github.com/spf13/cobra"
+)
+
+var (
+	preserveACL bool
+	toContext   string
+	toServer    []string
+)
+
+func newCmdZnodeCopy() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cp [flags] src dst",
+		Short: "Copy znode, and its descendants if recursive, to dst",
+		Example: `  zkcmd znode cp /test /test-copy
+  zkcmd znode cp -r /test /test-copy --preserve-acl
+  zkcmd znode cp -r /app /app --to-context prod --on-conflict overwrite`,
+		Args: cobra.ExactArgs(2),
+		Run:  cmdRunZnodeCopy,
+	}
+
+	addCopyFlags(cmd)
+	cmd.Flags().StringVarP(&onConflict, "on-conflict", "", zookeeper.ConflictFail, "the policy if dst znode exists, one of: fail, skip, overwrite")
+
+	return cmd
+}
+
+func newCmdZnodeMove() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "mv [flags] src dst",
+		Short: "Move znode, and its descendants if recursive, to dst",
+		Long: `Move znode, and its descendants if recursive, to dst.
+  The znodes are created and deleted in one multi request if possible, otherwise in batches,
+  and the committed batches are rolled back if a batch failed. It fails if dst exists.`,
+		Example: `  zkcmd znode mv /test /test-new
+  zkcmd znode mv -r /app/config /app/config-old --preserve-acl
+  zkcmd znode mv -r /app /app --to-server 10.0.0.1:2181`,
+		Args: cobra.ExactArgs(2),
+		Run:  cmdRunZnodeMove,
+	}
+
+	addCopyFlags(cmd)
+
+	return cmd
+}
+
+func addCopyFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "copy the descendants too")
+	cmd.Flags().BoolVarP(&preserveACL, "preserve-acl", "p", false, "keep the ACL of src znodes, the dst znodes are world:anyone:cdrwa by default")
+	cmd.Flags().StringVarP(&importACL, "override-acl", "", "", "the ACL of dst znodes, like: world:anyone:cdrwa")
+	cmd.Flags().BoolVarP(&importEphe, "ephemeral", "e", false, "copy the ephemeral znodes as persistent znodes, they are skipped by cp and fail mv by default")
+	cmd.Flags().StringVarP(&toContext, "to-context", "", "", "the config context of dst")
+	cmd.Flags().StringSliceVarP(&toServer, "to-server", "", nil, "zookeeper server address of dst, multiple addresses with a comma")
+	_ = cmd.RegisterFlagCompletionFunc("to-context", completeContextNames)
+}
+
+// copyOptions return the import options of the copy flags
+func copyOptions() zookeeper.ImportOptions {
+	opts := zookeeper.ImportOptions{
+		OnConflict: onConflict,
+		Ephemeral:  importEphe,
+	}
+
+	switch {
+	case importACL != "":
+		acls, err := zookeeper.ParseACL(importACL)
+		checkError(err)
+
+		opts.ACL = acls
+	case !preserveACL:
+		opts.ACL = zk.WorldACL(zk.PermAll)
+	}
+
+	return opts
+}
+
+func cmdRunZnodeCopy(cmd *cobra.Command, args []string) {
+	src, dst := znodePath(args[0]), znodePath(args[1])
+
+	dstcli, _ := clusterClient(toContext, toServer)
+	if dstcli != zkcli {
+		defer dstcli.Close()
+	}
+
+	res, err := zkcli.Copy(dstcli, src, dst, recursive, copyOptions())
+	if res != nil {
+		printOutput(&importResultDoc{
+			Path:    dst,
+			Created: res.Created,
+			Updated: res.Updated,
+			Skipped: res.Skipped,
+		})
+	}
+	checkError(err)
+}
+
+func cmdRunZnodeMove(cmd *cobra.Command, args []string) {
+	src, dst := znodePath(args[0]), znodePath(args[1])
+
+	dstcli, _ := clusterClient(toContext, toServer)
+	if dstcli != zkcli {
+		defer dstcli.Close()
+	}
+
+	res, err := zkcli.Move(dstcli, src, dst, recursive, copyOptions())
+	checkError(err)
+
+	printOutput(&moveResultDoc{
+		From:    src,
+		To:      dst,
+		Created: res.Created,
+		Deleted: res.Deleted,
+		Batches: res.Batches,
+	})
+}
+
+// moveResultDoc is the output of znode mv
+type moveResultDoc struct {
+	From    string   `json:"from" yaml:"from"`
+	To      string   `json:"to" yaml:"to"`
+	Created []string `json:"created" yaml:"created"`
+	Deleted []string `json:"deleted" yaml:"deleted"`
+	Batches int      `json:"batches" yaml:"batches"`
+}
+
+func (d *moveResultDoc) printTable(w io.Writer) {
+	fmt.Fprintf(w, "Moved %d znodes from %s to %s in %d multi requests\n", len(d.Created), d.From, d.To, d.Batches)
+}
+
+func (d *moveResultDoc) printPlain(w io.Writer) {
+	for _, p := range d.Created {
+		fmt.Fprintln(w, p)
+	}
+}
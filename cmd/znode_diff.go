@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/benzimu/zkcmd/common/diff"
+	"github.com/benzimu/zkcmd/common/zookeeper"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffContextA string
+	diffContextB string
+	diffServerA  []string
+	diffServerB  []string
+)
+
+func newCmdZnodeDiff() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff [flags] pathA pathB",
+		Short: "Compare two znode subtrees, exit 1 if they are different and 2 on errors",
+		Long: `Compare the data and ACL of two znode subtrees, the znodes are matched by the paths relative to pathA and pathB.
+  The subtrees can be on different clusters by --context-a/--context-b or --server-a/--server-b,
+  the side without them uses the current cluster. Exit 1 if they are different and 2 on errors like diff(1).`,
+		Example: `  zkcmd znode diff /staging/app /prod/app
+  zkcmd znode diff /app /app --context-a staging --context-b prod
+  zkcmd znode diff /app /app --server-b 10.0.0.1:2181 -o json`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			// exit 2 on the errors of the arguments and connecting too
+			errorExitCode = 2
+			return cobra.ExactArgs(2)(cmd, args)
+		},
+		Run: cmdRunZnodeDiff,
+	}
+	cmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		errorExitCode = 2
+		return err
+	})
+
+	cmd.Flags().StringVarP(&diffContextA, "context-a", "", "", "the config context of pathA")
+	cmd.Flags().StringVarP(&diffContextB, "context-b", "", "", "the config context of pathB")
+	cmd.Flags().StringSliceVarP(&diffServerA, "server-a", "", nil, "zookeeper server address of pathA, multiple addresses with a comma")
+	cmd.Flags().StringSliceVarP(&diffServerB, "server-b", "", nil, "zookeeper server address of pathB, multiple addresses with a comma")
+	addWalkFlags(cmd)
+	_ = cmd.RegisterFlagCompletionFunc("context-a", completeContextNames)
+	_ = cmd.RegisterFlagCompletionFunc("context-b", completeContextNames)
+
+	return cmd
+}
+
+func cmdRunZnodeDiff(cmd *cobra.Command, args []string) {
+	pathA, pathB := znodePath(args[0]), znodePath(args[1])
+
+	cliA, serversA := clusterClient(diffContextA, diffServerA)
+	if cliA != zkcli {
+		defer cliA.Close()
+	}
+
+	cliB, serversB := clusterClient(diffContextB, diffServerB)
+	if cliB != zkcli {
+		defer cliB.Close()
+	}
+
+	dumpA, err := cliA.Export(pathA, walkOptions())
+	checkError(err)
+
+	dumpB, err := cliB.Export(pathB, walkOptions())
+	checkError(err)
+
+	doc := newDiffDoc(
+		diffSide{Server: serversA, Path: pathA},
+		diffSide{Server: serversB, Path: pathB},
+		zookeeper.CompareDumps(dumpA, dumpB),
+	)
+	printOutput(doc)
+
+	// the shell is not exited by the differences
+	if len(doc.Changes) != 0 && !inShell {
+		os.Exit(1)
+	}
+}
+
+type diffSide struct {
+	Server []string `json:"server" yaml:"server"`
+	Path   string   `json:"path" yaml:"path"`
+}
+
+type znodeChangeDoc struct {
+	Path     string `json:"path" yaml:"path"`
+	Type     string `json:"type" yaml:"type"`
+	DataDiff string `json:"dataDiff,omitempty" yaml:"dataDiff,omitempty"`
+	ACLDiff  string `json:"aclDiff,omitempty" yaml:"aclDiff,omitempty"`
+}
+
+// diffDoc is the output of znode diff, the change paths are relative to the
+// paths of both sides, and the root is "/"
+type diffDoc struct {
+	A       diffSide         `json:"a" yaml:"a"`
+	B       diffSide         `json:"b" yaml:"b"`
+	Changes []znodeChangeDoc `json:"changes" yaml:"changes"`
+}
+
+func newDiffDoc(a, b diffSide, changes []zookeeper.ZnodeChange) *diffDoc {
+	d := &diffDoc{A: a, B: b, Changes: make([]znodeChangeDoc, len(changes))}
+
+	for i, c := range changes {
+		nameA, nameB := "a:"+zookeeper.JoinDumpPath(a.Path, c.Path), "b:"+zookeeper.JoinDumpPath(b.Path, c.Path)
+
+		var dataA, dataB, aclA, aclB string
+		if c.A != nil {
+			dataA, aclA = c.A.Data, strings.Join(zookeeper.SortedACLs(c.A.ACL), "\n")
+		}
+		if c.B != nil {
+			dataB, aclB = c.B.Data, strings.Join(zookeeper.SortedACLs(c.B.ACL), "\n")
+		}
+
+		d.Changes[i] = znodeChangeDoc{
+			Path:     c.Path,
+			Type:     c.Type,
+			DataDiff: diff.Unified(nameA, nameB, dataA, dataB, diff.DefaultContext),
+			ACLDiff:  diff.Unified(nameA, nameB, aclA, aclB, diff.DefaultContext),
+		}
+	}
+
+	return d
+}
+
+func (d *diffDoc) printTable(w io.Writer) {
+	var added, removed, modified int
+	for _, c := range d.Changes {
+		switch c.Type {
+		case zookeeper.ChangeAdded:
+			added++
+			fmt.Fprintf(w, "Added:    %s\n", c.Path)
+		case zookeeper.ChangeRemoved:
+			removed++
+			fmt.Fprintf(w, "Removed:  %s\n", c.Path)
+		default:
+			modified++
+			fmt.Fprintf(w, "Modified: %s\n", c.Path)
+		}
+
+		if c.DataDiff != "" {
+			fmt.Fprintf(w, "  Data:\n%s", indent(c.DataDiff, "    "))
+		}
+		if c.ACLDiff != "" {
+			fmt.Fprintf(w, "  ACL:\n%s", indent(c.ACLDiff, "    "))
+		}
+	}
+
+	if len(d.Changes) == 0 {
+		fmt.Fprintf(w, "No differences between %s and %s\n", d.A.Path, d.B.Path)
+		return
+	}
+
+	fmt.Fprintf(w, "%d added, %d removed, %d modified\n", added, removed, modified)
+}
+
+func (d *diffDoc) printPlain(w io.Writer) {
+	status := map[string]string{
+		zookeeper.ChangeAdded:    "A",
+		zookeeper.ChangeRemoved:  "D",
+		zookeeper.ChangeModified: "M",
+	}
+
+	for _, c := range d.Changes {
+		fmt.Fprintf(w, "%s %s\n", status[c.Type], c.Path)
+	}
+}
+
+// indent add the prefix to every line of s
+func indent(s, prefix string) string {
+	lines := strings.SplitAfter(s, "\n")
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = prefix + l
+		}
+	}
+
+	return strings.Join(lines, "")
+}
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/benzimu/zkcmd/common/zookeeper"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	dumpFile   string
+	onConflict string
+	importACL  string
+	importEphe bool
+)
+
+func newCmdZnodeExport() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export [flags] path",
+		Short: "Export znode and all its descendants to a json or yaml file",
+		Example: `  zkcmd znode export /test -f dump.json
+	  zkcmd znode export /test -f dump.yaml
+	  zkcmd znode export /test -o json > dump.json`,
+		Args: cobra.ExactArgs(1),
+		Run:  cmdRunZnodeExport,
+	}
+
+	cmd.Flags().StringVarP(&dumpFile, "file", "f", "", "the dump file, format by the file extension: .json, .yaml or .yml. (default stdout, format by --output)")
+	addWalkFlags(cmd)
+
+	return cmd
+}
+
+func newCmdZnodeImport() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import [flags] [path]",
+		Short: "Import znodes from the dump file under the path, the path default: the exported path",
+		Example: `  zkcmd znode import -f dump.json
+	  zkcmd znode import -f dump.yaml /test-copy
+	  zkcmd znode import -f dump.json --on-conflict overwrite --override-acl world:anyone:cdrwa /test`,
+		Args: cobra.MaximumNArgs(1),
+		Run:  cmdRunZnodeImport,
+	}
+
+	cmd.Flags().StringVarP(&dumpFile, "file", "f", "", `the dump file, "-" is stdin`)
+	cmd.Flags().StringVarP(&onConflict, "on-conflict", "", zookeeper.ConflictFail, "the policy if znode exists, one of: fail, skip, overwrite")
+	cmd.Flags().StringVarP(&importACL, "override-acl", "", "", "override the ACL of the dump, like: world:anyone:cdrwa")
+	cmd.Flags().BoolVarP(&importEphe, "ephemeral", "e", false, "import the ephemeral znodes as persistent znodes, they are skipped by default")
+	_ = cmd.MarkFlagRequired("file")
+
+	return cmd
+}
+
+func cmdRunZnodeExport(cmd *cobra.Command, args []string) {
+	d, err := zkcli.Export(znodePath(args[0]), walkOptions())
+	checkError(err)
+
+	if dumpFile == "" || dumpFile == "-" {
+		writeDump(os.Stdout, d, outputFormat)
+		return
+	}
+
+	format := dumpFormat(dumpFile)
+
+	f, err := os.Create(dumpFile)
+	checkError(err)
+	defer f.Close()
+
+	writeDump(f, d, format)
+
+	fmt.Printf("Exported %d znodes to %s\n", len(d.Znodes), dumpFile)
+}
+
+func cmdRunZnodeImport(cmd *cobra.Command, args []string) {
+	d := readDump(dumpFile)
+
+	target := d.Path
+	if len(args) > 0 {
+		target = znodePath(args[0])
+	}
+
+	opts := zookeeper.ImportOptions{
+		OnConflict: onConflict,
+		Ephemeral:  importEphe,
+	}
+
+	if importACL != "" {
+		acls, err := zookeeper.ParseACL(importACL)
+		checkError(err)
+
+		opts.ACL = acls
+	}
+
+	res, err := zkcli.Import(d, target, opts)
+	if res != nil {
+		printOutput(&importResultDoc{
+			Path:    target,
+			Created: res.Created,
+			Updated: res.Updated,
+			Skipped: res.Skipped,
+		})
+	}
+	checkError(err)
+}
+
+// dumpFormat return the dump format of the file extension, json or yaml
+func dumpFormat(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		return outputJSON
+	case ".yaml", ".yml":
+		return outputYAML
+	}
+
+	checkError(errors.Errorf("unknown dump file format: %s, the extension must be .json, .yaml or .yml", file))
+	return ""
+}
+
+func writeDump(w io.Writer, d *zookeeper.Dump, format string) {
+	if format == outputJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		checkError(enc.Encode(d))
+		return
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	checkError(enc.Encode(d))
+	checkError(enc.Close())
+}
+
+func readDump(file string) *zookeeper.Dump {
+	d := &zookeeper.Dump{}
+	// yaml is a superset of json
+	err := yaml.Unmarshal(readInputFile(file), d)
+	checkError(errors.Wrap(err, "invalid dump file"))
+
+	return d
+}
+
+// readInputFile read the file, "-" is stdin
+func readInputFile(file string) []byte {
+	var (
+		b   []byte
+		err error
+	)
+
+	if file == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(file)
+	}
+	checkError(err)
+
+	return b
+}
+
+// importResultDoc is the output of znode import
+type importResultDoc struct {
+	Path    string   `json:"path" yaml:"path"`
+	Created []string `json:"created" yaml:"created"`
+	Updated []string `json:"updated" yaml:"updated"`
+	Skipped []string `json:"skipped" yaml:"skipped"`
+}
+
+func (d *importResultDoc) printTable(w io.Writer) {
+	fmt.Fprintf(w, "Imported to %s, created: %d, updated: %d, skipped: %d\n",
+		d.Path, len(d.Created), len(d.Updated), len(d.Skipped))
+}
+
+func (d *importResultDoc) printPlain(w io.Writer) {
+	for _, p := range d.Created {
+		fmt.Fprintln(w, p)
+	}
+	for _, p := range d.Updated {
+		fmt.Fprintln(w, p)
+	}
+}
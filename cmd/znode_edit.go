@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/benzimu/zkcmd/common/codec"
+	"github.com/benzimu/zkcmd/common/diff"
+	"github.com/go-zookeeper/zk"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultEditor = "vi"
+
+// the formats of the edited data, they are validated on save
+const (
+	editFormatText = "text"
+	editFormatJSON = "json"
+	editFormatYAML = "yaml"
+)
+
+var editYes bool
+
+func newCmdZnodeEdit() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "edit [flags] path",
+		Short: "Edit znode value in $EDITOR",
+		Long: `Edit znode value in $VISUAL or $EDITOR, default vi. The JSON objects and arrays and the YAML
+  values are validated on save, the diff is shown before writing back with the version of the
+  edited value, so it fails if the znode was changed by others meanwhile. Then your changes
+  can be merged with theirs by a 3-way merge and edited again, the overlapping changes are
+  left in the conflict markers. The compressed and Java serialized values are decoded and
+  encoded back, use --decode to choose the codecs.`,
+		Example: `  zkcmd znode edit /app/config
+	  EDITOR="code -w" zkcmd znode edit /app/config
+	  zkcmd znode edit /app/config --decode gzip,json`,
+		Args: cobra.ExactArgs(1),
+		Run:  cmdRunZnodeEdit,
+	}
+
+	cmd.Flags().BoolVarP(&editYes, "yes", "y", false, "write back without confirming the diff")
+	addDecodeFlags(cmd)
+
+	return cmd
+}
+
+func cmdRunZnodeEdit(cmd *cobra.Command, args []string) {
+	path := znodePath(args[0])
+
+	d, stat, err := zkcli.Get(path)
+	checkError(err)
+
+	chain, text := editCodecs(d)
+	format := editFormat(text)
+
+	reader := bufio.NewReader(os.Stdin)
+	edited, merged := text, false
+	for {
+		edited = editText(path, format, edited)
+		if bytes.Equal(edited, text) {
+			fmt.Println("Edit cancelled, no changes made.")
+			return
+		}
+
+		if merged && diff.HasConflictMarkers(string(edited)) {
+			fmt.Println("The conflict markers are not resolved.")
+			if confirm(reader, "Edit again?") {
+				continue
+			}
+
+			checkError(errors.New("edit cancelled"))
+		}
+
+		if err := validateEditFormat(format, edited); err != nil {
+			fmt.Printf("Invalid %s: %v\n", format, err)
+			if confirm(reader, "Edit again?") {
+				continue
+			}
+
+			checkError(errors.New("edit cancelled"))
+		}
+
+		fmt.Print(diff.Unified(fmt.Sprintf("%s@%d", path, stat.Version), path, string(text), string(edited), diff.DefaultContext))
+		if !editYes && !confirm(reader, "Write back?") {
+			fmt.Println("Edit cancelled.")
+			return
+		}
+
+		data, err := chain.Encode(edited)
+		checkError(err)
+
+		if max := juteMaxBuffer(); len(data) > max {
+			checkError(errors.Errorf("the data is %d bytes, exceeds jute.maxbuffer %d of the server", len(data), max))
+		}
+
+		_, err = zkcli.Set(path, data, stat.Version)
+		if err != zk.ErrBadVersion {
+			checkError(err)
+
+			fmt.Printf("%s updated from version %d\n", path, stat.Version)
+			return
+		}
+
+		// the znode was changed by others, show their changes and re-edit on the current value
+		current, currentStat, err := zkcli.Get(path)
+		checkError(err)
+
+		_, currentText := editCodecs(current)
+		fmt.Printf("%s was changed from version %d to %d by others:\n", path, stat.Version, currentStat.Version)
+		fmt.Print(diff.Unified(fmt.Sprintf("%s@%d", path, stat.Version), fmt.Sprintf("%s@%d", path, currentStat.Version), string(text), string(currentText), diff.DefaultContext))
+
+		if !confirm(reader, "Re-merge your changes on the current value?") {
+			checkError(zk.ErrBadVersion)
+		}
+
+		// merge your changes and theirs from the edited value, the overlapped
+		// changes are left in the conflict markers to resolve in the editor
+		mergedText, conflicts := diff.Merge3(string(text), string(edited), string(currentText),
+			"yours", fmt.Sprintf("%s@%d", path, currentStat.Version))
+		if conflicts > 0 {
+			fmt.Printf("%d conflicts, resolve the conflict markers in the editor\n", conflicts)
+		}
+
+		text, stat = currentText, currentStat
+		edited, merged = []byte(mergedText), true
+	}
+}
+
+// editCodecs return the codecs and the decoded text to edit, the codecs are
+// detected if --decode is auto, the text is kept as it is stored except the
+// compressed and Java serialized containers
+func editCodecs(data []byte) (codec.Chain, []byte) {
+	if decodeCodecs != decodeAuto {
+		chain := parseCodecs(decodeCodecs)
+		text, err := chain.Decode(data)
+		checkError(err)
+
+		return chain, text
+	}
+
+	chain, _ := codec.Detect(data)
+	chain = chain[:len(chain)-1]
+
+	text, err := chain.Decode(data)
+	checkError(err)
+
+	if !utf8.Valid(text) {
+		checkError(errors.New("the value is binary, use --decode to choose the codecs"))
+	}
+
+	return chain, text
+}
+
+// editFormat return the format of the text to validate on save
+func editFormat(text []byte) string {
+	if len(bytes.TrimSpace(text)) == 0 {
+		return editFormatText
+	}
+
+	// only the objects and arrays are validated, the scalars like 1 are text
+	if t := bytes.TrimSpace(text); (t[0] == '{' || t[0] == '[') && json.Valid(t) {
+		return editFormatJSON
+	}
+
+	var v interface{}
+	if err := yaml.Unmarshal(text, &v); err == nil {
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			return editFormatYAML
+		}
+	}
+
+	return editFormatText
+}
+
+func validateEditFormat(format string, text []byte) error {
+	switch format {
+	case editFormatJSON:
+		var v interface{}
+		return json.Unmarshal(text, &v)
+	case editFormatYAML:
+		var v interface{}
+		return yaml.Unmarshal(text, &v)
+	}
+
+	return nil
+}
+
+// editText open the text in the editor and return the edited text, the
+// extension of the temporary file is the format for the syntax highlighting
+func editText(znode, format string, text []byte) []byte {
+	ext := ".txt"
+	if format != editFormatText {
+		ext = "." + format
+	}
+
+	f, err := os.CreateTemp("", "zkcmd-"+strings.NewReplacer("/", "", "*", "").Replace(path.Base(znode))+"-*"+ext)
+	checkError(err)
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(text)
+	checkError(err)
+	checkError(f.Close())
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = defaultEditor
+	}
+
+	args := strings.Fields(editor)
+	c := exec.Command(args[0], append(args[1:], f.Name())...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	checkError(errors.Wrapf(c.Run(), "run editor %s", editor))
+
+	edited, err := os.ReadFile(f.Name())
+	checkError(err)
+
+	return edited
+}
+
+// confirm print the question and return whether the answer is yes
+func confirm(reader *bufio.Reader, question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+
+	answer, err := reader.ReadString('\n')
+	if err != nil {
+		fmt.Println()
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,453 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/benzimu/zkcmd/common/codec"
+	"github.com/benzimu/zkcmd/common/zookeeper"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	findName       []string
+	findRegex      string
+	findData       string
+	findSize       string
+	findVersion    string
+	findCversion   string
+	findAversion   string
+	findChildren   string
+	findCtime      string
+	findMtime      string
+	findEphemeral  bool
+	findPersistent bool
+	findOwner      string
+	findACLSchemes []string
+
+	grepIgnoreCase bool
+	grepFilesOnly  bool
+)
+
+func newCmdZnodeFind() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "find [flags] [path]",
+		Short: "Find the znodes by name, data, stat and ACL, the path default: / or the shell working directory",
+		Long: `Find the path and its descendants matched by all the filters.
+  The numeric ranges are: N, +N (more than N), -N (less than N), N..M, N.. or ..M, the size
+  can end with k or m. The time ranges are: -1h (within 1 hour), +7d (more than 7 days ago), or
+  T1..T2 of RFC3339 or date like 2023-01-02.`,
+		Example: `  zkcmd znode find /brokers --data '10\.0\.0\.1:9092'
+	  zkcmd znode find --name 'lock-*' --ephemeral
+	  zkcmd znode find /app --size +100k --mtime +30d
+	  zkcmd znode find /app --version 0 --children 0 -o plain | xargs -n1 zkcmd znode delete
+	  zkcmd znode find --acl-scheme digest,sasl`,
+		Args: cobra.MaximumNArgs(1),
+		Run:  cmdRunZnodeFind,
+	}
+
+	cmd.Flags().StringSliceVarP(&findName, "name", "", nil, `the glob patterns of znode name, the pattern starting with "/" matches the path`)
+	cmd.Flags().StringVarP(&findRegex, "regex", "", "", "the regexp of znode name")
+	cmd.Flags().StringVarP(&findData, "data", "", "", "the regexp of znode data")
+	cmd.Flags().StringVarP(&findSize, "size", "", "", "the range of data length, like: +1k")
+	cmd.Flags().StringVarP(&findVersion, "version", "", "", "the range of data version")
+	cmd.Flags().StringVarP(&findCversion, "cversion", "", "", "the range of children version")
+	cmd.Flags().StringVarP(&findAversion, "aversion", "", "", "the range of ACL version")
+	cmd.Flags().StringVarP(&findChildren, "children", "", "", "the range of the number of children")
+	cmd.Flags().StringVarP(&findCtime, "ctime", "", "", "the range of created time, like: -1h")
+	cmd.Flags().StringVarP(&findMtime, "mtime", "", "", "the range of modified time, like: +7d")
+	cmd.Flags().BoolVarP(&findEphemeral, "ephemeral", "e", false, "only the ephemeral znodes")
+	cmd.Flags().BoolVarP(&findPersistent, "persistent", "", false, "only the persistent znodes")
+	cmd.Flags().StringVarP(&findOwner, "owner", "", "", "only the ephemeral znodes of the session id, like: 0x100000123")
+	cmd.Flags().StringSliceVarP(&findACLSchemes, "acl-scheme", "", nil, "only the znodes with an ACL of the schemes, like: world, digest, ip, x509, sasl")
+	addWalkFlags(cmd)
+
+	return cmd
+}
+
+func newCmdZnodeGrep() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "grep [flags] pattern [path]",
+		Short: "Print the data lines matched by the regexp with their znode paths, the path default: / or the shell working directory",
+		Long: `Print the data lines of the path and its descendants matched by the regexp.
+  The data is decoded by the codecs of --decode, by default the compressed data is decompressed
+  and JSON is indented, so every JSON field is a line.`,
+		Example: `  zkcmd znode grep 10.0.0.1 /brokers
+	  zkcmd znode grep -i 'bootstrap\.servers' /app -l
+	  zkcmd znode grep '"port": 2181' /app --include '*.json'`,
+		Args: cobra.RangeArgs(1, 2),
+		Run:  cmdRunZnodeGrep,
+	}
+
+	cmd.Flags().BoolVarP(&grepIgnoreCase, "ignore-case", "i", false, "ignore case of the pattern")
+	cmd.Flags().BoolVarP(&grepFilesOnly, "files-with-matches", "l", false, "only print the paths of the matched znodes")
+	addWalkFlags(cmd)
+	addDecodeFlags(cmd)
+
+	return cmd
+}
+
+func cmdRunZnodeFind(cmd *cobra.Command, args []string) {
+	path := workDir
+	if len(args) > 0 {
+		path = znodePath(args[0])
+	}
+
+	doc := &findDoc{Path: path, Znodes: make([]findZnode, 0)}
+
+	err := zkcli.Find(path, walkOptions(), findFilter(), func(n *zookeeper.WalkNode) error {
+		if outputFormat == outputPlain {
+			fmt.Println(n.Path)
+			return nil
+		}
+
+		doc.Znodes = append(doc.Znodes, findZnode{Path: n.Path, Stat: newZnodeStat(n.Stat)})
+		return nil
+	})
+	checkError(err)
+
+	if outputFormat != outputPlain {
+		printOutput(doc)
+	}
+}
+
+// findFilter return the filter of the find flags
+func findFilter() zookeeper.FindFilter {
+	var (
+		f   zookeeper.FindFilter
+		err error
+	)
+
+	f.Name = findName
+	if findRegex != "" {
+		f.NameRegexp, err = regexp.Compile(findRegex)
+		checkError(errors.Wrap(err, "invalid --regex"))
+	}
+	if findData != "" {
+		f.Data, err = regexp.Compile(findData)
+		checkError(errors.Wrap(err, "invalid --data"))
+	}
+
+	f.Size = parseRangeFlag("size", findSize, true)
+	f.Version = parseRangeFlag("version", findVersion, false)
+	f.Cversion = parseRangeFlag("cversion", findCversion, false)
+	f.Aversion = parseRangeFlag("aversion", findAversion, false)
+	f.Children = parseRangeFlag("children", findChildren, false)
+	f.Ctime = parseTimeRangeFlag("ctime", findCtime)
+	f.Mtime = parseTimeRangeFlag("mtime", findMtime)
+
+	switch {
+	case findEphemeral && findPersistent:
+		checkError(errors.New("--ephemeral and --persistent cannot be used together"))
+	case findEphemeral:
+		f.Ephemeral = &findEphemeral
+	case findPersistent:
+		ephemeral := false
+		f.Ephemeral = &ephemeral
+	}
+
+	if findOwner != "" {
+		f.Owner, err = strconv.ParseInt(findOwner, 0, 64)
+		checkError(errors.Wrap(err, "invalid --owner"))
+	}
+
+	f.ACLSchemes = findACLSchemes
+
+	return f
+}
+
+func parseRangeFlag(name, s string, size bool) *zookeeper.Range {
+	if s == "" {
+		return nil
+	}
+
+	r, err := parseRange(s, size)
+	checkError(errors.Wrapf(err, "invalid --%s", name))
+
+	return r
+}
+
+func parseTimeRangeFlag(name, s string) zookeeper.TimeRange {
+	if s == "" {
+		return zookeeper.TimeRange{}
+	}
+
+	r, err := parseTimeRange(s, time.Now())
+	checkError(errors.Wrapf(err, "invalid --%s", name))
+
+	return r
+}
+
+// parseRange parse the range: N, +N, -N, N..M, N.. or ..M, the size can end with k or m
+func parseRange(s string, size bool) (*zookeeper.Range, error) {
+	parse := func(v string) (int64, error) {
+		unit := int64(1)
+		if size {
+			switch {
+			case strings.HasSuffix(strings.ToLower(v), "k"):
+				unit, v = 1024, v[:len(v)-1]
+			case strings.HasSuffix(strings.ToLower(v), "m"):
+				unit, v = 1024*1024, v[:len(v)-1]
+			}
+		}
+
+		n, err := strconv.ParseInt(v, 10, 64)
+		return n * unit, err
+	}
+
+	r := &zookeeper.Range{Min: math.MinInt64, Max: math.MaxInt64}
+
+	var err error
+	switch {
+	case strings.Contains(s, ".."):
+		min, max, _ := strings.Cut(s, "..")
+		if min != "" {
+			if r.Min, err = parse(min); err != nil {
+				return nil, err
+			}
+		}
+		if max != "" {
+			if r.Max, err = parse(max); err != nil {
+				return nil, err
+			}
+		}
+	case strings.HasPrefix(s, "+"):
+		if r.Min, err = parse(s[1:]); err != nil {
+			return nil, err
+		}
+		r.Min++
+	case strings.HasPrefix(s, "-"):
+		if r.Max, err = parse(s[1:]); err != nil {
+			return nil, err
+		}
+		r.Max--
+	default:
+		if r.Min, err = parse(s); err != nil {
+			return nil, err
+		}
+		r.Max = r.Min
+	}
+
+	return r, nil
+}
+
+// parseTimeRange parse the time range: -D (within D before now), +D (more than
+// D before now) or T1..T2, D is the duration which can end with d for days
+func parseTimeRange(s string, now time.Time) (zookeeper.TimeRange, error) {
+	var (
+		r   zookeeper.TimeRange
+		err error
+	)
+
+	switch {
+	case strings.HasPrefix(s, "+"), strings.HasPrefix(s, "-"):
+		d, err := parseDays(s[1:])
+		if err != nil {
+			return r, err
+		}
+
+		if s[0] == '+' {
+			r.Before = now.Add(-d)
+		} else {
+			r.After = now.Add(-d)
+		}
+	case strings.Contains(s, ".."):
+		after, before, _ := strings.Cut(s, "..")
+		if after != "" {
+			if r.After, err = parseTime(after); err != nil {
+				return r, err
+			}
+		}
+		if before != "" {
+			if r.Before, err = parseTime(before); err != nil {
+				return r, err
+			}
+		}
+	default:
+		return r, errors.Errorf("%s must be -D, +D or T1..T2", s)
+	}
+
+	return r, nil
+}
+
+// parseDays parse the duration, it can end with d for days
+func parseDays(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.ParseFloat(s[:len(s)-1], 64)
+		return time.Duration(days * float64(24*time.Hour)), err
+	}
+
+	return time.ParseDuration(s)
+}
+
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	return time.ParseInLocation("2006-01-02", s, time.Local)
+}
+
+func cmdRunZnodeGrep(cmd *cobra.Command, args []string) {
+	pattern := args[0]
+	if grepIgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	checkError(errors.Wrap(err, "invalid pattern"))
+
+	path := workDir
+	if len(args) > 1 {
+		path = znodePath(args[1])
+	}
+
+	var chain codec.Chain
+	if decodeCodecs != decodeAuto {
+		chain = parseCodecs(decodeCodecs)
+	}
+
+	doc := &grepDoc{Pattern: args[0], Path: path, Matches: make([]grepMatch, 0)}
+
+	opts := walkOptions()
+	opts.Data = true
+	err = zkcli.Walk(path, opts, func(n *zookeeper.WalkNode) error {
+		for _, m := range grepData(n.Path, n.Data, re, chain) {
+			doc.Matches = append(doc.Matches, m)
+			if outputFormat == outputPlain {
+				doc.printPlainMatch(os.Stdout, m)
+			}
+
+			if grepFilesOnly {
+				break
+			}
+		}
+
+		return nil
+	})
+	checkError(err)
+
+	if outputFormat != outputPlain {
+		printOutput(doc)
+	}
+}
+
+// grepData return the matched lines of the data decoded by the chain, the
+// codecs are detected if the chain is nil, and the binary data is not decoded
+func grepData(path string, data []byte, re *regexp.Regexp, chain codec.Chain) []grepMatch {
+	text := data
+	if chain == nil {
+		var c codec.Chain
+		if c, text = codec.Detect(data); c[len(c)-1].Name() == "hex" {
+			text = data
+		}
+	} else {
+		var err error
+		if text, err = chain.Decode(data); err != nil {
+			return nil
+		}
+	}
+
+	var matches []grepMatch
+	for i, line := range bytes.Split(text, []byte("\n")) {
+		if re.Match(line) {
+			matches = append(matches, grepMatch{Path: path, Line: i + 1, Text: string(line)})
+		}
+	}
+
+	return matches
+}
+
+type findZnode struct {
+	Path string     `json:"path" yaml:"path"`
+	Stat *znodeStat `json:"stat" yaml:"stat"`
+}
+
+// findDoc is the output of znode find
+type findDoc struct {
+	Path   string      `json:"path" yaml:"path"`
+	Znodes []findZnode `json:"znodes" yaml:"znodes"`
+}
+
+func (d *findDoc) printTable(w io.Writer) {
+	if len(d.Znodes) == 0 {
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 6, 4, 3, ' ', 0)
+	fmt.Fprintf(tw, "Path\tSize\tChildren\tVersion\tMtime\tEphemeralOwner\t\n")
+	for _, n := range d.Znodes {
+		owner := "-"
+		if n.Stat.EphemeralOwner != 0 {
+			owner = fmt.Sprintf("%#x", n.Stat.EphemeralOwner)
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\t%s\t\n", n.Path, n.Stat.DataLength, n.Stat.NumChildren,
+			n.Stat.DataVersion, n.Stat.Mtime.Format("2006-01-02 15:04:05"), owner)
+	}
+	tw.Flush()
+}
+
+func (d *findDoc) printPlain(w io.Writer) {
+	for _, n := range d.Znodes {
+		fmt.Fprintln(w, n.Path)
+	}
+}
+
+type grepMatch struct {
+	Path string `json:"path" yaml:"path"`
+	Line int    `json:"line" yaml:"line"`
+	Text string `json:"text" yaml:"text"`
+}
+
+// grepDoc is the output of znode grep
+type grepDoc struct {
+	Pattern string      `json:"pattern" yaml:"pattern"`
+	Path    string      `json:"path" yaml:"path"`
+	Matches []grepMatch `json:"matches" yaml:"matches"`
+}
+
+func (d *grepDoc) printTable(w io.Writer) {
+	if len(d.Matches) == 0 {
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 6, 4, 3, ' ', 0)
+	if grepFilesOnly {
+		fmt.Fprintf(tw, "Path\t\n")
+		for _, m := range d.Matches {
+			fmt.Fprintf(tw, "%s\t\n", m.Path)
+		}
+	} else {
+		fmt.Fprintf(tw, "Path\tLine\tText\t\n")
+		for _, m := range d.Matches {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t\n", m.Path, m.Line, m.Text)
+		}
+	}
+	tw.Flush()
+}
+
+func (d *grepDoc) printPlain(w io.Writer) {
+	for _, m := range d.Matches {
+		d.printPlainMatch(w, m)
+	}
+}
+
+// printPlainMatch print the match like grep, it is called while walking
+func (d *grepDoc) printPlainMatch(w io.Writer, m grepMatch) {
+	if grepFilesOnly {
+		fmt.Fprintln(w, m.Path)
+		return
+	}
+
+	fmt.Fprintf(w, "%s:%d:%s\n", m.Path, m.Line, m.Text)
+}
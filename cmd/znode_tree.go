@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/benzimu/zkcmd/common/zookeeper"
+	"github.com/spf13/cobra"
+)
+
+var (
+	treeSize     bool
+	treeChildren bool
+
+	duDepth int
+	duTop   int
+)
+
+func newCmdZnodeTree() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tree [flags] [path]",
+		Short: "Print the znode hierarchy, the path default: / or the shell working directory",
+		Long: `Print the znode hierarchy like tree, the ephemeral znodes are marked with (ephemeral),
+  and the number of children is shown for the znodes at --max-depth.`,
+		Example: `  zkcmd znode tree /app
+	  zkcmd znode tree /app --max-depth 2 --size --children
+	  zkcmd znode tree /brokers -o json`,
+		Args: cobra.MaximumNArgs(1),
+		Run:  cmdRunZnodeTree,
+	}
+
+	cmd.Flags().BoolVarP(&treeSize, "size", "", false, "show the data length")
+	cmd.Flags().BoolVarP(&treeChildren, "children", "", false, "show the number of children")
+	addWalkFlags(cmd)
+
+	return cmd
+}
+
+func newCmdZnodeDu() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "du [flags] [path]",
+		Short: "Summarize the data bytes and number of znodes of the subtrees, the path default: / or the shell working directory",
+		Long: `Summarize the total data bytes and number of znodes of the path and its descendants
+  until --depth, sorted by bytes descending, to find the subtrees bloating the snapshot.`,
+		Example: `  zkcmd znode du /
+	  zkcmd znode du /app --depth 2 --top 10
+	  zkcmd znode du / --exclude /zookeeper`,
+		Args: cobra.MaximumNArgs(1),
+		Run:  cmdRunZnodeDu,
+	}
+
+	cmd.Flags().IntVarP(&duDepth, "depth", "d", 1, "summarize the subtrees until the depth, the children of the path are 1")
+	cmd.Flags().IntVarP(&duTop, "top", "n", 0, "only the top n subtrees. (default 0, all)")
+	cmd.Flags().IntVarP(&walkConcurrency, "concurrency", "", zookeeper.DefaultWalkConcurrency, "the number of concurrent requests to walk the znode tree")
+	cmd.Flags().StringSliceVarP(&walkExclude, "exclude", "", nil, "skip the znodes matched by the glob patterns and their descendants")
+
+	return cmd
+}
+
+func cmdRunZnodeTree(cmd *cobra.Command, args []string) {
+	root := workDir
+	if len(args) > 0 {
+		root = znodePath(args[0])
+	}
+
+	b := newTreeBuilder()
+	err := zkcli.Walk(root, walkOptions(), b.add)
+	checkError(err)
+
+	printOutput(b.root)
+}
+
+// treeNode is the output of znode tree
+type treeNode struct {
+	Path        string      `json:"path" yaml:"path"`
+	Name        string      `json:"-" yaml:"-"`
+	DataLength  int32       `json:"dataLength" yaml:"dataLength"`
+	NumChildren int32       `json:"numChildren" yaml:"numChildren"`
+	Ephemeral   bool        `json:"ephemeral" yaml:"ephemeral"`
+	Children    []*treeNode `json:"children,omitempty" yaml:"children,omitempty"`
+}
+
+// treeBuilder build the treeNode from the znodes walked in pre-order
+type treeBuilder struct {
+	root  *treeNode
+	nodes map[string]*treeNode
+}
+
+func newTreeBuilder() *treeBuilder {
+	return &treeBuilder{nodes: make(map[string]*treeNode)}
+}
+
+func (b *treeBuilder) add(n *zookeeper.WalkNode) error {
+	t := &treeNode{
+		Path:        n.Path,
+		Name:        path.Base(n.Path),
+		DataLength:  n.Stat.DataLength,
+		NumChildren: n.Stat.NumChildren,
+		Ephemeral:   n.Stat.EphemeralOwner != 0,
+		Children:    make([]*treeNode, 0),
+	}
+	b.nodes[n.Path] = t
+
+	if b.root == nil {
+		t.Name = n.Path
+		b.root = t
+		return nil
+	}
+
+	// the znodes not included are skipped, attach to the nearest ancestor
+	p := path.Dir(n.Path)
+	for b.nodes[p] == nil && p != "/" {
+		p = path.Dir(p)
+	}
+	if parent := b.nodes[p]; parent != nil {
+		parent.Children = append(parent.Children, t)
+	} else {
+		b.root.Children = append(b.root.Children, t)
+	}
+
+	return nil
+}
+
+func (t *treeNode) printTable(w io.Writer) {
+	var znodes, ephemerals int
+
+	var printNode func(n *treeNode, prefix, branch string)
+	printNode = func(n *treeNode, prefix, branch string) {
+		znodes++
+		fmt.Fprintf(w, "%s%s%s\n", prefix, branch, n.label())
+
+		if n != t {
+			if branch == "└── " {
+				prefix += "    "
+			} else {
+				prefix += "│   "
+			}
+		}
+		if n.Ephemeral {
+			ephemerals++
+		}
+
+		for i, c := range n.Children {
+			if i == len(n.Children)-1 {
+				printNode(c, prefix, "└── ")
+			} else {
+				printNode(c, prefix, "├── ")
+			}
+		}
+	}
+	printNode(t, "", "")
+
+	fmt.Fprintf(w, "\n%d znodes, %d ephemerals\n", znodes, ephemerals)
+}
+
+// label return the name with the size, number of children and ephemeral marker
+func (t *treeNode) label() string {
+	var info []string
+	if treeSize {
+		info = append(info, formatBytes(int64(t.DataLength)))
+	}
+	if treeChildren || (len(t.Children) == 0 && t.NumChildren > 0 && walkMaxDepth > 0) {
+		info = append(info, fmt.Sprintf("%d children", t.NumChildren))
+	}
+
+	label := t.Name
+	if len(info) > 0 {
+		label += " [" + strings.Join(info, ", ") + "]"
+	}
+	if t.Ephemeral {
+		label += " (ephemeral)"
+	}
+
+	return label
+}
+
+func (t *treeNode) printPlain(w io.Writer) {
+	fmt.Fprintln(w, t.Path)
+	for _, c := range t.Children {
+		c.printPlain(w)
+	}
+}
+
+func cmdRunZnodeDu(cmd *cobra.Command, args []string) {
+	root := workDir
+	if len(args) > 0 {
+		root = znodePath(args[0])
+	}
+
+	usages, err := zkcli.Usage(root, walkOptions(), duDepth)
+	checkError(err)
+
+	if duTop > 0 && len(usages) > duTop {
+		usages = usages[:duTop]
+	}
+
+	printOutput(&duDoc{Path: root, Usages: usages})
+}
+
+// duDoc is the output of znode du
+type duDoc struct {
+	Path   string             `json:"path" yaml:"path"`
+	Usages []*zookeeper.Usage `json:"usages" yaml:"usages"`
+}
+
+func (d *duDoc) printTable(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 6, 4, 3, ' ', 0)
+	fmt.Fprintf(tw, "Bytes\tSize\tZnodes\tEphemerals\tPath\t\n")
+	for _, u := range d.Usages {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%s\t\n", u.Bytes, formatBytes(u.Bytes), u.Znodes, u.Ephemerals, u.Path)
+	}
+	tw.Flush()
+}
+
+func (d *duDoc) printPlain(w io.Writer) {
+	for _, u := range d.Usages {
+		fmt.Fprintf(w, "%d\t%d\t%s\n", u.Bytes, u.Znodes, u.Path)
+	}
+}
+
+// formatBytes format the bytes in B, KiB, MiB or GiB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 2; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMG"[exp])
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/benzimu/zkcmd/common/zookeeper"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	txnFile string
+	dryRun  bool
+)
+
+func newCmdZnodeTxn() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "txn [flags]",
+		Short: "Execute the create/set/delete/check operations of the file in one transaction",
+		Long: `Execute the create/set/delete/check operations of the file in one transaction,
+  all of them succeed or none of them. The file is a json or yaml list of operations:
+
+  - op: check                 # create, set, delete or check
+    path: /app
+    version: 3                # expected data version of set/delete/check, required by check
+  - op: create
+    path: /app/config
+    data: "{}"
+    dataEncoding: utf8        # utf8 or base64. (default utf8)
+    acl: world:anyone:cdrwa   # ACL of create. (default world:anyone:cdrwa)
+    ephemeral: false
+    sequential: false
+  - op: set
+    path: /app/version
+    data: "2"
+  - op: delete
+    path: /app/lock`,
+		Example: `  zkcmd znode txn -f ops.yaml
+  zkcmd znode txn -f ops.json --dry-run`,
+		Args: cobra.NoArgs,
+		Run:  cmdRunZnodeTxn,
+	}
+
+	cmd.Flags().StringVarP(&txnFile, "file", "f", "", `the operations file, "-" is stdin`)
+	cmd.Flags().BoolVarP(&dryRun, "dry-run", "", false, "validate the existence and versions of the operations without committing")
+	_ = cmd.MarkFlagRequired("file")
+
+	return cmd
+}
+
+func cmdRunZnodeTxn(cmd *cobra.Command, args []string) {
+	var ops []zookeeper.TxnOp
+	// yaml is a superset of json
+	err := yaml.Unmarshal(readInputFile(txnFile), &ops)
+	checkError(errors.Wrap(err, "invalid operations file"))
+
+	if len(ops) == 0 {
+		checkError(errors.New("no operations in the file"))
+	}
+
+	var results []zookeeper.TxnResult
+	if dryRun {
+		results, err = zkcli.DryRunTxn(ops)
+	} else {
+		results, err = zkcli.Txn(ops)
+	}
+
+	if results != nil {
+		printOutput(newTxnDoc(results, dryRun, err == nil))
+	}
+	checkError(err)
+}
+
+type txnOpResult struct {
+	ID          int        `json:"id" yaml:"id"`
+	Op          string     `json:"op" yaml:"op"`
+	Path        string     `json:"path" yaml:"path"`
+	Status      string     `json:"status" yaml:"status"`
+	CreatedPath string     `json:"createdPath,omitempty" yaml:"createdPath,omitempty"`
+	Stat        *znodeStat `json:"stat,omitempty" yaml:"stat,omitempty"`
+	Error       string     `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// txnDoc is the output of znode txn
+type txnDoc struct {
+	DryRun    bool          `json:"dryRun" yaml:"dryRun"`
+	Committed bool          `json:"committed" yaml:"committed"`
+	Ops       []txnOpResult `json:"ops" yaml:"ops"`
+}
+
+func newTxnDoc(results []zookeeper.TxnResult, dryRun, succeeded bool) *txnDoc {
+	d := &txnDoc{
+		DryRun:    dryRun,
+		Committed: succeeded && !dryRun,
+		Ops:       make([]txnOpResult, len(results)),
+	}
+
+	for i, r := range results {
+		d.Ops[i] = txnOpResult{
+			ID:          i + 1,
+			Op:          r.Op,
+			Path:        r.Path,
+			Status:      r.Status,
+			CreatedPath: r.CreatedPath,
+			Stat:        newZnodeStat(r.Stat),
+		}
+
+		if r.Err != nil {
+			d.Ops[i].Error = r.Err.Error()
+		}
+	}
+
+	return d
+}
+
+func (d *txnDoc) printTable(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 6, 4, 3, ' ', 0)
+	fmt.Fprintf(tw, "ID\tOp\tPath\tStatus\tResult\t\n")
+	for _, op := range d.Ops {
+		result := op.Error
+		switch {
+		case op.CreatedPath != "" && op.CreatedPath != op.Path:
+			result = op.CreatedPath
+		case op.Stat != nil:
+			result = fmt.Sprintf("version=%d", op.Stat.DataVersion)
+		}
+
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t\n", op.ID, op.Op, op.Path, op.Status, result)
+	}
+	tw.Flush()
+
+	switch {
+	case d.Committed:
+		fmt.Fprintln(w, "Committed")
+	case !d.DryRun:
+		fmt.Fprintln(w, "Rolled back")
+	case d.Ops[len(d.Ops)-1].Status == zookeeper.TxnStatusOK:
+		fmt.Fprintln(w, "Dry run, the transaction would be committed")
+	default:
+		fmt.Fprintln(w, "Dry run, the transaction would be rolled back")
+	}
+}
+
+func (d *txnDoc) printPlain(w io.Writer) {
+	for _, op := range d.Ops {
+		fmt.Fprintf(w, "%d %s %s %s\n", op.ID, op.Op, op.Path, op.Status)
+	}
+}
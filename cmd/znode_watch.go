@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/benzimu/zkcmd/common/zookeeper"
+	"github.com/go-zookeeper/zk"
+	"github.com/spf13/cobra"
+)
+
+var recursive bool
+
+func newCmdZnodeWatch() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "watch [flags] path",
+		Short: "Watch znode data, children and existence changes until interrupted",
+		Long: `Watch znode data, children and existence changes until interrupted. With --recursive the
+  subtree is watched by the persistent recursive watch of ZooKeeper 3.6+, the created, changed and
+  deleted znodes are reported, the changes while disconnected are missed. The servers before 3.6
+  fall back to the one-time watches of every znode, which report the children changes too.`,
+		Example: `  zkcmd znode watch /test
+	  zkcmd znode watch -r /test
+	  zkcmd znode watch -r /test -o json`,
+		Args: cobra.ExactArgs(1),
+		Run:  cmdRunZnodeWatch,
+	}
+
+	cmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "watch all znodes of the subtree")
+
+	return cmd
+}
+
+func cmdRunZnodeWatch(cmd *cobra.Command, args []string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := zkcli.Watch(ctx, znodePath(args[0]), recursive, func(ev zookeeper.WatchEvent) {
+		if outputFormat == outputYAML {
+			fmt.Println("---")
+		}
+
+		printOutput(newWatchEventDoc(ev))
+	})
+	checkError(err)
+}
+
+// watchEventDoc is the output of znode watch, one document per event
+type watchEventDoc struct {
+	Time         time.Time  `json:"time" yaml:"time"`
+	Type         string     `json:"type" yaml:"type"`
+	State        string     `json:"state,omitempty" yaml:"state,omitempty"`
+	Path         string     `json:"path" yaml:"path"`
+	Data         *string    `json:"data,omitempty" yaml:"data,omitempty"`
+	DataEncoding string     `json:"dataEncoding,omitempty" yaml:"dataEncoding,omitempty"`
+	Children     []string   `json:"children,omitempty" yaml:"children,omitempty"`
+	Stat         *znodeStat `json:"stat,omitempty" yaml:"stat,omitempty"`
+	Missed       bool       `json:"missed,omitempty" yaml:"missed,omitempty"`
+	Error        string     `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+func newWatchEventDoc(ev zookeeper.WatchEvent) *watchEventDoc {
+	d := &watchEventDoc{
+		Time:     ev.Time,
+		Type:     strings.TrimPrefix(ev.Type.String(), "Event"),
+		Path:     ev.Path,
+		Children: ev.Children,
+		Stat:     newZnodeStat(ev.Stat),
+		Missed:   ev.Missed,
+	}
+
+	if ev.Type == zk.EventSession {
+		d.State = strings.TrimPrefix(ev.State.String(), "State")
+	}
+
+	if ev.Data != nil || (ev.Stat != nil && ev.Children == nil) {
+		var data string
+		data, d.DataEncoding = zookeeper.EncodeData(ev.Data)
+		d.Data = &data
+	}
+
+	if ev.Err != nil {
+		d.Error = ev.Err.Error()
+	}
+
+	return d
+}
+
+func (d *watchEventDoc) printTable(w io.Writer) {
+	fmt.Fprintf(w, "%s  %-20s  %s", d.Time.Format(time.RFC3339), d.Type, d.Path)
+
+	if d.State != "" {
+		fmt.Fprintf(w, "  state=%s", d.State)
+	}
+	if d.Stat != nil {
+		fmt.Fprintf(w, "  version=%d  cversion=%d", d.Stat.DataVersion, d.Stat.Cversion)
+	}
+	if d.Children != nil {
+		fmt.Fprintf(w, "  children=%v", d.Children)
+	}
+	if d.Data != nil {
+		fmt.Fprintf(w, "  data=%q", *d.Data)
+	}
+	if d.Missed {
+		fmt.Fprintf(w, "  (events may have been missed)")
+	}
+	if d.Error != "" {
+		fmt.Fprintf(w, "  error=%s", d.Error)
+	}
+
+	fmt.Fprintln(w)
+}
+
+func (d *watchEventDoc) printPlain(w io.Writer) {
+	fmt.Fprintf(w, "%s %s %s\n", d.Time.Format(time.RFC3339), d.Type, d.Path)
+}
@@ -0,0 +1,203 @@
+// Package adminserver is the client of the zookeeper AdminServer, see:
+// https://zookeeper.apache.org/doc/current/zookeeperAdmin.html#sc_adminserver
+package adminserver
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/benzimu/zkcmd/common/flw"
+	"github.com/go-resty/resty/v2"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultCommandURL is the default URL of the commands relative to the root URL
+	DefaultCommandURL = "/commands"
+
+	// DefaultTimeout is the default timeout of a request
+	DefaultTimeout = 5 * time.Second
+)
+
+var commandLink = regexp.MustCompile(`href="[^"]*/([^"/]+)"`)
+
+// Options control how the AdminServer is requested
+type Options struct {
+	// CommandURL is the URL of the commands relative to the root URL. (default "/commands")
+	CommandURL string
+	// Timeout is the timeout of a request. (default 5s)
+	Timeout time.Duration
+	// TLSConfig is used for the https AdminServer
+	TLSConfig *tls.Config
+	// Authorization is the Authorization header, like "digest user:password"
+	// of the AdminServer auth since 3.9
+	Authorization string
+}
+
+// Client is the client of an AdminServer
+type Client struct {
+	server string
+	base   string
+	http   *resty.Client
+}
+
+// Response is the response of a command, Fields are the decoded JSON object
+// with json.Number numbers, Raw is the body if it is not JSON, like the
+// streamed snapshot
+type Response struct {
+	Command     string                 `json:"command" yaml:"command"`
+	Fields      map[string]interface{} `json:"fields,omitempty" yaml:"fields,omitempty"`
+	Raw         []byte                 `json:"-" yaml:"-"`
+	ContentType string                 `json:"contentType,omitempty" yaml:"contentType,omitempty"`
+}
+
+// Error is the error of a command, the non-2xx status or the error field of the response
+type Error struct {
+	Command    string
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("adminserver: %s: %d %s", e.Command, e.StatusCode, e.Message)
+	}
+
+	return fmt.Sprintf("adminserver: %s: %s", e.Command, e.Message)
+}
+
+// New new the client of the server, the server is the address with optional
+// scheme, like: 10.0.0.1:8080 or https://10.0.0.1:8443
+func New(server string, opts Options) *Client {
+	if opts.CommandURL == "" {
+		opts.CommandURL = DefaultCommandURL
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+
+	base := server
+	if !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") {
+		base = "http://" + base
+	}
+	base = strings.TrimSuffix(base, "/") + "/" + strings.Trim(opts.CommandURL, "/")
+
+	h := resty.New().SetTimeout(opts.Timeout)
+	if opts.TLSConfig != nil {
+		h.SetTLSClientConfig(opts.TLSConfig)
+	}
+	if opts.Authorization != "" {
+		h.SetHeader("Authorization", opts.Authorization)
+	}
+
+	return &Client{server: server, base: base, http: h}
+}
+
+// Server return the address of the server
+func (c *Client) Server() string {
+	return c.server
+}
+
+// Commands list the names of the commands
+func (c *Client) Commands() ([]string, error) {
+	resp, err := c.http.R().Get(c.base)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.IsError() {
+		return nil, &Error{Command: "list", StatusCode: resp.StatusCode(), Message: resp.Status()}
+	}
+
+	names := make([]string, 0)
+	for _, m := range commandLink.FindAllStringSubmatch(resp.String(), -1) {
+		names = append(names, m[1])
+	}
+
+	return names, nil
+}
+
+// Exec run the command by GET with the query parameters
+func (c *Client) Exec(command string, params url.Values) (*Response, error) {
+	return c.Do("GET", command, params, nil)
+}
+
+// Do run the command by the method with the query parameters and the body
+func (c *Client) Do(method, command string, params url.Values, body []byte) (*Response, error) {
+	req := c.http.R().SetQueryParamsFromValues(params)
+	if body != nil {
+		req.SetBody(body)
+	}
+
+	resp, err := req.Execute(method, c.base+"/"+url.PathEscape(command))
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Response{Command: command, ContentType: resp.Header().Get("Content-Type")}
+
+	if !strings.Contains(res.ContentType, "json") {
+		if resp.IsError() {
+			return nil, &Error{Command: command, StatusCode: resp.StatusCode(), Message: strings.TrimSpace(resp.String())}
+		}
+
+		res.Raw = resp.Body()
+		return res, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(resp.Body()))
+	dec.UseNumber()
+	if err := dec.Decode(&res.Fields); err != nil {
+		if resp.IsError() {
+			return nil, &Error{Command: command, StatusCode: resp.StatusCode(), Message: resp.Status()}
+		}
+
+		return nil, errors.Wrapf(err, "decode response of %s", command)
+	}
+
+	// the error field is null if succeeded
+	msg, _ := res.Fields["error"].(string)
+	if resp.IsError() {
+		if msg == "" {
+			msg = resp.Status()
+		}
+
+		return nil, &Error{Command: command, StatusCode: resp.StatusCode(), Message: msg}
+	}
+	if msg != "" {
+		return nil, &Error{Command: command, Message: msg}
+	}
+
+	return res, nil
+}
+
+// Ruok run the ruok command, it returns nil if the server is running
+func (c *Client) Ruok() error {
+	_, err := c.Exec("ruok", nil)
+	return err
+}
+
+// Monitor run the monitor command, the metrics are the same as mntr
+func (c *Client) Monitor() (*flw.Mntr, error) {
+	res, err := c.Exec("monitor", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := make(map[string]string, len(res.Fields))
+	for k, v := range res.Fields {
+		switch v.(type) {
+		case string, json.Number, bool:
+			metrics[k] = fmt.Sprint(v)
+		}
+	}
+	delete(metrics, "command")
+
+	return flw.NewMntr(metrics), nil
+}
@@ -0,0 +1,256 @@
+// Package codec convert the data of znode between the stored bytes and the
+// readable text, like pretty JSON, hex dump, decompressed gzip and protobuf.
+package codec
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Codec convert the data of znode, Decode the stored data to the readable data
+// and Encode the readable data back to the stored data
+type Codec interface {
+	Name() string
+	Decode(data []byte) ([]byte, error)
+	Encode(data []byte) ([]byte, error)
+}
+
+var codecs = map[string]Codec{}
+
+func init() {
+	for _, c := range []Codec{
+		rawCodec{}, jsonCodec{}, hexCodec{}, base64Codec{},
+		gzipCodec{}, snappyCodec{}, zstdCodec{}, javaCodec{}, &Protobuf{},
+	} {
+		Register(c)
+	}
+}
+
+// Register register the codec by its name, the codec of the same name is replaced
+func Register(c Codec) {
+	codecs[c.Name()] = c
+}
+
+// Names return the sorted names of the registered codecs
+func Names() []string {
+	names := make([]string, 0, len(codecs))
+	for name := range codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Chain is the codecs applied in order by Decode and in reverse order by
+// Encode, so the data encoded by a chain is decoded by the same chain
+type Chain []Codec
+
+// Parse parse the chain of the codec names separated by a comma, like: gzip,json
+func Parse(spec string) (Chain, error) {
+	var chain Chain
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		c, ok := codecs[name]
+		if !ok {
+			return nil, errors.Errorf("unknown codec %s, must be one of: %s", name, strings.Join(Names(), ", "))
+		}
+
+		chain = append(chain, c)
+	}
+
+	return chain, nil
+}
+
+// String return the names of the codecs separated by a comma
+func (c Chain) String() string {
+	names := make([]string, len(c))
+	for i, codec := range c {
+		names[i] = codec.Name()
+	}
+
+	return strings.Join(names, ",")
+}
+
+// Decode decode the data by the codecs in order
+func (c Chain) Decode(data []byte) ([]byte, error) {
+	var err error
+	for _, codec := range c {
+		if data, err = codec.Decode(data); err != nil {
+			return nil, errors.Wrapf(err, "decode %s", codec.Name())
+		}
+	}
+
+	return data, nil
+}
+
+// Encode encode the data by the codecs in reverse order
+func (c Chain) Encode(data []byte) ([]byte, error) {
+	var err error
+	for i := len(c) - 1; i >= 0; i-- {
+		if data, err = c[i].Encode(data); err != nil {
+			return nil, errors.Wrapf(err, "encode %s", c[i].Name())
+		}
+	}
+
+	return data, nil
+}
+
+// maxDetectDepth limit the nested containers, like gzip in java in gzip
+const maxDetectDepth = 4
+
+// Detect detect the codecs of the data by the magic numbers of the compressed
+// and Java serialized data, the valid UTF-8 data is decoded as JSON if it is
+// a JSON object or array, otherwise raw, and the other binary data is decoded
+// as hex dump. It return the detected chain and the decoded data.
+func Detect(data []byte) (Chain, []byte) {
+	var chain Chain
+	for i := 0; i < maxDetectDepth; i++ {
+		c := detectContainer(data)
+		if c == nil {
+			break
+		}
+
+		decoded, err := c.Decode(data)
+		if err != nil {
+			break
+		}
+
+		chain, data = append(chain, c), decoded
+	}
+
+	var c Codec = rawCodec{}
+	switch {
+	case !utf8.Valid(data):
+		c = hexCodec{}
+	case isJSON(data):
+		c = jsonCodec{}
+	}
+
+	decoded, err := c.Decode(data)
+	if err != nil {
+		return append(chain, rawCodec{}), data
+	}
+
+	return append(chain, c), decoded
+}
+
+// detectContainer return the codec of the magic number, nil if unknown
+func detectContainer(data []byte) Codec {
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		return gzipCodec{}
+	case bytes.HasPrefix(data, zstdMagic):
+		return zstdCodec{}
+	case bytes.HasPrefix(data, snappyMagic):
+		return snappyCodec{}
+	case isJavaString(data):
+		return javaCodec{}
+	}
+
+	return nil
+}
+
+func isJSON(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || (data[0] != '{' && data[0] != '[') {
+		return false
+	}
+
+	return json.Valid(data)
+}
+
+// rawCodec keep the data as it is
+type rawCodec struct{}
+
+func (rawCodec) Name() string { return "raw" }
+
+func (rawCodec) Decode(data []byte) ([]byte, error) { return data, nil }
+
+func (rawCodec) Encode(data []byte) ([]byte, error) { return data, nil }
+
+// jsonCodec indent the JSON data, and compact it when encoding
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) Decode(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (jsonCodec) Encode(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// hexCodec dump the binary data like hexdump -C, the hex string or the dump is
+// decoded to binary when encoding, the spaces and the "0x" prefix are ignored
+type hexCodec struct{}
+
+// hexDumpLine match the line of the hex dump: the offset, the hex bytes, then
+// the printable chars between "|", the plain hex string has no "|"
+var hexDumpLine = regexp.MustCompile(`^[0-9a-fA-F]{8}  ([0-9a-fA-F ]*)\|`)
+
+func (hexCodec) Name() string { return "hex" }
+
+func (hexCodec) Decode(data []byte) ([]byte, error) {
+	return []byte(hex.Dump(data)), nil
+}
+
+func (hexCodec) Encode(data []byte) ([]byte, error) {
+	// the offsets and the printable chars of the dump are dropped, so the
+	// decoded data is encoded back
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		if m := hexDumpLine.FindStringSubmatch(line); m != nil {
+			lines[i] = m[1]
+		}
+	}
+
+	s := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+
+		return r
+	}, strings.Join(lines, ""))
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+
+	return hex.DecodeString(s)
+}
+
+// base64Codec show the binary data as base64 text, the base64 text is decoded
+// to binary when encoding
+type base64Codec struct{}
+
+func (base64Codec) Name() string { return "base64" }
+
+func (base64Codec) Decode(data []byte) ([]byte, error) {
+	return []byte(base64.StdEncoding.EncodeToString(data)), nil
+}
+
+func (base64Codec) Encode(data []byte) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+}
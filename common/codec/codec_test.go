@@ -0,0 +1,244 @@
+package codec
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/golang/snappy"
+)
+
+// allBytes is the data with all the byte values, it is not valid UTF-8
+var allBytes = func() []byte {
+	b := make([]byte, 256)
+	for i := range b {
+		b[i] = byte(i)
+	}
+
+	return b
+}()
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		chain string
+		// data is the stored data
+		data []byte
+		// want is the decoded data, not checked if nil
+		want []byte
+	}{
+		{name: "raw", chain: "raw", data: allBytes, want: allBytes},
+		{name: "json", chain: "json", data: []byte(`{"a":[1,2],"b":{"c":"d"}}`)},
+		{name: "hex", chain: "hex", data: allBytes},
+		{name: "hex empty", chain: "hex", data: []byte{}, want: []byte{}},
+		{name: "hex of text", chain: "hex", data: []byte("a|b|c\n")},
+		{name: "base64", chain: "base64", data: allBytes},
+		{name: "gzip", chain: "gzip", data: encodeBy(t, "gzip", allBytes), want: allBytes},
+		{name: "snappy", chain: "snappy", data: encodeBy(t, "snappy", allBytes), want: allBytes},
+		{name: "zstd", chain: "zstd", data: encodeBy(t, "zstd", allBytes), want: allBytes},
+		{name: "java ascii", chain: "java", data: encodeBy(t, "java", []byte("hello")), want: []byte("hello")},
+		{name: "java NUL", chain: "java", data: encodeBy(t, "java", []byte("a\x00b")), want: []byte("a\x00b")},
+		{name: "java BMP", chain: "java", data: encodeBy(t, "java", []byte("中文 é")), want: []byte("中文 é")},
+		{name: "java surrogates", chain: "java", data: encodeBy(t, "java", []byte("emoji 😀 𝄞")), want: []byte("emoji 😀 𝄞")},
+		{name: "java long string", chain: "java", data: encodeBy(t, "java", bytes.Repeat([]byte("x"), 0x10000)), want: bytes.Repeat([]byte("x"), 0x10000)},
+		{name: "gzip json", chain: "gzip,json", data: encodeBy(t, "gzip", []byte(`{"a":1}`))},
+		{name: "gzip java", chain: "gzip,java", data: encodeBy(t, "gzip,java", []byte("hello")), want: []byte("hello")},
+		{name: "zstd hex", chain: "zstd,hex", data: encodeBy(t, "zstd", allBytes)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, err := Parse(tt.chain)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			decoded, err := chain.Decode(tt.data)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if tt.want != nil && !bytes.Equal(decoded, tt.want) {
+				t.Errorf("Decode() = %q, want %q", decoded, tt.want)
+			}
+
+			// the decoded data is encoded back by the same chain, the JSON
+			// is compacted
+			encoded, err := chain.Encode(decoded)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if strings.HasSuffix(tt.chain, "json") {
+				encoded = mustDecode(t, chain[:len(chain)-1], encoded)
+				tt.data = mustDecode(t, chain[:len(chain)-1], tt.data)
+			}
+			if !bytes.Equal(encoded, tt.data) {
+				t.Errorf("Encode() = %x, want %x", encoded, tt.data)
+			}
+		})
+	}
+}
+
+func TestSnappyBlock(t *testing.T) {
+	// the block format is decoded, but encoded by the framing format
+	got, err := snappyCodec{}.Decode(snappy.Encode(nil, allBytes))
+	if err != nil || !bytes.Equal(got, allBytes) {
+		t.Errorf("Decode() = %x, %v", got, err)
+	}
+}
+
+// encodeBy encode the data by the chain of the spec
+func encodeBy(t *testing.T, spec string, data []byte) []byte {
+	t.Helper()
+
+	chain, err := Parse(spec)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	res, err := chain.Encode(data)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	return res
+}
+
+func mustDecode(t *testing.T, c Chain, data []byte) []byte {
+	t.Helper()
+
+	res, err := c.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	return res
+}
+
+func TestJavaModifiedUTF8(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want []byte
+	}{
+		{name: "ascii", s: "ab", want: []byte("ab")},
+		{name: "NUL is two bytes", s: "\x00", want: []byte{0xc0, 0x80}},
+		{name: "BMP", s: "é", want: []byte{0xc3, 0xa9}},
+		{name: "supplementary is a surrogate pair", s: "😀", want: []byte{0xed, 0xa0, 0xbd, 0xed, 0xb8, 0x80}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := javaCodec{}.Encode([]byte(tt.s))
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+
+			want := append(append([]byte(nil), javaStreamHeader...), javaTCString, 0, byte(len(tt.want)))
+			want = append(want, tt.want...)
+			if !bytes.Equal(got, want) {
+				t.Errorf("Encode() = %x, want %x", got, want)
+			}
+		})
+	}
+
+	for _, data := range [][]byte{
+		{0xac, 0xed, 0x00, 0x05},
+		{0xac, 0xed, 0x00, 0x05, 0x73, 0x72},
+		{0xac, 0xed, 0x00, 0x05, javaTCString, 0x00, 0x05, 'a'},
+		{0xac, 0xed, 0x00, 0x05, javaTCString, 0x00, 0x01, 0xff},
+	} {
+		if _, err := (javaCodec{}).Decode(data); err == nil {
+			t.Errorf("Decode(%x) error = nil", data)
+		}
+	}
+}
+
+func TestHexEncode(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    []byte
+		wantErr bool
+	}{
+		{name: "plain", s: "68656c6c6f", want: []byte("hello")},
+		{name: "spaces and 0x", s: "0x68 65 6C\n6c 6f\n", want: []byte("hello")},
+		{name: "dump", s: "00000000  68 65 6c 6c 6f 7c 0a                              |hello|.|\n", want: []byte("hello|\n")},
+		{name: "invalid", s: "6g", wantErr: true},
+		{name: "odd length", s: "686", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := hexCodec{}.Encode([]byte(tt.s))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Encode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !bytes.Equal(got, tt.want) {
+				t.Errorf("Encode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBase64Encode(t *testing.T) {
+	if got, err := (base64Codec{}).Encode([]byte("aGVsbG8=\n")); err != nil || string(got) != "hello" {
+		t.Errorf("Encode() = %q, %v", got, err)
+	}
+	if _, err := (base64Codec{}).Encode([]byte("!!")); err == nil {
+		t.Errorf("Encode() error = nil")
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []byte
+		wantChain string
+		want      []byte
+	}{
+		{name: "text", data: []byte("hello"), wantChain: "raw", want: []byte("hello")},
+		{name: "empty", data: []byte{}, wantChain: "raw", want: []byte{}},
+		{name: "json object", data: []byte(`{"a":1}`), wantChain: "json", want: []byte("{\n  \"a\": 1\n}")},
+		{name: "json array", data: []byte(`[1]`), wantChain: "json", want: []byte("[\n  1\n]")},
+		{name: "json string is raw", data: []byte(`"a"`), wantChain: "raw", want: []byte(`"a"`)},
+		{name: "invalid json is raw", data: []byte(`{a}`), wantChain: "raw", want: []byte(`{a}`)},
+		{name: "binary", data: []byte{0xff, 0x00}, wantChain: "hex", want: []byte("00000000  ff 00                                             |..|\n")},
+		{name: "gzip", data: encodeBy(t, "gzip", []byte("hello")), wantChain: "gzip,raw", want: []byte("hello")},
+		{name: "zstd json", data: encodeBy(t, "zstd,json", []byte(`{"a":1}`)), wantChain: "zstd,json", want: []byte("{\n  \"a\": 1\n}")},
+		{name: "snappy", data: encodeBy(t, "snappy", []byte("hello")), wantChain: "snappy,raw", want: []byte("hello")},
+		{name: "java", data: encodeBy(t, "java", []byte("hello")), wantChain: "java,raw", want: []byte("hello")},
+		{name: "java in gzip", data: encodeBy(t, "gzip,java", []byte("hello")), wantChain: "gzip,java,raw", want: []byte("hello")},
+		{name: "gzip binary", data: encodeBy(t, "gzip", []byte{0xff}), wantChain: "gzip,hex", want: []byte("00000000  ff                                                |.|\n")},
+		{name: "truncated gzip is binary", data: encodeBy(t, "gzip", []byte("hello"))[:8], wantChain: "hex"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, got := Detect(tt.data)
+			if chain.String() != tt.wantChain {
+				t.Errorf("Detect() chain = %s, want %s", chain, tt.wantChain)
+			}
+			if tt.want != nil && !bytes.Equal(got, tt.want) {
+				t.Errorf("Detect() = %q, want %q", got, tt.want)
+			}
+
+			// the detected data is encoded back by the chain, JSON is indented
+			if !strings.HasSuffix(tt.wantChain, "json") {
+				if encoded := encodeBy(t, chain.String(), got); !bytes.Equal(encoded, tt.data) {
+					t.Errorf("Encode(Detect()) = %x, want %x", encoded, tt.data)
+				}
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	chain, err := Parse(" gzip , json ,")
+	if err != nil || chain.String() != "gzip,json" {
+		t.Errorf("Parse() = %s, %v", chain, err)
+	}
+
+	if _, err := Parse("gzip,nope"); err == nil {
+		t.Errorf("Parse() error = nil")
+	}
+}
@@ -0,0 +1,122 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+)
+
+// the Java object serialization stream, see:
+// https://docs.oracle.com/javase/8/docs/platform/serialization/spec/protocol.html
+const (
+	javaTCString     = 0x74
+	javaTCLongString = 0x7c
+)
+
+// javaStreamHeader is the stream magic 0xaced and version 5
+var javaStreamHeader = []byte{0xac, 0xed, 0x00, 0x05}
+
+// javaCodec decode the Java serialized String, like the data written by
+// ObjectOutputStream.writeObject(String), and serialize the string when encoding
+type javaCodec struct{}
+
+func (javaCodec) Name() string { return "java" }
+
+func isJavaString(data []byte) bool {
+	return len(data) > 4 && bytes.HasPrefix(data, javaStreamHeader) &&
+		(data[4] == javaTCString || data[4] == javaTCLongString)
+}
+
+func (javaCodec) Decode(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, javaStreamHeader) {
+		return nil, errors.New("not a Java serialization stream")
+	}
+	data = data[len(javaStreamHeader):]
+
+	if len(data) == 0 {
+		return nil, errors.New("empty Java serialization stream")
+	}
+
+	var n uint64
+	switch data[0] {
+	case javaTCString:
+		if len(data) < 3 {
+			return nil, errors.New("truncated Java string")
+		}
+		n, data = uint64(binary.BigEndian.Uint16(data[1:])), data[3:]
+	case javaTCLongString:
+		if len(data) < 9 {
+			return nil, errors.New("truncated Java string")
+		}
+		n, data = binary.BigEndian.Uint64(data[1:]), data[9:]
+	default:
+		return nil, errors.Errorf("Java serialized object 0x%02x is not a String", data[0])
+	}
+
+	if uint64(len(data)) < n {
+		return nil, errors.New("truncated Java string")
+	}
+
+	return decodeModifiedUTF8(data[:n])
+}
+
+func (javaCodec) Encode(data []byte) ([]byte, error) {
+	s := encodeModifiedUTF8(data)
+
+	buf := bytes.NewBuffer(append([]byte(nil), javaStreamHeader...))
+	if len(s) <= 0xffff {
+		buf.WriteByte(javaTCString)
+		_ = binary.Write(buf, binary.BigEndian, uint16(len(s)))
+	} else {
+		buf.WriteByte(javaTCLongString)
+		_ = binary.Write(buf, binary.BigEndian, uint64(len(s)))
+	}
+	buf.Write(s)
+
+	return buf.Bytes(), nil
+}
+
+// decodeModifiedUTF8 decode the modified UTF-8 of Java to UTF-8, the NUL is
+// two bytes and the supplementary characters are surrogate pairs
+func decodeModifiedUTF8(data []byte) ([]byte, error) {
+	units := make([]uint16, 0, len(data))
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c < 0x80:
+			units = append(units, uint16(c))
+			i++
+		case c&0xe0 == 0xc0 && i+1 < len(data):
+			units = append(units, uint16(c&0x1f)<<6|uint16(data[i+1]&0x3f))
+			i += 2
+		case c&0xf0 == 0xe0 && i+2 < len(data):
+			units = append(units, uint16(c&0x0f)<<12|uint16(data[i+1]&0x3f)<<6|uint16(data[i+2]&0x3f))
+			i += 3
+		default:
+			return nil, errors.Errorf("invalid modified UTF-8 at %d", i)
+		}
+	}
+
+	return []byte(string(utf16.Decode(units))), nil
+}
+
+// encodeModifiedUTF8 encode the UTF-8 to the modified UTF-8 of Java
+func encodeModifiedUTF8(data []byte) []byte {
+	units := utf16.Encode([]rune(string(data)))
+
+	res := make([]byte, 0, len(units))
+	for _, u := range units {
+		switch {
+		case u != 0 && u < 0x80:
+			res = append(res, byte(u))
+		case u < 0x800:
+			res = append(res, byte(0xc0|u>>6), byte(0x80|u&0x3f))
+		default:
+			res = append(res, byte(0xe0|u>>12), byte(0x80|(u>>6)&0x3f), byte(0x80|u&0x3f))
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,96 @@
+package codec
+
+import (
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Protobuf decode the protobuf message to JSON, and encode the JSON to the
+// message. The message is described by a descriptor set, which is generated by:
+//
+//	protoc --include_imports --descriptor_set_out=app.pb app.proto
+type Protobuf struct {
+	desc protoreflect.MessageDescriptor
+}
+
+// NewProtobuf new the protobuf codec of the message type in the serialized
+// FileDescriptorSet, the type can be empty if there is only one message
+func NewProtobuf(descriptorSet []byte, messageType string) (*Protobuf, error) {
+	fds := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(descriptorSet, fds); err != nil {
+		return nil, errors.Wrap(err, "invalid descriptor set")
+	}
+
+	files, err := protodesc.NewFiles(fds)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid descriptor set")
+	}
+
+	if messageType == "" {
+		var names []protoreflect.FullName
+		files.RangeFiles(func(f protoreflect.FileDescriptor) bool {
+			for i := 0; i < f.Messages().Len(); i++ {
+				names = append(names, f.Messages().Get(i).FullName())
+			}
+
+			return true
+		})
+
+		if len(names) != 1 {
+			return nil, errors.Errorf("%d messages in the descriptor set, the message type is required", len(names))
+		}
+
+		messageType = string(names[0])
+	}
+
+	d, err := files.FindDescriptorByName(protoreflect.FullName(messageType))
+	if err != nil {
+		return nil, errors.Wrapf(err, "message type %s", messageType)
+	}
+
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, errors.Errorf("%s is not a message type", messageType)
+	}
+
+	return &Protobuf{desc: md}, nil
+}
+
+func (p *Protobuf) Name() string { return "protobuf" }
+
+func (p *Protobuf) Decode(data []byte) ([]byte, error) {
+	if p.desc == nil {
+		return nil, errors.New("the descriptor set of protobuf is required")
+	}
+
+	msg := dynamicpb.NewMessage(p.desc)
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+
+	b, err := protojson.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	// the whitespaces of protojson are randomized, indent it like the json codec
+	return jsonCodec{}.Decode(b)
+}
+
+func (p *Protobuf) Encode(data []byte) ([]byte, error) {
+	if p.desc == nil {
+		return nil, errors.New("the descriptor set of protobuf is required")
+	}
+
+	msg := dynamicpb.NewMessage(p.desc)
+	if err := protojson.Unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(msg)
+}
@@ -0,0 +1,208 @@
+// Package diff compute the line differences of two texts and format them as unified diff.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// DefaultContext is the number of unchanged lines around the changes
+	DefaultContext = 3
+
+	// maxCells limit the memory of the LCS table, the texts are treated as
+	// completely different if they are too large
+	maxCells = 4 << 20
+)
+
+// OpKind is the kind of a line edit
+type OpKind int
+
+const (
+	Equal OpKind = iota
+	Delete
+	Insert
+)
+
+// Op is a line edit from a to b
+type Op struct {
+	Kind OpKind
+	Line string
+}
+
+// Lines compute the line edits from a to b, the edits are in the order of lines
+func Lines(a, b []string) []Op {
+	// skip the common prefix and suffix, they are usually most of the lines
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]Op, 0, len(a)+len(b))
+	for _, l := range a[:prefix] {
+		ops = append(ops, Op{Equal, l})
+	}
+
+	ops = append(ops, lcs(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+
+	for _, l := range a[len(a)-suffix:] {
+		ops = append(ops, Op{Equal, l})
+	}
+
+	return ops
+}
+
+// lcs compute the line edits by the longest common subsequence
+func lcs(a, b []string) []Op {
+	ops := make([]Op, 0, len(a)+len(b))
+
+	if len(a)*len(b) > maxCells {
+		for _, l := range a {
+			ops = append(ops, Op{Delete, l})
+		}
+		for _, l := range b {
+			ops = append(ops, Op{Insert, l})
+		}
+
+		return ops
+	}
+
+	// t[i][j] is the LCS length of a[i:] and b[j:]
+	t := make([][]int, len(a)+1)
+	for i := range t {
+		t[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				t[i][j] = t[i+1][j+1] + 1
+			} else if t[i+1][j] >= t[i][j+1] {
+				t[i][j] = t[i+1][j]
+			} else {
+				t[i][j] = t[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, Op{Equal, a[i]})
+			i++
+			j++
+		case t[i+1][j] >= t[i][j+1]:
+			ops = append(ops, Op{Delete, a[i]})
+			i++
+		default:
+			ops = append(ops, Op{Insert, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, Op{Delete, a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, Op{Insert, b[j]})
+	}
+
+	return ops
+}
+
+// Unified return the unified diff of the texts a and b with the file names,
+// return empty string if they are equal
+func Unified(nameA, nameB, a, b string, context int) string {
+	if a == b {
+		return ""
+	}
+
+	ops := Lines(splitLines(a), splitLines(b))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", nameA, nameB)
+
+	// lineA and lineB are the line numbers before ops[i]
+	lineA, lineB := 0, 0
+	for i := 0; i < len(ops); {
+		if ops[i].Kind == Equal {
+			lineA++
+			lineB++
+			i++
+			continue
+		}
+
+		// the hunk start with the context lines before the change, and end
+		// when the unchanged lines are more than twice the context
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		startA, startB := lineA-(i-start), lineB-(i-start)
+
+		end, equals := i, 0
+		for ; end < len(ops) && equals <= 2*context; end++ {
+			if ops[end].Kind == Equal {
+				equals++
+			} else {
+				equals = 0
+			}
+		}
+		end -= equals - context
+		if equals < context {
+			end = len(ops)
+		}
+
+		countA, countB := 0, 0
+		for _, op := range ops[start:end] {
+			if op.Kind != Insert {
+				countA++
+			}
+			if op.Kind != Delete {
+				countB++
+			}
+		}
+
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(startA, countA), hunkRange(startB, countB))
+		for _, op := range ops[start:end] {
+			switch op.Kind {
+			case Equal:
+				sb.WriteString(" ")
+			case Delete:
+				sb.WriteString("-")
+			case Insert:
+				sb.WriteString("+")
+			}
+			sb.WriteString(op.Line)
+			sb.WriteString("\n")
+		}
+
+		lineA, lineB, i = startA+countA, startB+countB, end
+	}
+
+	return sb.String()
+}
+
+// hunkRange format the hunk range, start is 0-based
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
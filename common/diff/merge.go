@@ -0,0 +1,131 @@
+package diff
+
+import "strings"
+
+// the conflict markers of Merge3, like git merge
+const (
+	markerOurs   = "<<<<<<<"
+	markerSep    = "======="
+	markerTheirs = ">>>>>>>"
+)
+
+// Merge3 merge the changes of ours and theirs from base line by line, like
+// diff3: a chunk changed by one side takes its change, a chunk changed by both
+// sides the same takes it once, and the other chunks changed by both sides are
+// conflicts wrapped in the conflict markers with the labels. It return the
+// merged text and the number of conflicts.
+func Merge3(base, ours, theirs, labelOurs, labelTheirs string) (string, int) {
+	b, o, t := splitLines(base), splitLines(ours), splitLines(theirs)
+	mo, mt := matches(b, o), matches(b, t)
+
+	merged := make([]string, 0, len(o)+len(t))
+	conflicts := 0
+
+	// resolve the chunk of the unstable lines between the stable lines
+	resolve := func(cb, co, ct []string) {
+		switch {
+		case equalLines(co, cb):
+			merged = append(merged, ct...)
+		case equalLines(ct, cb), equalLines(co, ct):
+			merged = append(merged, co...)
+		default:
+			conflicts++
+			merged = append(merged, markerOurs+" "+labelOurs)
+			merged = append(merged, co...)
+			merged = append(merged, markerSep)
+			merged = append(merged, ct...)
+			merged = append(merged, markerTheirs+" "+labelTheirs)
+		}
+	}
+
+	i, j, k := 0, 0, 0
+	for {
+		// the next base line kept by both sides
+		n := i
+		for n < len(b) && (mo[n] < 0 || mt[n] < 0) {
+			n++
+		}
+
+		if n == len(b) {
+			resolve(b[i:], o[j:], t[k:])
+			break
+		}
+
+		if n > i || mo[n] > j || mt[n] > k {
+			resolve(b[i:n], o[j:mo[n]], t[k:mt[n]])
+		}
+
+		merged = append(merged, b[n])
+		i, j, k = n+1, mo[n]+1, mt[n]+1
+	}
+
+	if len(merged) == 0 {
+		return "", conflicts
+	}
+
+	// keep the trailing newline of the changed side
+	text := strings.Join(merged, "\n")
+	newline := strings.HasSuffix(ours, "\n")
+	if ours == base {
+		newline = strings.HasSuffix(theirs, "\n")
+	}
+	if newline {
+		text += "\n"
+	}
+
+	return text, conflicts
+}
+
+// HasConflictMarkers report whether the text has the conflict markers of Merge3
+func HasConflictMarkers(text string) bool {
+	ours, sep := false, false
+	for _, l := range splitLines(text) {
+		switch {
+		case strings.HasPrefix(l, markerOurs):
+			ours = true
+		case l == markerSep && ours:
+			sep = true
+		case strings.HasPrefix(l, markerTheirs) && sep:
+			return true
+		}
+	}
+
+	return false
+}
+
+// matches return the index of every line of a in b if it is kept by the edits
+// from a to b, otherwise -1
+func matches(a, b []string) []int {
+	m := make([]int, len(a))
+
+	i, j := 0, 0
+	for _, op := range Lines(a, b) {
+		switch op.Kind {
+		case Equal:
+			m[i] = j
+			i++
+			j++
+		case Delete:
+			m[i] = -1
+			i++
+		case Insert:
+			j++
+		}
+	}
+
+	return m
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
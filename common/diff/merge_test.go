@@ -0,0 +1,78 @@
+package diff
+
+import "testing"
+
+func TestMerge3(t *testing.T) {
+	tests := []struct {
+		name          string
+		base          string
+		ours          string
+		theirs        string
+		want          string
+		wantConflicts int
+	}{
+		{
+			name:   "changes on different lines",
+			base:   "a\nb\nc\nd\ne\n",
+			ours:   "a\nB\nc\nd\ne\n",
+			theirs: "a\nb\nc\nd\nE\n",
+			want:   "a\nB\nc\nd\nE\n",
+		},
+		{
+			name:   "only theirs changed",
+			base:   "a\nb\n",
+			ours:   "a\nb\n",
+			theirs: "a\nb\nc\n",
+			want:   "a\nb\nc\n",
+		},
+		{
+			name:   "same change on both sides",
+			base:   "a\nb\nc\n",
+			ours:   "a\nx\nc\n",
+			theirs: "a\nx\nc\n",
+			want:   "a\nx\nc\n",
+		},
+		{
+			name:   "insert and delete",
+			base:   "a\nb\nc\nd\n",
+			ours:   "z\na\nb\nc\nd\n",
+			theirs: "a\nb\nd\n",
+			want:   "z\na\nb\nd\n",
+		},
+		{
+			name:          "overlapping changes",
+			base:          "a\nb\nc\n",
+			ours:          "a\nmine\nc\n",
+			theirs:        "a\nyours\nc\n",
+			want:          "a\n<<<<<<< ours\nmine\n=======\nyours\n>>>>>>> theirs\nc\n",
+			wantConflicts: 1,
+		},
+		{
+			name:          "conflict at the end without newline",
+			base:          `{"port": 80}`,
+			ours:          `{"port": 8080}`,
+			theirs:        `{"port": 9090}`,
+			want:          "<<<<<<< ours\n{\"port\": 8080}\n=======\n{\"port\": 9090}\n>>>>>>> theirs",
+			wantConflicts: 1,
+		},
+		{
+			name:   "everything deleted by ours",
+			base:   "a\n",
+			ours:   "",
+			theirs: "a\n",
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conflicts := Merge3(tt.base, tt.ours, tt.theirs, "ours", "theirs")
+			if got != tt.want || conflicts != tt.wantConflicts {
+				t.Errorf("Merge3() = %q, %d, want %q, %d", got, conflicts, tt.want, tt.wantConflicts)
+			}
+			if HasConflictMarkers(got) != (tt.wantConflicts > 0) {
+				t.Errorf("HasConflictMarkers(%q) = %v", got, !(tt.wantConflicts > 0))
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+// Package exporter export the metrics of zookeeper servers and znodes to
+// Prometheus, the servers are polled periodically and the last results are
+// collected on scrape.
+package exporter
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benzimu/zkcmd/common/flw"
+	"github.com/go-zookeeper/zk"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "zookeeper"
+
+// DefaultInterval is the default interval of polling
+const DefaultInterval = 15 * time.Second
+
+// counterMetrics are the mntr metrics only increased since the server started,
+// the others are gauges
+var counterMetrics = map[string]bool{
+	"packets_received":                true,
+	"packets_sent":                    true,
+	"bytes_received_count":            true,
+	"stale_requests":                  true,
+	"stale_requests_dropped":          true,
+	"stale_replies":                   true,
+	"request_throttle_wait_count":     true,
+	"digest_mismatches_count":         true,
+	"unrecoverable_error_count":       true,
+	"auth_failed_count":               true,
+	"looking_count":                   true,
+	"connection_drop_count":           true,
+	"connection_rejected":             true,
+	"sessionless_connections_expired": true,
+}
+
+// infoMetrics are the non-numeric mntr metrics exported as labels of the info metric
+var infoMetrics = map[string]bool{
+	"version":      true,
+	"server_state": true,
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+var (
+	upDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "up"),
+		"Whether the last poll of the server succeeded.",
+		[]string{"server"}, nil,
+	)
+	infoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "server_info"),
+		"The version and role of the server.",
+		[]string{"server", "role", "version"}, nil,
+	)
+	znodeExistsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "znode", "exists"),
+		"Whether the znode exists.",
+		[]string{"path"}, nil,
+	)
+	znodeChildrenDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "znode", "children"),
+		"The number of children of the znode.",
+		[]string{"path"}, nil,
+	)
+	znodeDataDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "znode", "data_bytes"),
+		"The data length of the znode.",
+		[]string{"path"}, nil,
+	)
+	znodeMtimeAgeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "znode", "mtime_age_seconds"),
+		"The seconds since the znode was last modified.",
+		[]string{"path"}, nil,
+	)
+)
+
+// Options control what are polled
+type Options struct {
+	// Servers are the addresses of the four letter word commands
+	Servers []string
+	// Interval is the interval of polling. (default 15s)
+	Interval time.Duration
+	// Timeout is the timeout of a four letter word command. (default flw.DefaultTimeout)
+	Timeout time.Duration
+	// Monitor get the extra metrics of the server, like the AdminServer
+	// monitor command, they override the mntr metrics of the same name
+	Monitor func(server string) (*flw.Mntr, error)
+	// Znodes are the paths to export the children, data length and mtime age
+	Znodes []string
+	// Stat get the stat of the znode, the stat is nil if the znode does not exist
+	Stat func(path string) (*zk.Stat, error)
+	// OnError is called with the errors of polling
+	OnError func(err error)
+}
+
+// serverMetrics are the polled metrics of a server, Metrics are nil if down
+type serverMetrics struct {
+	server  string
+	metrics map[string]string
+}
+
+type znodeStat struct {
+	path string
+	stat *zk.Stat
+}
+
+// Exporter poll the servers and znodes, it is a prometheus.Collector
+type Exporter struct {
+	opts Options
+
+	mu      sync.RWMutex
+	servers []serverMetrics
+	znodes  []znodeStat
+}
+
+// New new the exporter, call Run to poll
+func New(opts Options) *Exporter {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(error) {}
+	}
+
+	return &Exporter{opts: opts}
+}
+
+// Run poll immediately and then every interval until stop is closed
+func (e *Exporter) Run(stop <-chan struct{}) {
+	e.Poll()
+
+	ticker := time.NewTicker(e.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			e.Poll()
+		}
+	}
+}
+
+// Poll poll the servers concurrently and the znodes once
+func (e *Exporter) Poll() {
+	servers := make([]serverMetrics, len(e.opts.Servers))
+
+	var wg sync.WaitGroup
+	for i, server := range e.opts.Servers {
+		wg.Add(1)
+		go func(i int, server string) {
+			defer wg.Done()
+
+			servers[i] = serverMetrics{server: server, metrics: e.pollServer(server)}
+		}(i, server)
+	}
+
+	znodes := make([]znodeStat, 0, len(e.opts.Znodes))
+	for _, p := range e.opts.Znodes {
+		if e.opts.Stat == nil {
+			break
+		}
+
+		// the znode is not exported if the stat is unknown
+		stat, err := e.opts.Stat(p)
+		if err != nil && err != zk.ErrNoNode {
+			e.opts.OnError(err)
+			continue
+		}
+
+		znodes = append(znodes, znodeStat{path: p, stat: stat})
+	}
+
+	wg.Wait()
+
+	e.mu.Lock()
+	e.servers, e.znodes = servers, znodes
+	e.mu.Unlock()
+}
+
+// pollServer return the metrics of mntr and monitor without the "zk_" prefix,
+// nil if both failed
+func (e *Exporter) pollServer(server string) map[string]string {
+	var metrics map[string]string
+
+	out, err := flw.Exec(server, "mntr", e.opts.Timeout)
+	if err == nil {
+		var m *flw.Mntr
+		if m, err = flw.ParseMntr(out); err == nil {
+			metrics = m.Metrics
+		}
+	}
+	if err != nil {
+		e.opts.OnError(err)
+	}
+
+	if e.opts.Monitor != nil {
+		m, err := e.opts.Monitor(server)
+		if err != nil {
+			e.opts.OnError(err)
+		} else if metrics == nil {
+			metrics = m.Metrics
+		} else {
+			for k, v := range m.Metrics {
+				metrics[k] = v
+			}
+		}
+	}
+
+	if metrics == nil {
+		return nil
+	}
+
+	res := make(map[string]string, len(metrics))
+	for k, v := range metrics {
+		res[strings.TrimPrefix(k, "zk_")] = v
+	}
+
+	return res
+}
+
+// Describe send no descriptors, the mntr metrics are different by the versions
+// and roles of the servers, so the exporter is an unchecked collector
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect send the metrics of the last poll
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, s := range e.servers {
+		if s.metrics == nil {
+			ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, 0, s.server)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, 1, s.server)
+
+		role := s.metrics["server_state"]
+		ch <- prometheus.MustNewConstMetric(infoDesc, prometheus.GaugeValue, 1, s.server, role, s.metrics["version"])
+
+		for name, value := range s.metrics {
+			if infoMetrics[name] {
+				continue
+			}
+
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+
+			valueType, fqName := prometheus.GaugeValue, metricName(name)
+			if counterMetrics[name] {
+				valueType, fqName = prometheus.CounterValue, fqName+"_total"
+			}
+
+			desc := prometheus.NewDesc(fqName, "The "+name+" of mntr.", []string{"server", "role"}, nil)
+			ch <- prometheus.MustNewConstMetric(desc, valueType, v, s.server, role)
+		}
+	}
+
+	now := time.Now()
+	for _, z := range e.znodes {
+		if z.stat == nil {
+			ch <- prometheus.MustNewConstMetric(znodeExistsDesc, prometheus.GaugeValue, 0, z.path)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(znodeExistsDesc, prometheus.GaugeValue, 1, z.path)
+		ch <- prometheus.MustNewConstMetric(znodeChildrenDesc, prometheus.GaugeValue, float64(z.stat.NumChildren), z.path)
+		ch <- prometheus.MustNewConstMetric(znodeDataDesc, prometheus.GaugeValue, float64(z.stat.DataLength), z.path)
+
+		mtime := time.UnixMilli(z.stat.Mtime)
+		ch <- prometheus.MustNewConstMetric(znodeMtimeAgeDesc, prometheus.GaugeValue, now.Sub(mtime).Seconds(), z.path)
+	}
+}
+
+// metricName return the Prometheus name of the mntr metric
+func metricName(name string) string {
+	return prometheus.BuildFQName(namespace, "", invalidNameChars.ReplaceAllString(name, "_"))
+}
@@ -0,0 +1,497 @@
+// Package flw execute the four letter word commands of zookeeper servers and
+// parse the responses of mntr, srvr, stat, conf, cons, envi and wchs.
+package flw
+
+import (
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultTimeout is the default timeout of a four letter word command
+const DefaultTimeout = 8 * time.Second
+
+// Response is the response of a server
+type Response struct {
+	Server string
+	Output string
+	Err    error
+}
+
+// Exec send the four letter word command to the server and return the
+// response, the timeout includes dialing
+func Exec(server, cmd string, timeout time.Duration) (string, error) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	conn, err := net.DialTimeout("tcp", server, timeout)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return "", err
+	}
+
+	if _, err := conn.Write([]byte(cmd)); err != nil {
+		return "", err
+	}
+
+	res, err := io.ReadAll(conn)
+	if err != nil {
+		return "", err
+	}
+
+	return string(res), nil
+}
+
+// ExecAll send the command to all the servers concurrently, the responses
+// are in the order of servers
+func ExecAll(servers []string, cmd string, timeout time.Duration) []*Response {
+	res := make([]*Response, len(servers))
+
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func(i int, server string) {
+			defer wg.Done()
+
+			out, err := Exec(server, cmd, timeout)
+			res[i] = &Response{Server: server, Output: out, Err: err}
+		}(i, server)
+	}
+	wg.Wait()
+
+	return res
+}
+
+// NotWhitelistedError is returned if the command is not in the
+// 4lw.commands.whitelist of the server, only srvr is allowed by default since
+// ZooKeeper 3.5
+type NotWhitelistedError struct {
+	Message string
+}
+
+func (e *NotWhitelistedError) Error() string {
+	return e.Message
+}
+
+// IsNotWhitelisted report whether the error is a NotWhitelistedError
+func IsNotWhitelisted(err error) bool {
+	var e *NotWhitelistedError
+	return errors.As(err, &e)
+}
+
+// checkResponse return the error if the command is not executed by the server
+func checkResponse(s string) error {
+	msg := strings.TrimSpace(s)
+	switch {
+	case msg == "":
+		return errors.New("empty response")
+	case strings.Contains(msg, "not in the whitelist"):
+		return &NotWhitelistedError{Message: msg}
+	case strings.Contains(msg, "not currently serving requests"):
+		return errors.New(msg)
+	}
+
+	return nil
+}
+
+// Mntr is the response of mntr, Metrics are all the metrics by name
+type Mntr struct {
+	Version                 string  `json:"version" yaml:"version"`
+	ServerState             string  `json:"serverState" yaml:"serverState"`
+	AvgLatency              float64 `json:"avgLatency" yaml:"avgLatency"`
+	MaxLatency              float64 `json:"maxLatency" yaml:"maxLatency"`
+	MinLatency              float64 `json:"minLatency" yaml:"minLatency"`
+	PacketsReceived         int64   `json:"packetsReceived" yaml:"packetsReceived"`
+	PacketsSent             int64   `json:"packetsSent" yaml:"packetsSent"`
+	NumAliveConnections     int64   `json:"numAliveConnections" yaml:"numAliveConnections"`
+	OutstandingRequests     int64   `json:"outstandingRequests" yaml:"outstandingRequests"`
+	ZnodeCount              int64   `json:"znodeCount" yaml:"znodeCount"`
+	WatchCount              int64   `json:"watchCount" yaml:"watchCount"`
+	EphemeralsCount         int64   `json:"ephemeralsCount" yaml:"ephemeralsCount"`
+	ApproximateDataSize     int64   `json:"approximateDataSize" yaml:"approximateDataSize"`
+	OpenFileDescriptorCount int64   `json:"openFileDescriptorCount" yaml:"openFileDescriptorCount"`
+	MaxFileDescriptorCount  int64   `json:"maxFileDescriptorCount" yaml:"maxFileDescriptorCount"`
+	// Followers, SyncedFollowers and PendingSyncs are only reported by the leader
+	Followers       int64 `json:"followers,omitempty" yaml:"followers,omitempty"`
+	SyncedFollowers int64 `json:"syncedFollowers,omitempty" yaml:"syncedFollowers,omitempty"`
+	PendingSyncs    int64 `json:"pendingSyncs,omitempty" yaml:"pendingSyncs,omitempty"`
+
+	Metrics map[string]string `json:"metrics" yaml:"metrics"`
+}
+
+// ParseMntr parse the tab separated metrics of mntr
+func ParseMntr(s string) (*Mntr, error) {
+	if err := checkResponse(s); err != nil {
+		return nil, err
+	}
+
+	metrics := make(map[string]string)
+	for _, line := range lines(s) {
+		key, value, ok := strings.Cut(line, "\t")
+		if !ok {
+			return nil, errors.Errorf("invalid mntr line: %s", line)
+		}
+
+		metrics[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	return NewMntr(metrics), nil
+}
+
+// NewMntr new the mntr of the metrics, the "zk_" prefix of the metric names
+// is added if missing, like the keys of the AdminServer monitor command
+func NewMntr(metrics map[string]string) *Mntr {
+	m := &Mntr{Metrics: make(map[string]string, len(metrics))}
+
+	floats := map[string]*float64{
+		"zk_avg_latency": &m.AvgLatency,
+		"zk_max_latency": &m.MaxLatency,
+		"zk_min_latency": &m.MinLatency,
+	}
+	ints := map[string]*int64{
+		"zk_packets_received":           &m.PacketsReceived,
+		"zk_packets_sent":               &m.PacketsSent,
+		"zk_num_alive_connections":      &m.NumAliveConnections,
+		"zk_outstanding_requests":       &m.OutstandingRequests,
+		"zk_znode_count":                &m.ZnodeCount,
+		"zk_watch_count":                &m.WatchCount,
+		"zk_ephemerals_count":           &m.EphemeralsCount,
+		"zk_approximate_data_size":      &m.ApproximateDataSize,
+		"zk_open_file_descriptor_count": &m.OpenFileDescriptorCount,
+		"zk_max_file_descriptor_count":  &m.MaxFileDescriptorCount,
+		"zk_followers":                  &m.Followers,
+		"zk_synced_followers":           &m.SyncedFollowers,
+		"zk_pending_syncs":              &m.PendingSyncs,
+	}
+
+	for key, value := range metrics {
+		if !strings.HasPrefix(key, "zk_") {
+			key = "zk_" + key
+		}
+		m.Metrics[key] = value
+
+		switch key {
+		case "zk_version":
+			m.Version = value
+		case "zk_server_state":
+			m.ServerState = value
+		}
+
+		if p, ok := floats[key]; ok {
+			*p, _ = strconv.ParseFloat(value, 64)
+		}
+		if p, ok := ints[key]; ok {
+			*p, _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+
+	return m
+}
+
+// Ruok return nil if the server responds imok to ruok
+func Ruok(server string, timeout time.Duration) error {
+	res, err := Exec(server, "ruok", timeout)
+	if err != nil {
+		return err
+	}
+
+	if res != "imok" {
+		if err := checkResponse(res); err != nil {
+			return err
+		}
+
+		return errors.Errorf("ruok response: %s", res)
+	}
+
+	return nil
+}
+
+// Srvr is the response of srvr
+type Srvr struct {
+	Version     string  `json:"version" yaml:"version"`
+	MinLatency  float64 `json:"minLatency" yaml:"minLatency"`
+	AvgLatency  float64 `json:"avgLatency" yaml:"avgLatency"`
+	MaxLatency  float64 `json:"maxLatency" yaml:"maxLatency"`
+	Received    int64   `json:"received" yaml:"received"`
+	Sent        int64   `json:"sent" yaml:"sent"`
+	Connections int64   `json:"connections" yaml:"connections"`
+	Outstanding int64   `json:"outstanding" yaml:"outstanding"`
+	Zxid        int64   `json:"zxid" yaml:"zxid"`
+	Mode        string  `json:"mode" yaml:"mode"`
+	NodeCount   int64   `json:"nodeCount" yaml:"nodeCount"`
+}
+
+// Epoch return the epoch of the zxid
+func (s *Srvr) Epoch() int64 {
+	return s.Zxid >> 32
+}
+
+// ParseSrvr parse the response of srvr
+func ParseSrvr(s string) (*Srvr, error) {
+	st, err := ParseStat(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &st.Srvr, nil
+}
+
+// Stat is the response of stat, it is srvr and the connections
+type Stat struct {
+	Srvr    `yaml:",inline"`
+	Clients []*Connection `json:"clients" yaml:"clients"`
+}
+
+// ParseStat parse the response of stat
+func ParseStat(s string) (*Stat, error) {
+	if err := checkResponse(s); err != nil {
+		return nil, err
+	}
+
+	st := &Stat{Clients: make([]*Connection, 0)}
+
+	inClients := false
+	for _, line := range lines(s) {
+		if inClients && strings.HasPrefix(line, " ") {
+			c, err := ParseConnection(line)
+			if err != nil {
+				return nil, err
+			}
+
+			st.Clients = append(st.Clients, c)
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		inClients = key == "Clients"
+
+		var err error
+		switch key {
+		case "Zookeeper version":
+			st.Version = value
+		case "Latency min/avg/max":
+			err = parseLatency(value, &st.MinLatency, &st.AvgLatency, &st.MaxLatency)
+		case "Received":
+			st.Received, err = strconv.ParseInt(value, 10, 64)
+		case "Sent":
+			st.Sent, err = strconv.ParseInt(value, 10, 64)
+		case "Connections":
+			st.Connections, err = strconv.ParseInt(value, 10, 64)
+		case "Outstanding":
+			st.Outstanding, err = strconv.ParseInt(value, 10, 64)
+		case "Zxid":
+			st.Zxid, err = strconv.ParseInt(value, 0, 64)
+		case "Mode":
+			st.Mode = value
+		case "Node count":
+			st.NodeCount, err = strconv.ParseInt(value, 10, 64)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid %s", key)
+		}
+	}
+
+	if st.Mode == "" {
+		return nil, errors.Errorf("invalid response: %s", strings.TrimSpace(s))
+	}
+
+	return st, nil
+}
+
+func parseLatency(s string, min, avg, max *float64) error {
+	parts := strings.Split(s, "/")
+	if len(parts) != 3 {
+		return errors.Errorf("invalid latency: %s", s)
+	}
+
+	for i, p := range []*float64{min, avg, max} {
+		v, err := strconv.ParseFloat(parts[i], 64)
+		if err != nil {
+			return err
+		}
+		*p = v
+	}
+
+	return nil
+}
+
+// Connection is a client connection of cons and stat, Stats are all the
+// statistics by name. Only Address, Interest, Queued, Received and Sent are
+// reported by stat.
+type Connection struct {
+	Address     string            `json:"address" yaml:"address"`
+	Interest    int               `json:"interest" yaml:"interest"`
+	Queued      int64             `json:"queued" yaml:"queued"`
+	Received    int64             `json:"received" yaml:"received"`
+	Sent        int64             `json:"sent" yaml:"sent"`
+	SessionID   string            `json:"sessionID,omitempty" yaml:"sessionID,omitempty"`
+	LastOp      string            `json:"lastOp,omitempty" yaml:"lastOp,omitempty"`
+	Timeout     int64             `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	LastLatency int64             `json:"lastLatency,omitempty" yaml:"lastLatency,omitempty"`
+	MinLatency  int64             `json:"minLatency,omitempty" yaml:"minLatency,omitempty"`
+	AvgLatency  int64             `json:"avgLatency,omitempty" yaml:"avgLatency,omitempty"`
+	MaxLatency  int64             `json:"maxLatency,omitempty" yaml:"maxLatency,omitempty"`
+	Stats       map[string]string `json:"stats" yaml:"stats"`
+}
+
+// ParseConnection parse the connection line like:
+// /127.0.0.1:52126[1](queued=0,recved=5,sent=5,sid=0x100,lop=PING,...)
+func ParseConnection(line string) (*Connection, error) {
+	line = strings.TrimSpace(line)
+
+	// the interest ops are the last brackets before the stats, the IPv6
+	// address is in brackets too
+	k := strings.IndexByte(line, '(')
+	if k < 0 {
+		return nil, errors.Errorf("invalid connection: %s", line)
+	}
+	i, j := strings.LastIndexByte(line[:k], '['), strings.LastIndexByte(line[:k], ']')
+	if i < 0 || j < i || !strings.HasSuffix(line, ")") {
+		return nil, errors.Errorf("invalid connection: %s", line)
+	}
+
+	c := &Connection{
+		Address: strings.TrimPrefix(line[:i], "/"),
+		Stats:   make(map[string]string),
+	}
+
+	var err error
+	if c.Interest, err = strconv.Atoi(line[i+1 : j]); err != nil {
+		return nil, errors.Errorf("invalid connection: %s", line)
+	}
+
+	ints := map[string]*int64{
+		"queued": &c.Queued,
+		"recved": &c.Received,
+		"sent":   &c.Sent,
+		"to":     &c.Timeout,
+		"llat":   &c.LastLatency,
+		"minlat": &c.MinLatency,
+		"avglat": &c.AvgLatency,
+		"maxlat": &c.MaxLatency,
+	}
+
+	for _, kv := range strings.Split(line[k+1:len(line)-1], ",") {
+		key, value, _ := strings.Cut(kv, "=")
+		c.Stats[key] = value
+
+		switch key {
+		case "sid":
+			c.SessionID = value
+		case "lop":
+			c.LastOp = value
+		}
+
+		if p, ok := ints[key]; ok {
+			*p, _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+
+	return c, nil
+}
+
+// ParseCons parse the response of cons
+func ParseCons(s string) ([]*Connection, error) {
+	if err := checkResponse(s); err != nil {
+		return nil, err
+	}
+
+	conns := make([]*Connection, 0)
+	for _, line := range lines(s) {
+		c, err := ParseConnection(line)
+		if err != nil {
+			return nil, err
+		}
+
+		conns = append(conns, c)
+	}
+
+	return conns, nil
+}
+
+// Properties are the key=value lines of conf and envi
+type Properties map[string]string
+
+// ParseProperties parse the response of conf or envi, the "Environment:"
+// header of envi is skipped
+func ParseProperties(s string) (Properties, error) {
+	if err := checkResponse(s); err != nil {
+		return nil, err
+	}
+
+	props := make(Properties)
+	for _, line := range lines(s) {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+
+		props[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	return props, nil
+}
+
+// Wchs is the response of wchs
+type Wchs struct {
+	Connections int64 `json:"connections" yaml:"connections"`
+	Paths       int64 `json:"paths" yaml:"paths"`
+	Watches     int64 `json:"watches" yaml:"watches"`
+}
+
+// ParseWchs parse the response of wchs like:
+// 2 connections watching 3 paths
+// Total watches:4
+func ParseWchs(s string) (*Wchs, error) {
+	if err := checkResponse(s); err != nil {
+		return nil, err
+	}
+
+	w := &Wchs{}
+	for _, line := range lines(s) {
+		var err error
+		if strings.HasPrefix(line, "Total watches:") {
+			w.Watches, err = strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(line, "Total watches:")), 10, 64)
+		} else {
+			fields := strings.Fields(line)
+			if len(fields) != 5 {
+				return nil, errors.Errorf("invalid wchs line: %s", line)
+			}
+			if w.Connections, err = strconv.ParseInt(fields[0], 10, 64); err == nil {
+				w.Paths, err = strconv.ParseInt(fields[3], 10, 64)
+			}
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid wchs line: %s", line)
+		}
+	}
+
+	return w, nil
+}
+
+// lines return the non-empty lines
+func lines(s string) []string {
+	res := make([]string, 0)
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) != "" {
+			res = append(res, line)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,537 @@
+package flw
+
+import (
+	"testing"
+)
+
+// the captured responses of ZooKeeper 3.5.9 and 3.8.1 ensembles and a 3.8.1
+// standalone server
+const (
+	srvr35Follower = `Zookeeper version: 3.5.9-83df9301aa5c2a5d284a9940177808c01bc35cef, built on 01/06/2021 20:03 GMT
+Latency min/avg/max: 0/0/12
+Received: 1234
+Sent: 1233
+Connections: 3
+Outstanding: 0
+Zxid: 0x10000002a
+Mode: follower
+Node count: 12
+`
+
+	srvr38Leader = `Zookeeper version: 3.8.1-74db005175a4ec545697012f9069cb9dcc8cdda7, built on 2023-01-25 16:31 UTC
+Latency min/avg/max: 0/0.4213/12
+Received: 56789
+Sent: 56790
+Connections: 2
+Outstanding: 0
+Zxid: 0x200000123
+Mode: leader
+Node count: 42
+Proposal sizes last/min/max: 36/32/120
+`
+
+	srvr38Standalone = `Zookeeper version: 3.8.1-74db005175a4ec545697012f9069cb9dcc8cdda7, built on 2023-01-25 16:31 UTC
+Latency min/avg/max: 0/1.0/3
+Received: 10
+Sent: 9
+Connections: 1
+Outstanding: 0
+Zxid: 0x5
+Mode: standalone
+Node count: 5
+`
+
+	stat35 = `Zookeeper version: 3.5.9-83df9301aa5c2a5d284a9940177808c01bc35cef, built on 01/06/2021 20:03 GMT
+Clients:
+ /10.0.0.5:52126[1](queued=0,recved=5,sent=5)
+ /10.0.0.6:52128[0](queued=0,recved=1,sent=0)
+
+Latency min/avg/max: 0/0/12
+Received: 1234
+Sent: 1233
+Connections: 2
+Outstanding: 0
+Zxid: 0x10000002a
+Mode: follower
+Node count: 12
+`
+
+	stat38Standalone = `Zookeeper version: 3.8.1-74db005175a4ec545697012f9069cb9dcc8cdda7, built on 2023-01-25 16:31 UTC
+Clients:
+ /[0:0:0:0:0:0:0:1]:40318[1](queued=0,recved=12,sent=12)
+ /127.0.0.1:40320[0](queued=0,recved=1,sent=0)
+
+Latency min/avg/max: 0/1.0/3
+Received: 13
+Sent: 12
+Connections: 2
+Outstanding: 0
+Zxid: 0x5
+Mode: standalone
+Node count: 5
+`
+
+	mntr35Leader = "zk_version\t3.5.9-83df9301aa5c2a5d284a9940177808c01bc35cef, built on 01/06/2021 20:03 GMT\n" +
+		"zk_avg_latency\t0\n" +
+		"zk_max_latency\t12\n" +
+		"zk_min_latency\t0\n" +
+		"zk_packets_received\t1234\n" +
+		"zk_packets_sent\t1233\n" +
+		"zk_num_alive_connections\t3\n" +
+		"zk_outstanding_requests\t0\n" +
+		"zk_server_state\tleader\n" +
+		"zk_znode_count\t12\n" +
+		"zk_watch_count\t4\n" +
+		"zk_ephemerals_count\t1\n" +
+		"zk_approximate_data_size\t345\n" +
+		"zk_open_file_descriptor_count\t62\n" +
+		"zk_max_file_descriptor_count\t1048576\n" +
+		"zk_followers\t2\n" +
+		"zk_synced_followers\t2\n" +
+		"zk_pending_syncs\t0\n" +
+		"zk_last_proposal_size\t36\n" +
+		"zk_max_proposal_size\t120\n" +
+		"zk_min_proposal_size\t32\n"
+
+	mntr38Follower = "zk_version\t3.8.1-74db005175a4ec545697012f9069cb9dcc8cdda7, built on 2023-01-25 16:31 UTC\n" +
+		"zk_server_state\tfollower\n" +
+		"zk_peer_state\tfollowing - broadcast\n" +
+		"zk_avg_latency\t0.4213\n" +
+		"zk_max_latency\t12\n" +
+		"zk_min_latency\t0\n" +
+		"zk_packets_received\t56789\n" +
+		"zk_packets_sent\t56790\n" +
+		"zk_num_alive_connections\t2\n" +
+		"zk_outstanding_requests\t0\n" +
+		"zk_znode_count\t42\n" +
+		"zk_watch_count\t7\n" +
+		"zk_ephemerals_count\t3\n" +
+		"zk_approximate_data_size\t4096\n" +
+		"zk_open_file_descriptor_count\t71\n" +
+		"zk_max_file_descriptor_count\t1048576\n" +
+		"zk_uptime\t123456789\n" +
+		"zk_quorum_size\t3\n" +
+		"zk_avg_commit_propagation_latency\t0.0\n" +
+		"zk_cnt_commit_propagation_latency\t0\n"
+
+	mntr38Standalone = "zk_version\t3.8.1-74db005175a4ec545697012f9069cb9dcc8cdda7, built on 2023-01-25 16:31 UTC\n" +
+		"zk_server_state\tstandalone\n" +
+		"zk_avg_latency\t1.0\n" +
+		"zk_max_latency\t3\n" +
+		"zk_min_latency\t0\n" +
+		"zk_packets_received\t13\n" +
+		"zk_packets_sent\t12\n" +
+		"zk_num_alive_connections\t1\n" +
+		"zk_outstanding_requests\t0\n" +
+		"zk_znode_count\t5\n" +
+		"zk_watch_count\t0\n" +
+		"zk_ephemerals_count\t0\n" +
+		"zk_approximate_data_size\t44\n" +
+		"zk_open_file_descriptor_count\t58\n" +
+		"zk_max_file_descriptor_count\t1048576\n"
+
+	cons35 = ` /10.0.0.5:52126[1](queued=0,recved=5,sent=5,sid=0x100000d8c2a0001,lop=PING,est=1700000000000,to=30000,lcxid=0x2,lzxid=0x10000002a,lresp=1700000001000,llat=0,minlat=0,avglat=0,maxlat=2)
+ /10.0.0.6:52140[0](queued=0,recved=1,sent=0)
+
+`
+
+	cons38 = ` /[0:0:0:0:0:0:0:1]:40318[1](queued=0,recved=12,sent=12,sid=0x1000011a5b40000,lop=GETD,est=1700000000000,to=10000,lcxid=0xb,lzxid=0xffffffffffffffff,lresp=1700000002000,llat=1,minlat=0,avglat=1,maxlat=3)
+ /127.0.0.1:40320[0](queued=0,recved=1,sent=0)
+
+`
+
+	conf35 = `clientPort=2181
+secureClientPort=-1
+dataDir=/data/version-2
+dataDirSize=67108880
+dataLogDir=/datalog/version-2
+dataLogSize=67108880
+tickTime=2000
+maxClientCnxns=60
+minSessionTimeout=4000
+maxSessionTimeout=40000
+serverId=1
+initLimit=5
+syncLimit=2
+electionAlg=3
+electionPort=3888
+quorumPort=2888
+peerType=0
+membership:
+server.1=zk1:2888:3888:participant;0.0.0.0:2181
+server.2=zk2:2888:3888:participant;0.0.0.0:2181
+server.3=zk3:2888:3888:participant;0.0.0.0:2181
+version=100000000
+`
+
+	envi38 = `Environment:
+zookeeper.version=3.8.1-74db005175a4ec545697012f9069cb9dcc8cdda7, built on 2023-01-25 16:31 UTC
+host.name=zk1
+java.version=11.0.18
+java.vendor=Eclipse Adoptium
+java.class.path=/apache-zookeeper-3.8.1-bin/bin/../zookeeper-server/target/classes:/apache-zookeeper-3.8.1-bin/bin/../lib/zookeeper-3.8.1.jar
+os.name=Linux
+user.dir=/apache-zookeeper-3.8.1-bin
+os.memory.free=1009MB
+`
+
+	wchs = `2 connections watching 3 paths
+Total watches:4
+`
+
+	notWhitelisted = "mntr is not executed because it is not in the whitelist.\n"
+	notServing     = "This ZooKeeper instance is not currently serving requests\n"
+)
+
+func TestParseSrvr(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    Srvr
+		wantErr bool
+	}{
+		{
+			name: "3.5 follower",
+			s:    srvr35Follower,
+			want: Srvr{
+				Version:     "3.5.9-83df9301aa5c2a5d284a9940177808c01bc35cef, built on 01/06/2021 20:03 GMT",
+				MaxLatency:  12,
+				Received:    1234,
+				Sent:        1233,
+				Connections: 3,
+				Zxid:        0x10000002a,
+				Mode:        "follower",
+				NodeCount:   12,
+			},
+		},
+		{
+			name: "3.8 leader",
+			s:    srvr38Leader,
+			want: Srvr{
+				Version:     "3.8.1-74db005175a4ec545697012f9069cb9dcc8cdda7, built on 2023-01-25 16:31 UTC",
+				AvgLatency:  0.4213,
+				MaxLatency:  12,
+				Received:    56789,
+				Sent:        56790,
+				Connections: 2,
+				Zxid:        0x200000123,
+				Mode:        "leader",
+				NodeCount:   42,
+			},
+		},
+		{
+			name: "3.8 standalone",
+			s:    srvr38Standalone,
+			want: Srvr{
+				Version:     "3.8.1-74db005175a4ec545697012f9069cb9dcc8cdda7, built on 2023-01-25 16:31 UTC",
+				AvgLatency:  1,
+				MaxLatency:  3,
+				Received:    10,
+				Sent:        9,
+				Connections: 1,
+				Zxid:        5,
+				Mode:        "standalone",
+				NodeCount:   5,
+			},
+		},
+		{name: "not in the whitelist", s: notWhitelisted, wantErr: true},
+		{name: "not serving", s: notServing, wantErr: true},
+		{name: "empty", s: "", wantErr: true},
+		{name: "invalid zxid", s: "Zxid: 0xzz\nMode: leader\n", wantErr: true},
+		{name: "no mode", s: "Zookeeper version: 3.8.1\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSrvr(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSrvr() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if *got != tt.want {
+				t.Errorf("ParseSrvr() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+
+	if st, _ := ParseSrvr(srvr38Leader); st.Epoch() != 2 {
+		t.Errorf("Epoch() = %d, want 2", st.Epoch())
+	}
+}
+
+func TestParseStat(t *testing.T) {
+	tests := []struct {
+		name        string
+		s           string
+		wantMode    string
+		wantClients []Connection
+	}{
+		{
+			name:     "3.5 follower",
+			s:        stat35,
+			wantMode: "follower",
+			wantClients: []Connection{
+				{Address: "10.0.0.5:52126", Interest: 1, Received: 5, Sent: 5},
+				{Address: "10.0.0.6:52128", Interest: 0, Received: 1},
+			},
+		},
+		{
+			name:     "3.8 standalone with IPv6 client",
+			s:        stat38Standalone,
+			wantMode: "standalone",
+			wantClients: []Connection{
+				{Address: "[0:0:0:0:0:0:0:1]:40318", Interest: 1, Received: 12, Sent: 12},
+				{Address: "127.0.0.1:40320", Interest: 0, Received: 1},
+			},
+		},
+		{
+			name:        "3.8 leader without clients",
+			s:           srvr38Leader,
+			wantMode:    "leader",
+			wantClients: []Connection{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseStat(tt.s)
+			if err != nil {
+				t.Fatalf("ParseStat() error = %v", err)
+			}
+
+			if got.Mode != tt.wantMode {
+				t.Errorf("Mode = %q, want %q", got.Mode, tt.wantMode)
+			}
+			if len(got.Clients) != len(tt.wantClients) {
+				t.Fatalf("Clients = %d, want %d", len(got.Clients), len(tt.wantClients))
+			}
+			for i, want := range tt.wantClients {
+				c := got.Clients[i]
+				if c.Address != want.Address || c.Interest != want.Interest || c.Received != want.Received || c.Sent != want.Sent {
+					t.Errorf("Clients[%d] = %+v, want %+v", i, *c, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseMntr(t *testing.T) {
+	tests := []struct {
+		name        string
+		s           string
+		wantState   string
+		wantAvg     float64
+		wantZnodes  int64
+		wantSynced  int64
+		wantMetrics map[string]string
+		wantErr     bool
+	}{
+		{
+			name:       "3.5 leader",
+			s:          mntr35Leader,
+			wantState:  "leader",
+			wantZnodes: 12,
+			wantSynced: 2,
+			wantMetrics: map[string]string{
+				"zk_max_proposal_size": "120",
+			},
+		},
+		{
+			name:       "3.8 follower",
+			s:          mntr38Follower,
+			wantState:  "follower",
+			wantAvg:    0.4213,
+			wantZnodes: 42,
+			wantMetrics: map[string]string{
+				"zk_peer_state":                     "following - broadcast",
+				"zk_avg_commit_propagation_latency": "0.0",
+			},
+		},
+		{
+			name:       "3.8 standalone",
+			s:          mntr38Standalone,
+			wantState:  "standalone",
+			wantAvg:    1,
+			wantZnodes: 5,
+		},
+		{name: "not in the whitelist", s: notWhitelisted, wantErr: true},
+		{name: "not tab separated", s: "zk_version 3.8.1\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMntr(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMntr() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if got.ServerState != tt.wantState || got.AvgLatency != tt.wantAvg || got.ZnodeCount != tt.wantZnodes || got.SyncedFollowers != tt.wantSynced {
+				t.Errorf("ParseMntr() = %+v", got)
+			}
+			if got.Version == "" || got.MaxFileDescriptorCount != 1048576 {
+				t.Errorf("ParseMntr() = %+v", got)
+			}
+			for k, v := range tt.wantMetrics {
+				if got.Metrics[k] != v {
+					t.Errorf("Metrics[%s] = %q, want %q", k, got.Metrics[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestNewMntr(t *testing.T) {
+	// the keys of the AdminServer monitor command have no zk_ prefix
+	m := NewMntr(map[string]string{"server_state": "leader", "znode_count": "7", "zk_version": "3.8.1"})
+	if m.ServerState != "leader" || m.ZnodeCount != 7 || m.Version != "3.8.1" || m.Metrics["zk_znode_count"] != "7" {
+		t.Errorf("NewMntr() = %+v", m)
+	}
+}
+
+func TestParseCons(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    []Connection
+		wantErr bool
+	}{
+		{
+			name: "3.5",
+			s:    cons35,
+			want: []Connection{
+				{Address: "10.0.0.5:52126", Interest: 1, Received: 5, Sent: 5, SessionID: "0x100000d8c2a0001", LastOp: "PING", Timeout: 30000, MaxLatency: 2},
+				{Address: "10.0.0.6:52140", Received: 1},
+			},
+		},
+		{
+			name: "3.8 with IPv6 client",
+			s:    cons38,
+			want: []Connection{
+				{Address: "[0:0:0:0:0:0:0:1]:40318", Interest: 1, Received: 12, Sent: 12, SessionID: "0x1000011a5b40000", LastOp: "GETD", Timeout: 10000, LastLatency: 1, AvgLatency: 1, MaxLatency: 3},
+				{Address: "127.0.0.1:40320", Received: 1},
+			},
+		},
+		{name: "no connection", s: "\n", wantErr: true},
+		{name: "invalid", s: " /10.0.0.5:52126(queued=0)\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCons(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCons() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseCons() = %d connections, want %d", len(got), len(tt.want))
+			}
+			for i, want := range tt.want {
+				c := *got[i]
+				stats := c.Stats
+				c.Stats, want.Stats = nil, nil
+				if !equalConnection(c, want) {
+					t.Errorf("ParseCons()[%d] = %+v, want %+v", i, c, want)
+				}
+				if stats["queued"] != "0" {
+					t.Errorf("ParseCons()[%d].Stats = %v", i, stats)
+				}
+			}
+		})
+	}
+}
+
+func equalConnection(a, b Connection) bool {
+	return a.Address == b.Address && a.Interest == b.Interest && a.Queued == b.Queued &&
+		a.Received == b.Received && a.Sent == b.Sent && a.SessionID == b.SessionID &&
+		a.LastOp == b.LastOp && a.Timeout == b.Timeout && a.LastLatency == b.LastLatency &&
+		a.MinLatency == b.MinLatency && a.AvgLatency == b.AvgLatency && a.MaxLatency == b.MaxLatency
+}
+
+func TestParseProperties(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want map[string]string
+		len  int
+	}{
+		{
+			name: "3.5 conf",
+			s:    conf35,
+			want: map[string]string{
+				"clientPort": "2181",
+				"dataLogDir": "/datalog/version-2",
+				"server.2":   "zk2:2888:3888:participant;0.0.0.0:2181",
+				"version":    "100000000",
+			},
+			len: 21,
+		},
+		{
+			name: "3.8 envi",
+			s:    envi38,
+			want: map[string]string{
+				"zookeeper.version": "3.8.1-74db005175a4ec545697012f9069cb9dcc8cdda7, built on 2023-01-25 16:31 UTC",
+				"java.vendor":       "Eclipse Adoptium",
+				"os.memory.free":    "1009MB",
+			},
+			len: 8,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseProperties(tt.s)
+			if err != nil {
+				t.Fatalf("ParseProperties() error = %v", err)
+			}
+
+			if len(got) != tt.len {
+				t.Errorf("ParseProperties() = %d properties, want %d", len(got), tt.len)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("ParseProperties()[%s] = %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+
+	if _, err := ParseProperties(notWhitelisted); err == nil {
+		t.Errorf("ParseProperties() error = nil")
+	}
+}
+
+func TestParseWchs(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    Wchs
+		wantErr bool
+	}{
+		{name: "watches", s: wchs, want: Wchs{Connections: 2, Paths: 3, Watches: 4}},
+		{name: "no watch", s: "0 connections watching 0 paths\nTotal watches:0\n", want: Wchs{}},
+		{name: "not in the whitelist", s: notWhitelisted, wantErr: true},
+		{name: "invalid", s: "2 connections watching\n", wantErr: true},
+		{name: "invalid total", s: "Total watches:x\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWchs(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWchs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && *got != tt.want {
+				t.Errorf("ParseWchs() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,394 @@
+// Package health check the health of a zookeeper ensemble by the four letter
+// word commands, the result status is OK, WARN or CRIT like the monitoring plugins.
+package health
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benzimu/zkcmd/common/flw"
+	"github.com/pkg/errors"
+)
+
+// Status is the status of a check, it is the exit code of the monitoring plugins
+type Status int
+
+const (
+	OK Status = iota
+	Warn
+	Crit
+)
+
+func (s Status) String() string {
+	switch s {
+	case OK:
+		return "OK"
+	case Warn:
+		return "WARN"
+	}
+
+	return "CRIT"
+}
+
+// MarshalText encode the status as its name
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+const (
+	modeLeader     = "leader"
+	modeStandalone = "standalone"
+)
+
+// Thresholds are the WARN and CRIT thresholds of the checks, a check is
+// skipped if both of its thresholds are 0
+type Thresholds struct {
+	// ZxidSkewWarn and ZxidSkewCrit are the transactions a server is behind the latest zxid
+	ZxidSkewWarn int64 `json:"zxidSkewWarn" yaml:"zxidSkewWarn"`
+	ZxidSkewCrit int64 `json:"zxidSkewCrit" yaml:"zxidSkewCrit"`
+	// LatencyWarn and LatencyCrit are the average request latency in milliseconds
+	LatencyWarn float64 `json:"latencyWarn" yaml:"latencyWarn"`
+	LatencyCrit float64 `json:"latencyCrit" yaml:"latencyCrit"`
+	// OutstandingWarn and OutstandingCrit are the queued requests
+	OutstandingWarn int64 `json:"outstandingWarn" yaml:"outstandingWarn"`
+	OutstandingCrit int64 `json:"outstandingCrit" yaml:"outstandingCrit"`
+	// FDRatioWarn and FDRatioCrit are the ratio of open to max file descriptors
+	FDRatioWarn float64 `json:"fdRatioWarn" yaml:"fdRatioWarn"`
+	FDRatioCrit float64 `json:"fdRatioCrit" yaml:"fdRatioCrit"`
+}
+
+// DefaultThresholds are the default thresholds of the checks
+var DefaultThresholds = Thresholds{
+	ZxidSkewWarn:    100,
+	ZxidSkewCrit:    1000,
+	LatencyWarn:     100,
+	LatencyCrit:     500,
+	OutstandingWarn: 10,
+	OutstandingCrit: 100,
+	FDRatioWarn:     0.8,
+	FDRatioCrit:     0.95,
+}
+
+// Server is the collected state of a server, Mntr is nil if neither mntr nor
+// the monitor fallback is available. RuokSkipped is true if ruok is not in the
+// whitelist and there is no fallback, the server is checked by srvr then.
+type Server struct {
+	Server      string    `json:"server" yaml:"server"`
+	Ruok        bool      `json:"ruok" yaml:"ruok"`
+	RuokSkipped bool      `json:"ruokSkipped,omitempty" yaml:"ruokSkipped,omitempty"`
+	Srvr        *flw.Srvr `json:"srvr,omitempty" yaml:"srvr,omitempty"`
+	Mntr        *flw.Mntr `json:"mntr,omitempty" yaml:"mntr,omitempty"`
+	Errors      []string  `json:"errors,omitempty" yaml:"errors,omitempty"`
+}
+
+// Mode return the mode of srvr or mntr, it is empty if unknown
+func (s *Server) Mode() string {
+	if s.Srvr != nil {
+		return s.Srvr.Mode
+	}
+	if s.Mntr != nil {
+		return s.Mntr.ServerState
+	}
+
+	return ""
+}
+
+// Reachable report whether the server is running and serving requests, the
+// mode is known only if the server is serving
+func (s *Server) Reachable() bool {
+	return (s.Ruok || s.RuokSkipped) && s.Mode() != ""
+}
+
+// CollectOptions control how the servers are collected
+type CollectOptions struct {
+	// Timeout is the timeout of every command. (default flw.DefaultTimeout)
+	Timeout time.Duration
+	// Monitor get the metrics of the server from elsewhere if mntr failed,
+	// like the AdminServer monitor command
+	Monitor func(server string) (*flw.Mntr, error)
+	// Ruok check the server from elsewhere if ruok is not in the whitelist,
+	// like the AdminServer ruok command
+	Ruok func(server string) error
+}
+
+// Collect run ruok, srvr and mntr on the servers concurrently
+func Collect(servers []string, opts CollectOptions) []*Server {
+	res := make([]*Server, len(servers))
+
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func(i int, server string) {
+			defer wg.Done()
+
+			res[i] = collect(server, opts)
+		}(i, server)
+	}
+	wg.Wait()
+
+	return res
+}
+
+func collect(server string, opts CollectOptions) *Server {
+	s := &Server{Server: server}
+
+	err := flw.Ruok(server, opts.Timeout)
+	if flw.IsNotWhitelisted(err) {
+		if opts.Ruok != nil {
+			err = opts.Ruok(server)
+		}
+
+		// decided by srvr or mntr then
+		s.RuokSkipped = err != nil
+		err = nil
+	}
+	if err != nil {
+		s.Errors = append(s.Errors, errors.Wrap(err, "ruok").Error())
+		return s
+	}
+	s.Ruok = !s.RuokSkipped
+
+	out, err := flw.Exec(server, "srvr", opts.Timeout)
+	if err == nil {
+		s.Srvr, err = flw.ParseSrvr(out)
+	}
+	if err != nil {
+		s.Errors = append(s.Errors, errors.Wrap(err, "srvr").Error())
+	}
+
+	out, err = flw.Exec(server, "mntr", opts.Timeout)
+	if err == nil {
+		s.Mntr, err = flw.ParseMntr(out)
+	}
+	if err != nil && opts.Monitor != nil {
+		s.Mntr, err = opts.Monitor(server)
+	}
+	if err != nil {
+		s.Errors = append(s.Errors, errors.Wrap(err, "mntr").Error())
+	}
+
+	return s
+}
+
+// Check is the result of a check, Server is empty for the ensemble checks
+type Check struct {
+	Name    string `json:"name" yaml:"name"`
+	Server  string `json:"server,omitempty" yaml:"server,omitempty"`
+	Status  Status `json:"status" yaml:"status"`
+	Message string `json:"message" yaml:"message"`
+}
+
+// Report is the result of all the checks, Status is the worst status of the checks
+type Report struct {
+	Status     Status     `json:"status" yaml:"status"`
+	Summary    string     `json:"summary" yaml:"summary"`
+	Thresholds Thresholds `json:"thresholds" yaml:"thresholds"`
+	Checks     []*Check   `json:"checks" yaml:"checks"`
+	Servers    []*Server  `json:"servers" yaml:"servers"`
+}
+
+func (r *Report) add(name, server string, status Status, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, &Check{
+		Name:    name,
+		Server:  server,
+		Status:  status,
+		Message: fmt.Sprintf(format, args...),
+	})
+
+	if status > r.Status {
+		r.Status = status
+	}
+}
+
+// Evaluate check the quorum, the followers, the zxid skew, the latency, the
+// outstanding requests and the file descriptors of the servers
+func Evaluate(servers []*Server, t Thresholds) *Report {
+	r := &Report{Thresholds: t, Checks: make([]*Check, 0), Servers: servers}
+
+	reachable := make([]*Server, 0, len(servers))
+	for _, s := range servers {
+		if s.Reachable() {
+			reachable = append(reachable, s)
+		} else {
+			r.add("ruok", s.Server, Crit, "not serving: %s", strings.Join(s.Errors, "; "))
+		}
+	}
+
+	leaders := evaluateQuorum(r, servers, reachable)
+	for _, s := range leaders {
+		evaluateFollowers(r, s)
+	}
+
+	evaluateZxid(r, reachable, t)
+
+	for _, s := range reachable {
+		evaluateServer(r, s, t)
+	}
+
+	r.Summary = summary(r, servers, reachable, leaders)
+	return r
+}
+
+// evaluateQuorum check the majority of the servers are serving and there is
+// exactly one leader, return the leaders
+func evaluateQuorum(r *Report, servers, reachable []*Server) []*Server {
+	leaders := make([]*Server, 0, 1)
+	for _, s := range reachable {
+		if s.Mode() == modeLeader {
+			leaders = append(leaders, s)
+		}
+	}
+
+	if len(servers) == 1 && len(reachable) == 1 && reachable[0].Mode() == modeStandalone {
+		r.add("quorum", "", OK, "standalone server")
+		return leaders
+	}
+
+	switch quorum := len(servers)/2 + 1; {
+	case len(reachable) < quorum:
+		r.add("quorum", "", Crit, "%d of %d servers serving, quorum is %d", len(reachable), len(servers), quorum)
+	case len(reachable) < len(servers):
+		r.add("quorum", "", Warn, "%d of %d servers serving", len(reachable), len(servers))
+	default:
+		r.add("quorum", "", OK, "%d of %d servers serving", len(reachable), len(servers))
+	}
+
+	switch len(leaders) {
+	case 0:
+		r.add("leader", "", Crit, "no leader")
+	case 1:
+		r.add("leader", leaders[0].Server, OK, "one leader")
+	default:
+		names := make([]string, len(leaders))
+		for i, s := range leaders {
+			names[i] = s.Server
+		}
+		r.add("leader", "", Crit, "%d leaders: %s", len(leaders), strings.Join(names, ", "))
+	}
+
+	return leaders
+}
+
+// evaluateFollowers check the followers of the leader are synced
+func evaluateFollowers(r *Report, leader *Server) {
+	m := leader.Mntr
+	if m == nil {
+		return
+	}
+
+	switch {
+	case m.SyncedFollowers < m.Followers:
+		r.add("followers", leader.Server, Warn, "%d of %d followers synced", m.SyncedFollowers, m.Followers)
+	case m.PendingSyncs > 0:
+		r.add("followers", leader.Server, Warn, "%d pending syncs", m.PendingSyncs)
+	default:
+		r.add("followers", leader.Server, OK, "%d of %d followers synced", m.SyncedFollowers, m.Followers)
+	}
+}
+
+// evaluateZxid check the servers are not behind the latest zxid, the server
+// of an old epoch is CRIT
+func evaluateZxid(r *Report, reachable []*Server, t Thresholds) {
+	if t.ZxidSkewWarn <= 0 && t.ZxidSkewCrit <= 0 {
+		return
+	}
+
+	var latest int64
+	for _, s := range reachable {
+		if s.Srvr != nil && s.Srvr.Zxid > latest {
+			latest = s.Srvr.Zxid
+		}
+	}
+
+	for _, s := range reachable {
+		if s.Srvr == nil {
+			continue
+		}
+
+		if s.Srvr.Epoch() != latest>>32 {
+			r.add("zxid", s.Server, Crit, "epoch %d behind the latest epoch %d", s.Srvr.Epoch(), latest>>32)
+			continue
+		}
+
+		skew := latest - s.Srvr.Zxid
+		r.add("zxid", s.Server, thresholdStatus(float64(skew), float64(t.ZxidSkewWarn), float64(t.ZxidSkewCrit)),
+			"%d transactions behind 0x%x", skew, latest)
+	}
+}
+
+// evaluateServer check the latency, outstanding requests and file descriptors of the server
+func evaluateServer(r *Report, s *Server, t Thresholds) {
+	latency, outstanding := -1.0, int64(-1)
+	switch {
+	case s.Mntr != nil:
+		latency, outstanding = s.Mntr.AvgLatency, s.Mntr.OutstandingRequests
+	case s.Srvr != nil:
+		latency, outstanding = s.Srvr.AvgLatency, s.Srvr.Outstanding
+	}
+
+	if latency >= 0 && (t.LatencyWarn > 0 || t.LatencyCrit > 0) {
+		r.add("latency", s.Server, thresholdStatus(latency, t.LatencyWarn, t.LatencyCrit), "average latency %gms", latency)
+	}
+
+	if outstanding >= 0 && (t.OutstandingWarn > 0 || t.OutstandingCrit > 0) {
+		r.add("outstanding", s.Server, thresholdStatus(float64(outstanding), float64(t.OutstandingWarn), float64(t.OutstandingCrit)),
+			"%d outstanding requests", outstanding)
+	}
+
+	if m := s.Mntr; m != nil && m.MaxFileDescriptorCount > 0 && (t.FDRatioWarn > 0 || t.FDRatioCrit > 0) {
+		ratio := float64(m.OpenFileDescriptorCount) / float64(m.MaxFileDescriptorCount)
+		r.add("fd", s.Server, thresholdStatus(ratio, t.FDRatioWarn, t.FDRatioCrit),
+			"%d of %d file descriptors open (%.1f%%)", m.OpenFileDescriptorCount, m.MaxFileDescriptorCount, ratio*100)
+	}
+}
+
+// thresholdStatus return the status of the value, the threshold 0 is disabled
+func thresholdStatus(v, warn, crit float64) Status {
+	switch {
+	case crit > 0 && v >= crit:
+		return Crit
+	case warn > 0 && v >= warn:
+		return Warn
+	}
+
+	return OK
+}
+
+// summary return the one line summary, the problems are listed by severity
+func summary(r *Report, servers, reachable, leaders []*Server) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: %d/%d servers serving", r.Status, len(reachable), len(servers))
+	if len(leaders) == 1 {
+		fmt.Fprintf(&sb, ", leader %s", leaders[0].Server)
+	}
+
+	problems := make([]*Check, 0)
+	for _, c := range r.Checks {
+		if c.Status != OK {
+			problems = append(problems, c)
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Status > problems[j].Status })
+
+	for i, c := range problems {
+		if i == 0 {
+			sb.WriteString(" - ")
+		} else {
+			sb.WriteString("; ")
+		}
+		sb.WriteString(c.String())
+	}
+
+	return sb.String()
+}
+
+func (c *Check) String() string {
+	if c.Server == "" {
+		return fmt.Sprintf("%s: %s", c.Name, c.Message)
+	}
+
+	return fmt.Sprintf("%s %s: %s", c.Server, c.Name, c.Message)
+}
@@ -0,0 +1,352 @@
+package health
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/benzimu/zkcmd/common/flw"
+)
+
+// server return a serving server of the mode and zxid, the metrics are
+// below the default thresholds
+func server(name, mode string, zxid int64) *Server {
+	return &Server{
+		Server: name,
+		Ruok:   true,
+		Srvr:   &flw.Srvr{Mode: mode, Zxid: zxid, AvgLatency: 1},
+		Mntr: &flw.Mntr{
+			ServerState:             mode,
+			AvgLatency:              1,
+			OpenFileDescriptorCount: 100,
+			MaxFileDescriptorCount:  1000,
+		},
+	}
+}
+
+// down return a server not serving
+func down(name string) *Server {
+	return &Server{Server: name, Errors: []string{"ruok: connection refused"}}
+}
+
+// with modify the server and return it
+func with(s *Server, fn func(s *Server)) *Server {
+	fn(s)
+	return s
+}
+
+// ensemble return a leader with synced followers and two followers
+func ensemble() []*Server {
+	leader := server("zk1", "leader", 0x100001000)
+	leader.Mntr.Followers, leader.Mntr.SyncedFollowers = 2, 2
+
+	return []*Server{leader, server("zk2", "follower", 0x100001000), server("zk3", "follower", 0x100001000)}
+}
+
+// ensembleWith return the ensemble modified by fn
+func ensembleWith(fn func(s []*Server)) []*Server {
+	s := ensemble()
+	fn(s)
+
+	return s
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name    string
+		servers []*Server
+		// thresholds modify the default thresholds
+		thresholds func(t *Thresholds)
+		wantStatus Status
+		// wantChecks are the statuses of the checks by the name and server
+		wantChecks map[[2]string]Status
+		// wantMissing are the checks not done
+		wantMissing [][2]string
+	}{
+		{
+			name:       "healthy",
+			servers:    ensemble(),
+			wantStatus: OK,
+			wantChecks: map[[2]string]Status{
+				{"quorum", ""}:       OK,
+				{"leader", "zk1"}:    OK,
+				{"followers", "zk1"}: OK,
+				{"zxid", "zk3"}:      OK,
+				{"latency", "zk2"}:   OK,
+				{"fd", "zk1"}:        OK,
+			},
+		},
+		{
+			name:        "standalone",
+			servers:     []*Server{server("zk1", "standalone", 5)},
+			wantStatus:  OK,
+			wantChecks:  map[[2]string]Status{{"quorum", ""}: OK},
+			wantMissing: [][2]string{{"leader", ""}},
+		},
+		{
+			name:       "one server down",
+			servers:    append(ensemble()[:2], down("zk3")),
+			wantStatus: Crit,
+			wantChecks: map[[2]string]Status{
+				{"ruok", "zk3"}:   Crit,
+				{"quorum", ""}:    Warn,
+				{"leader", "zk1"}: OK,
+			},
+		},
+		{
+			name:       "quorum lost",
+			servers:    []*Server{ensemble()[0], down("zk2"), down("zk3")},
+			wantStatus: Crit,
+			wantChecks: map[[2]string]Status{{"quorum", ""}: Crit},
+		},
+		{
+			name: "ruok not in the whitelist",
+			servers: []*Server{
+				with(ensemble()[0], func(s *Server) { s.Ruok, s.RuokSkipped = false, true }),
+				with(server("zk2", "follower", 0x100001000), func(s *Server) { s.Ruok, s.RuokSkipped = false, true }),
+				with(server("zk3", "follower", 0x100001000), func(s *Server) { s.Ruok, s.RuokSkipped = false, true }),
+			},
+			wantStatus:  OK,
+			wantChecks:  map[[2]string]Status{{"quorum", ""}: OK, {"leader", "zk1"}: OK},
+			wantMissing: [][2]string{{"ruok", "zk1"}},
+		},
+		{
+			name: "ruok skipped and srvr failed",
+			servers: append(ensemble()[:2], &Server{
+				Server:      "zk3",
+				RuokSkipped: true,
+				Errors:      []string{"srvr: connection reset", "mntr: not in the whitelist"},
+			}),
+			wantStatus: Crit,
+			wantChecks: map[[2]string]Status{{"ruok", "zk3"}: Crit, {"quorum", ""}: Warn},
+		},
+		{
+			name:       "no leader",
+			servers:    []*Server{server("zk1", "follower", 1), server("zk2", "follower", 1), server("zk3", "follower", 1)},
+			wantStatus: Crit,
+			wantChecks: map[[2]string]Status{{"leader", ""}: Crit},
+		},
+		{
+			name:       "two leaders",
+			servers:    []*Server{server("zk1", "leader", 1), server("zk2", "leader", 1), server("zk3", "follower", 1)},
+			wantStatus: Crit,
+			wantChecks: map[[2]string]Status{{"leader", ""}: Crit, {"followers", "zk1"}: OK},
+		},
+		{
+			name: "followers not synced",
+			servers: ensembleWith(func(s []*Server) {
+				s[0].Mntr.SyncedFollowers = 1
+			}),
+			wantStatus: Warn,
+			wantChecks: map[[2]string]Status{{"followers", "zk1"}: Warn},
+		},
+		{
+			name: "pending syncs",
+			servers: ensembleWith(func(s []*Server) {
+				s[0].Mntr.PendingSyncs = 1
+			}),
+			wantStatus: Warn,
+			wantChecks: map[[2]string]Status{{"followers", "zk1"}: Warn},
+		},
+		{
+			name: "old epoch",
+			servers: ensembleWith(func(s []*Server) {
+				s[0].Srvr.Zxid, s[1].Srvr.Zxid = 0x200000001, 0x200000001
+			}),
+			wantStatus: Crit,
+			wantChecks: map[[2]string]Status{{"zxid", "zk1"}: OK, {"zxid", "zk3"}: Crit},
+		},
+		{
+			name: "zxid skew",
+			servers: ensembleWith(func(s []*Server) {
+				s[1].Srvr.Zxid -= 100
+				s[2].Srvr.Zxid -= 1000
+			}),
+			wantStatus: Crit,
+			wantChecks: map[[2]string]Status{{"zxid", "zk1"}: OK, {"zxid", "zk2"}: Warn, {"zxid", "zk3"}: Crit},
+		},
+		{
+			name: "zxid skew and latency disabled",
+			servers: ensembleWith(func(s []*Server) {
+				s[2].Srvr.Zxid -= 1000
+			}),
+			thresholds: func(t *Thresholds) {
+				t.ZxidSkewWarn, t.ZxidSkewCrit = 0, 0
+				t.LatencyWarn, t.LatencyCrit = 0, 0
+			},
+			wantStatus:  OK,
+			wantMissing: [][2]string{{"zxid", "zk3"}, {"latency", "zk3"}},
+		},
+		{
+			name: "latency and outstanding",
+			servers: ensembleWith(func(s []*Server) {
+				s[0].Mntr.AvgLatency = 100
+				s[1].Mntr.AvgLatency = 600
+				s[2].Mntr.OutstandingRequests = 10
+			}),
+			wantStatus: Crit,
+			wantChecks: map[[2]string]Status{
+				{"latency", "zk1"}:     Warn,
+				{"latency", "zk2"}:     Crit,
+				{"outstanding", "zk3"}: Warn,
+			},
+		},
+		{
+			name: "latency of srvr without mntr",
+			servers: ensembleWith(func(s []*Server) {
+				s[1].Mntr = nil
+				s[1].Srvr.Outstanding = 100
+			}),
+			wantStatus:  Crit,
+			wantChecks:  map[[2]string]Status{{"latency", "zk2"}: OK, {"outstanding", "zk2"}: Crit},
+			wantMissing: [][2]string{{"fd", "zk2"}},
+		},
+		{
+			name: "file descriptors",
+			servers: ensembleWith(func(s []*Server) {
+				s[0].Mntr.OpenFileDescriptorCount = 800
+				s[1].Mntr.OpenFileDescriptorCount = 950
+			}),
+			wantStatus: Crit,
+			wantChecks: map[[2]string]Status{{"fd", "zk1"}: Warn, {"fd", "zk2"}: Crit, {"fd", "zk3"}: OK},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thresholds := DefaultThresholds
+			if tt.thresholds != nil {
+				tt.thresholds(&thresholds)
+			}
+
+			r := Evaluate(tt.servers, thresholds)
+			if r.Status != tt.wantStatus {
+				t.Errorf("Status = %s, want %s: %s", r.Status, tt.wantStatus, r.Summary)
+			}
+
+			for key, want := range tt.wantChecks {
+				c := findCheck(r, key)
+				if c == nil {
+					t.Errorf("check %s %s is missing: %s", key[0], key[1], r.Summary)
+					continue
+				}
+				if c.Status != want {
+					t.Errorf("check %s = %s, want %s", c, c.Status, want)
+				}
+			}
+			for _, key := range tt.wantMissing {
+				if c := findCheck(r, key); c != nil {
+					t.Errorf("check %s is done", c)
+				}
+			}
+		})
+	}
+}
+
+func findCheck(r *Report, key [2]string) *Check {
+	for _, c := range r.Checks {
+		if c.Name == key[0] && c.Server == key[1] {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// serve4lw serve the four letter word commands by the responses, the
+// commands not in them are not in the whitelist
+func serve4lw(t *testing.T, responses map[string]string) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			cmd := make([]byte, 4)
+			if _, err := io.ReadFull(conn, cmd); err == nil {
+				res, ok := responses[string(cmd)]
+				if !ok {
+					res = string(cmd) + " is not executed because it is not in the whitelist.\n"
+				}
+				_, _ = conn.Write([]byte(res))
+			}
+			conn.Close()
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func TestCollect(t *testing.T) {
+	srvr := "Zookeeper version: 3.8.1\nLatency min/avg/max: 0/0.5/3\nOutstanding: 0\nZxid: 0x100001000\nMode: follower\nNode count: 5\n"
+
+	tests := []struct {
+		name            string
+		responses       map[string]string
+		ruok            func(server string) error
+		wantRuok        bool
+		wantRuokSkipped bool
+		wantReachable   bool
+	}{
+		{
+			name:          "ruok",
+			responses:     map[string]string{"ruok": "imok", "srvr": srvr},
+			wantRuok:      true,
+			wantReachable: true,
+		},
+		{
+			name:            "only srvr in the whitelist",
+			responses:       map[string]string{"srvr": srvr},
+			wantRuokSkipped: true,
+			wantReachable:   true,
+		},
+		{
+			name:          "ruok of the AdminServer",
+			responses:     map[string]string{"srvr": srvr},
+			ruok:          func(server string) error { return nil },
+			wantRuok:      true,
+			wantReachable: true,
+		},
+		{
+			name:            "the AdminServer failed",
+			responses:       map[string]string{"srvr": srvr},
+			ruok:            func(server string) error { return errors.New("connection refused") },
+			wantRuokSkipped: true,
+			wantReachable:   true,
+		},
+		{
+			name:            "not serving",
+			responses:       map[string]string{"srvr": "This ZooKeeper instance is not currently serving requests\n"},
+			wantRuokSkipped: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := serve4lw(t, tt.responses)
+
+			servers := Collect([]string{addr}, CollectOptions{Timeout: time.Second, Ruok: tt.ruok})
+			s := servers[0]
+			if s.Ruok != tt.wantRuok || s.RuokSkipped != tt.wantRuokSkipped || s.Reachable() != tt.wantReachable {
+				t.Errorf("Collect() = %+v, reachable %v", s, s.Reachable())
+			}
+		})
+	}
+
+	addr := serve4lw(t, map[string]string{"srvr": srvr})
+	r := Evaluate(Collect([]string{addr}, CollectOptions{Timeout: time.Second}), DefaultThresholds)
+	if c := findCheck(r, [2]string{"ruok", addr}); c != nil {
+		t.Errorf("check %s is done", c)
+	}
+}
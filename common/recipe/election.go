@@ -0,0 +1,73 @@
+package recipe
+
+import (
+	"context"
+
+	"github.com/benzimu/zkcmd/common/zookeeper"
+	"github.com/pkg/errors"
+)
+
+// candidatePrefix is the name prefix of the election candidate znodes
+const candidatePrefix = "n_"
+
+var (
+	// ErrLeadershipLost is returned if the candidate znode is deleted, the
+	// session is expired, or the client is disconnected longer than the session
+	// timeout while leading
+	ErrLeadershipLost = errors.New("the leadership is lost")
+	// ErrNotLeader is returned if the candidate resigns without leading
+	ErrNotLeader = errors.New("not the leader")
+)
+
+// Election is the leader election recipe, it is the exclusive lock of the
+// election path: every candidate creates an ephemeral sequential znode, the
+// lowest is the leader and the others watch the znode before them.
+type Election struct {
+	lock *Lock
+}
+
+// NewElection return the election of the path, data is saved in the
+// candidate znode, like the json of Holder
+func NewElection(c *zookeeper.Client, path string, data []byte) (*Election, error) {
+	if err := zookeeper.ValidatePath(path, false); err != nil {
+		return nil, err
+	}
+
+	return &Election{&Lock{c: c, path: path, mode: LockExclusive, prefix: candidatePrefix, data: data}}, nil
+}
+
+// Node return the path of the candidate znode, it is empty if not leading
+func (e *Election) Node() string {
+	return e.lock.Node()
+}
+
+// Campaign create the candidate znode and wait until elected or ctx is done,
+// the candidate znode is deleted if not elected
+func (e *Election) Campaign(ctx context.Context) error {
+	return e.lock.Acquire(ctx)
+}
+
+// Lost return a channel which is closed if the leadership is lost after
+// elected
+func (e *Election) Lost() <-chan struct{} {
+	return e.lock.Lost()
+}
+
+// Resign delete the candidate znode so the next candidate is elected,
+// ErrLeadershipLost is returned if the leadership has been lost
+func (e *Election) Resign() error {
+	switch err := e.lock.Release(); err {
+	case ErrLockLost:
+		return ErrLeadershipLost
+	case ErrNotLocked:
+		return ErrNotLeader
+	default:
+		return err
+	}
+}
+
+// Candidates return the candidates of the election sorted by sequence, the
+// leader is the first one and Held
+func Candidates(c *zookeeper.Client, electionPath string) ([]*Contender, error) {
+	return listContenders(c, electionPath)
+}
@@ -0,0 +1,428 @@
+// Package recipe implement the zookeeper recipes on zookeeper.Client, see:
+// https://zookeeper.apache.org/doc/current/recipes.html
+package recipe
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/user"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benzimu/zkcmd/common/zookeeper"
+	"github.com/go-zookeeper/zk"
+	"github.com/pkg/errors"
+)
+
+// the lock modes, the shared locks are held together and exclude the
+// exclusive lock, like the read and write locks
+const (
+	LockExclusive = "exclusive"
+	LockShared    = "shared"
+)
+
+// the name prefixes of the lock znodes
+const (
+	writePrefix = "write-"
+	readPrefix  = "read-"
+)
+
+// sequenceLen is the length of the sequence suffix of the sequential znodes
+const sequenceLen = 10
+
+var (
+	// ErrLockLost is returned if the lock znode is deleted, the session is
+	// expired, or the client is disconnected longer than the session timeout
+	// while waiting or holding the lock
+	ErrLockLost = errors.New("the lock is lost")
+	// ErrNotLocked is returned if the lock is released without acquiring
+	ErrNotLocked = errors.New("the lock is not acquired")
+)
+
+// Holder is the metadata of the lock holder or the election candidate saved
+// in the znode data
+type Holder struct {
+	Host    string    `json:"host" yaml:"host"`
+	PID     int       `json:"pid" yaml:"pid"`
+	User    string    `json:"user,omitempty" yaml:"user,omitempty"`
+	ID      string    `json:"id,omitempty" yaml:"id,omitempty"`
+	Command string    `json:"command,omitempty" yaml:"command,omitempty"`
+	Time    time.Time `json:"time" yaml:"time"`
+}
+
+// NewHolder return the holder of the current process
+func NewHolder(id, command string) *Holder {
+	h := &Holder{PID: os.Getpid(), ID: id, Command: command, Time: time.Now()}
+	h.Host, _ = os.Hostname()
+	if u, err := user.Current(); err == nil {
+		h.User = u.Username
+	}
+
+	return h
+}
+
+// ParseHolder parse the holder of the znode data, nil if it is not a holder
+func ParseHolder(data []byte) *Holder {
+	var h Holder
+	if err := json.Unmarshal(data, &h); err != nil || h.Host == "" {
+		return nil
+	}
+
+	return &h
+}
+
+// Lock is the lock recipe, the lock is held by the ephemeral sequential znode
+// under the lock path, and every contender watches the znode before it.
+type Lock struct {
+	c      *zookeeper.Client
+	path   string
+	mode   string
+	prefix string
+	data   []byte
+
+	node string
+	lost chan struct{}
+	stop chan struct{}
+}
+
+// NewLock return the lock of the path in the mode, data is saved in the lock
+// znode, like the json of Holder
+func NewLock(c *zookeeper.Client, path, mode string, data []byte) (*Lock, error) {
+	if err := zookeeper.ValidatePath(path, false); err != nil {
+		return nil, err
+	}
+	if mode != LockExclusive && mode != LockShared {
+		return nil, errors.Errorf("unknown lock mode: %s", mode)
+	}
+
+	prefix := writePrefix
+	if mode == LockShared {
+		prefix = readPrefix
+	}
+
+	return &Lock{c: c, path: path, mode: mode, prefix: prefix, data: data}, nil
+}
+
+// Node return the path of the lock znode, it is empty if not acquired
+func (l *Lock) Node() string {
+	return l.node
+}
+
+// Acquire create the lock znode and wait until the lock is held or ctx is
+// done, the lock znode is deleted if the lock is not held. The lock path is
+// created if not exists.
+func (l *Lock) Acquire(ctx context.Context) error {
+	if l.node != "" {
+		return errors.New("the lock is already acquired")
+	}
+
+	if err := l.c.ForceCreate(l.path, nil, 0, zk.WorldACL(zk.PermAll)); err != nil {
+		return errors.Wrapf(err, "create the lock path %s", l.path)
+	}
+
+	node, err := l.c.CreateProtectedEphemeralSequential(path.Join(l.path, l.prefix), l.data, zk.WorldACL(zk.PermAll))
+	if err != nil {
+		return errors.Wrap(err, "create the lock znode")
+	}
+
+	if err := l.wait(ctx, node); err != nil {
+		if derr := l.c.Delete(node, -1); derr != nil && derr != zk.ErrNoNode {
+			return errors.Wrapf(err, "delete the lock znode %s: %v", node, derr)
+		}
+		return err
+	}
+
+	l.node = node
+	l.lost = make(chan struct{})
+	l.stop = make(chan struct{})
+	go l.watch()
+
+	return nil
+}
+
+// wait wait until no contender before the node blocks it
+func (l *Lock) wait(ctx context.Context, node string) error {
+	for {
+		contenders, err := l.contenders()
+		if err != nil {
+			return err
+		}
+
+		blocker, err := blockerOf(contenders, path.Base(node))
+		if err != nil {
+			return err
+		}
+		if blocker == "" {
+			return nil
+		}
+
+		exist, _, ch, err := l.c.ExistsW(path.Join(l.path, blocker))
+		if err != nil {
+			return err
+		}
+		if !exist {
+			continue
+		}
+
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// blockerOf return the contender the node waits for, the exclusive lock waits
+// for the contender before it, and the shared lock waits for the exclusive
+// contender before it. It is empty if the lock is held. ErrLockLost is
+// returned if the node is not a contender, like deleted by the session
+// expiration.
+func blockerOf(contenders []string, node string) (string, error) {
+	i := 0
+	for i < len(contenders) && contenders[i] != node {
+		i++
+	}
+	if i == len(contenders) {
+		return "", ErrLockLost
+	}
+
+	if !strings.Contains(node, readPrefix) {
+		if i > 0 {
+			return contenders[i-1], nil
+		}
+		return "", nil
+	}
+
+	for j := i - 1; j >= 0; j-- {
+		if strings.Contains(contenders[j], writePrefix) {
+			return contenders[j], nil
+		}
+	}
+
+	return "", nil
+}
+
+// contenders return the lock znode names sorted by sequence
+func (l *Lock) contenders() ([]string, error) {
+	children, _, err := l.c.Children(l.path)
+	if err != nil {
+		return nil, err
+	}
+
+	return sortBySequence(children), nil
+}
+
+// sortBySequence return the sequential znode names sorted by the sequence
+// suffix, the other names are skipped
+func sortBySequence(names []string) []string {
+	res := make([]string, 0, len(names))
+	for _, n := range names {
+		if _, ok := sequenceOf(n); ok {
+			res = append(res, n)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		a, _ := sequenceOf(res[i])
+		b, _ := sequenceOf(res[j])
+		return a < b
+	})
+
+	return res
+}
+
+// sequenceOf return the sequence suffix of the znode name
+func sequenceOf(name string) (int64, bool) {
+	if len(name) < sequenceLen {
+		return 0, false
+	}
+
+	seq, err := strconv.ParseInt(name[len(name)-sequenceLen:], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return seq, true
+}
+
+// watch close lost if the lock znode is deleted, the session is expired, or
+// the client is disconnected until the session would have been expired by the
+// server, the server may grant the lock to others meanwhile
+func (l *Lock) watch() {
+	events, unsubscribe := l.c.SessionEvents()
+	defer unsubscribe()
+
+	deleted := make(chan struct{})
+	go l.watchNode(deleted)
+
+	var (
+		timer  *time.Timer
+		expire <-chan time.Time
+	)
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case ev := <-events:
+			switch ev.State {
+			case zk.StateExpired:
+				close(l.lost)
+				return
+			case zk.StateDisconnected:
+				if timer == nil {
+					timer = time.NewTimer(time.Until(l.c.LastReceived().Add(l.c.SessionTimeout())))
+					expire = timer.C
+				}
+			case zk.StateHasSession:
+				if timer != nil {
+					timer.Stop()
+					timer, expire = nil, nil
+				}
+			}
+		case <-expire:
+			close(l.lost)
+			return
+		case <-deleted:
+			close(l.lost)
+			return
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// watchNode close deleted if the lock znode is deleted, the requests block
+// while disconnected, so it is watched apart from the session events
+func (l *Lock) watchNode(deleted chan struct{}) {
+	for {
+		exist, _, ch, err := l.c.ExistsW(l.node)
+		if err == nil && !exist {
+			close(deleted)
+			return
+		}
+		if err != nil {
+			// retry after reconnected, the expired session is handled by watch
+			ch = nil
+		}
+
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// Lost return a channel which is closed if the lock is lost after acquired
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Release delete the lock znode, ErrLockLost is returned if the lock has
+// been lost. The lock znode is deleted even if it is lost by the client side
+// timer, the session may survive the reconnection and keep the znode.
+func (l *Lock) Release() error {
+	if l.node == "" {
+		return ErrNotLocked
+	}
+
+	close(l.stop)
+	node := l.node
+	l.node = ""
+
+	select {
+	case <-l.lost:
+		// the znode is deleted now if the session survived, or by the server
+		// with the expired session if it is not deleted in the session timeout
+		done := make(chan struct{})
+		go func() {
+			_ = l.c.Delete(node, -1)
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(l.c.SessionTimeout()):
+		}
+		return ErrLockLost
+	default:
+	}
+
+	err := l.c.Delete(node, -1)
+	if err == zk.ErrNoNode {
+		return ErrLockLost
+	}
+
+	return err
+}
+
+// Contender is a lock znode or an election candidate
+type Contender struct {
+	Node     string  `json:"node" yaml:"node"`
+	Sequence int64   `json:"sequence" yaml:"sequence"`
+	Mode     string  `json:"mode,omitempty" yaml:"mode,omitempty"`
+	Held     bool    `json:"held" yaml:"held"`
+	Session  int64   `json:"session" yaml:"session"`
+	Holder   *Holder `json:"holder,omitempty" yaml:"holder,omitempty"`
+	Data     []byte  `json:"-" yaml:"-"`
+}
+
+// LockStatus return the contenders of the lock sorted by sequence, the
+// holders are Held
+func LockStatus(c *zookeeper.Client, lockPath string) ([]*Contender, error) {
+	contenders, err := listContenders(c, lockPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ct := range contenders {
+		ct.Mode = LockExclusive
+		if strings.Contains(path.Base(ct.Node), readPrefix) {
+			ct.Mode = LockShared
+		}
+	}
+
+	return contenders, nil
+}
+
+// listContenders return the contenders under the path sorted by sequence
+func listContenders(c *zookeeper.Client, p string) ([]*Contender, error) {
+	children, _, err := c.Children(p)
+	if err != nil {
+		return nil, err
+	}
+
+	names := sortBySequence(children)
+	res := make([]*Contender, 0, len(names))
+	for _, name := range names {
+		data, stat, err := c.Get(path.Join(p, name))
+		if err == zk.ErrNoNode {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		seq, _ := sequenceOf(name)
+		blocker, _ := blockerOf(names, name)
+		res = append(res, &Contender{
+			Node:     path.Join(p, name),
+			Sequence: seq,
+			Held:     blocker == "",
+			Session:  stat.EphemeralOwner,
+			Holder:   ParseHolder(data),
+			Data:     data,
+		})
+	}
+
+	return res, nil
+}
@@ -0,0 +1,271 @@
+package snapshot
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	snapshotPrefix = "snapshot."
+	txnLogPrefix   = "log."
+
+	// versionDir is the sub directory of the data dir of the server
+	versionDir = "version-2"
+)
+
+// parseFileZxid parse the hex zxid of the file name like snapshot.1a00000000
+// or snapshot.1a00000000.gz
+func parseFileZxid(name, prefix string) (int64, bool) {
+	if !strings.HasPrefix(name, prefix) {
+		return 0, false
+	}
+
+	name = strings.TrimPrefix(name, prefix)
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		name = name[:i]
+	}
+
+	zxid, err := strconv.ParseInt(name, 16, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return zxid, true
+}
+
+// zxidFile is a snapshot or log file of the data dir
+type zxidFile struct {
+	path string
+	zxid int64
+}
+
+// listFiles list the files of the prefix sorted by zxid, the version-2 sub
+// directory is used if it exists
+func listFiles(dir, prefix string) ([]zxidFile, error) {
+	if fi, err := os.Stat(filepath.Join(dir, versionDir)); err == nil && fi.IsDir() {
+		dir = filepath.Join(dir, versionDir)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []zxidFile
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if zxid, ok := parseFileZxid(e.Name(), prefix); ok {
+			files = append(files, zxidFile{path: filepath.Join(dir, e.Name()), zxid: zxid})
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].zxid < files[j].zxid })
+
+	return files, nil
+}
+
+// TxnLogs return the log files of the data dir sorted by zxid, or the source
+// itself if it is a file
+func TxnLogs(source string) ([]string, error) {
+	fi, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return []string{source}, nil
+	}
+
+	files, err := listFiles(source, txnLogPrefix)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errors.Errorf("no transaction log in %s", source)
+	}
+
+	logs := make([]string, 0, len(files))
+	for _, f := range files {
+		logs = append(logs, f.path)
+	}
+
+	return logs, nil
+}
+
+// ReadTxns read the transactions of the log files in order, the reading is
+// stopped if fn returns error
+func ReadTxns(logs []string, fn func(*Txn) error) error {
+	for _, file := range logs {
+		if err := readTxnLog(file, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readTxnLog(file string, fn func(*Txn) error) error {
+	l, err := OpenTxnLog(file)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	for {
+		txn, err := l.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := fn(txn); err != nil {
+			return err
+		}
+	}
+}
+
+// errStop stop reading the transactions
+var errStop = errors.New("stop")
+
+// Load load the data tree from the source, which is a snapshot file or a data
+// dir. For the data dir, the latest snapshot not after zxid is loaded and the
+// transactions of the logs are applied until zxid, 0 means the latest zxid.
+// For the snapshot file, the logs are applied only if zxid is not 0 or logDir
+// is set. The logs are in logDir, like the dataLogDir of the server, the
+// default is the data dir or the dir of the snapshot file.
+func Load(source, logDir string, zxid int64) (*DataTree, error) {
+	fi, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := source
+	if !fi.IsDir() {
+		t, err := Open(source)
+		if err != nil || (zxid == 0 && logDir == "") {
+			return t, err
+		}
+		if zxid != 0 && zxid < t.Zxid {
+			return nil, errors.Errorf("the zxid 0x%x is before the snapshot 0x%x", zxid, t.Zxid)
+		}
+
+		if logDir == "" {
+			logDir = filepath.Dir(source)
+		}
+		if err := t.replay(logDir, zxid); err != nil {
+			return nil, err
+		}
+
+		return t, nil
+	}
+
+	snapshots, err := listFiles(dir, snapshotPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	// the latest snapshot not after zxid, the snapshot is fuzzy and may
+	// contain the transactions after its zxid, they are applied again
+	var file string
+	for _, s := range snapshots {
+		if zxid == 0 || s.zxid <= zxid {
+			file = s.path
+		}
+	}
+	if file == "" {
+		if len(snapshots) == 0 {
+			return nil, errors.Errorf("no snapshot in %s", source)
+		}
+		return nil, errors.Errorf("no snapshot before the zxid 0x%x in %s", zxid, source)
+	}
+
+	t, err := Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if logDir == "" {
+		logDir = dir
+	}
+	if err := t.replay(logDir, zxid); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// replay apply the transactions after the zxid of the tree until zxid, 0
+// means all the transactions. MissingTxns is set if the logs do not cover the
+// zxid of the tree.
+func (t *DataTree) replay(dir string, zxid int64) error {
+	if zxid != 0 && zxid == t.Zxid {
+		return nil
+	}
+
+	logs, err := listFiles(dir, txnLogPrefix)
+	if err != nil {
+		return err
+	}
+
+	// the logs start from the last log not after the next zxid of the tree
+	start := 0
+	for i, l := range logs {
+		if l.zxid <= t.Zxid+1 {
+			start = i
+		}
+	}
+
+	// the logs cover the tree if they have the transaction of its zxid or
+	// the next one, or the next one starts a new epoch
+	from, covered, next := t.Zxid, false, int64(0)
+	for _, l := range logs[start:] {
+		if zxid != 0 && l.zxid > zxid {
+			break
+		}
+
+		err := readTxnLog(l.path, func(txn *Txn) error {
+			if txn.Zxid == from || txn.Zxid == from+1 {
+				covered = true
+			}
+			if zxid != 0 && txn.Zxid > zxid {
+				return errStop
+			}
+			if txn.Zxid > from {
+				if next == 0 {
+					next = txn.Zxid
+				}
+				t.Apply(txn)
+			}
+			return nil
+		})
+		if err == errStop {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	t.MissingTxns = !covered && !isEpochStart(from, next)
+
+	if zxid != 0 && t.Zxid < zxid {
+		return errors.Errorf("the zxid 0x%x is after the last transaction 0x%x", zxid, t.Zxid)
+	}
+
+	return nil
+}
+
+// isEpochStart report whether next is the first zxid of an epoch after zxid,
+// the high 32 bits of zxid is the epoch and the low 32 bits is the counter
+func isEpochStart(zxid, next int64) bool {
+	return next>>32 > zxid>>32 && next&0xffffffff <= 1
+}
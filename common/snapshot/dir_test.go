@@ -0,0 +1,166 @@
+package snapshot
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	snap := buildSnapshot(testSessions, testNodes)
+	log := buildTxnLog(testTxns)
+
+	tests := []struct {
+		name        string
+		files       map[string][]byte
+		source      string
+		logDir      string
+		zxid        int64
+		wantZxid    int64
+		wantExist   []string
+		wantMissing []string
+		wantData    map[string]string
+		wantSession []int64
+		missingTxns bool
+		wantErr     bool
+	}{
+		{
+			name:        "data dir at the latest",
+			files:       map[string][]byte{"version-2/snapshot.100000002": snap, "version-2/log.100000001": log},
+			wantZxid:    0x100000007,
+			wantExist:   []string{"/app/a", "/app/ttl", "/app/t"},
+			wantMissing: []string{"/app/e", "/app/b"},
+			wantData:    map[string]string{"/app": "v2"},
+			wantSession: []int64{0x22},
+		},
+		{
+			name:        "data dir at the zxid",
+			files:       map[string][]byte{"snapshot.100000002": snap, "log.100000001": log},
+			zxid:        0x100000004,
+			wantZxid:    0x100000004,
+			wantExist:   []string{"/app/a", "/app/e"},
+			wantMissing: []string{"/app/ttl"},
+			wantData:    map[string]string{"/app": "v2"},
+			wantSession: []int64{0x11, 0x22},
+		},
+		{
+			name:        "snapshot file without the logs",
+			files:       map[string][]byte{"snapshot.100000002": snap, "log.100000001": log},
+			source:      "snapshot.100000002",
+			wantZxid:    0x100000002,
+			wantExist:   []string{"/app/e"},
+			wantMissing: []string{"/app/a"},
+			wantData:    map[string]string{"/app": "v1"},
+			wantSession: []int64{0x11},
+		},
+		{
+			name:        "snapshot file with the log dir",
+			files:       map[string][]byte{"data/snapshot.100000002": snap, "txnlog/version-2/log.100000001": log},
+			source:      "data/snapshot.100000002",
+			logDir:      "txnlog",
+			wantZxid:    0x100000007,
+			wantExist:   []string{"/app/a", "/app/ttl"},
+			wantMissing: []string{"/app/e"},
+			wantSession: []int64{0x22},
+		},
+		{
+			name:        "data dir with the log dir",
+			files:       map[string][]byte{"data/snapshot.100000002": snap, "txnlog/log.100000001": log},
+			source:      "data",
+			logDir:      "txnlog",
+			wantZxid:    0x100000007,
+			wantExist:   []string{"/app/a"},
+			wantSession: []int64{0x22},
+		},
+		{
+			name:        "no log",
+			files:       map[string][]byte{"snapshot.100000002": snap},
+			wantZxid:    0x100000002,
+			wantExist:   []string{"/app/e"},
+			missingTxns: true,
+		},
+		{
+			name:        "the logs after a gap",
+			files:       map[string][]byte{"snapshot.100000002": snap, "log.100000005": buildTxnLog(testTxns[4:])},
+			wantZxid:    0x100000007,
+			wantExist:   []string{"/app/ttl"},
+			wantMissing: []string{"/app/a", "/app/e"},
+			missingTxns: true,
+		},
+		{
+			name: "the log of a new epoch",
+			files: map[string][]byte{"snapshot.100000002": snap, "log.200000001": buildTxnLog([]testTxn{
+				{session: 0x33, zxid: 0x200000001, typ: opCreate, record: createRecord("/app/n", "n", false)},
+			})},
+			wantZxid:  0x200000001,
+			wantExist: []string{"/app/n"},
+		},
+		{
+			name:    "the zxid before the snapshot",
+			files:   map[string][]byte{"snapshot.100000002": snap, "log.100000001": log},
+			source:  "snapshot.100000002",
+			zxid:    0x100000001,
+			wantErr: true,
+		},
+		{
+			name:    "the zxid after the last transaction",
+			files:   map[string][]byte{"snapshot.100000002": snap, "log.100000001": log},
+			zxid:    0x100000009,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+
+			logDir := ""
+			if tt.logDir != "" {
+				logDir = filepath.Join(dir, tt.logDir)
+			}
+
+			tree, err := Load(filepath.Join(dir, tt.source), logDir, tt.zxid)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Load() error = nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			if tree.Zxid != tt.wantZxid {
+				t.Errorf("Zxid = 0x%x, want 0x%x", tree.Zxid, tt.wantZxid)
+			}
+			if tree.MissingTxns != tt.missingTxns {
+				t.Errorf("MissingTxns = %v, want %v", tree.MissingTxns, tt.missingTxns)
+			}
+			for _, p := range tt.wantExist {
+				if _, _, err := tree.Get(p); err != nil {
+					t.Errorf("Get(%s) error = %v", p, err)
+				}
+			}
+			for _, p := range tt.wantMissing {
+				if _, _, err := tree.Get(p); err == nil {
+					t.Errorf("Get(%s) exists", p)
+				}
+			}
+			for p, want := range tt.wantData {
+				if data, _, _ := tree.Get(p); string(data) != want {
+					t.Errorf("Get(%s) = %q, want %q", p, data, want)
+				}
+			}
+			if tt.wantSession != nil {
+				if len(tree.Sessions) != len(tt.wantSession) {
+					t.Errorf("Sessions = %v, want %x", tree.Sessions, tt.wantSession)
+				}
+				for _, id := range tt.wantSession {
+					if _, ok := tree.Sessions[id]; !ok {
+						t.Errorf("Sessions = %v, want %x", tree.Sessions, tt.wantSession)
+					}
+				}
+			}
+		})
+	}
+}
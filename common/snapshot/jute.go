@@ -0,0 +1,108 @@
+package snapshot
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/go-zookeeper/zk"
+	"github.com/pkg/errors"
+)
+
+// maxBufferLen limit the length of a buffer, the larger length means the file is corrupted
+const maxBufferLen = 64 << 20
+
+// juteReader read the jute binary archive, the first error is kept and the
+// later reads return zero values
+type juteReader struct {
+	r   io.Reader
+	err error
+	buf [8]byte
+}
+
+func newJuteReader(r io.Reader) *juteReader {
+	return &juteReader{r: r}
+}
+
+func (j *juteReader) read(n int) []byte {
+	if j.err != nil {
+		return j.buf[:n]
+	}
+
+	if _, err := io.ReadFull(j.r, j.buf[:n]); err != nil {
+		j.err = err
+	}
+
+	return j.buf[:n]
+}
+
+func (j *juteReader) readByte() byte {
+	return j.read(1)[0]
+}
+
+func (j *juteReader) readBool() bool {
+	return j.readByte() != 0
+}
+
+func (j *juteReader) readInt() int32 {
+	return int32(binary.BigEndian.Uint32(j.read(4)))
+}
+
+func (j *juteReader) readLong() int64 {
+	return int64(binary.BigEndian.Uint64(j.read(8)))
+}
+
+// readBuffer read the length and the bytes, nil if the length is -1
+func (j *juteReader) readBuffer() []byte {
+	n := j.readInt()
+	if j.err != nil || n < 0 {
+		return nil
+	}
+	if n > maxBufferLen {
+		j.err = errors.Errorf("invalid buffer length %d", n)
+		return nil
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(j.r, b); err != nil {
+		j.err = err
+	}
+
+	return b
+}
+
+func (j *juteReader) readString() string {
+	return string(j.readBuffer())
+}
+
+func (j *juteReader) readACLs() []zk.ACL {
+	n := j.readInt()
+	if j.err != nil || n < 0 {
+		return nil
+	}
+
+	acls := make([]zk.ACL, 0, n)
+	for i := int32(0); i < n && j.err == nil; i++ {
+		perms := j.readInt()
+		scheme := j.readString()
+		id := j.readString()
+		acls = append(acls, zk.ACL{Perms: perms, Scheme: scheme, ID: id})
+	}
+
+	return acls
+}
+
+// fileHeader is the header of the snapshot and the transaction log
+type fileHeader struct {
+	Magic   int32
+	Version int32
+	DBID    int64
+}
+
+func (j *juteReader) readFileHeader(magic int32) fileHeader {
+	h := fileHeader{Magic: j.readInt(), Version: j.readInt(), DBID: j.readLong()}
+	if j.err == nil && h.Magic != magic {
+		j.err = errors.Errorf("invalid magic number 0x%x, expected 0x%x", h.Magic, magic)
+	}
+
+	return h
+}
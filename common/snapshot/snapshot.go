@@ -0,0 +1,392 @@
+// Package snapshot read the zookeeper snapshots and transaction logs in the
+// data dir offline, and reconstruct the data tree at a zxid.
+package snapshot
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/benzimu/zkcmd/common/zookeeper"
+	"github.com/go-zookeeper/zk"
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+)
+
+const (
+	// SnapshotMagic is "ZKSN"
+	SnapshotMagic = 0x5a4b534e
+	// TxnLogMagic is "ZKLG"
+	TxnLogMagic = 0x5a4b4c47
+
+	// openACLID is the ACL id of world:anyone:cdrwa, it is not in the ACL cache
+	openACLID = -1
+
+	// containerOwner is the ephemeral owner of the container znodes, and the
+	// ephemeral owner of TTL znodes has the high byte 0xff
+	containerOwner = math.MinInt64
+	ttlOwnerMask   = int64(-1) << 56
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	// xerialMagic is the header of the snappy stream of the snapshot
+	xerialMagic = []byte{0x82, 'S', 'N', 'A', 'P', 'P', 'Y', 0}
+)
+
+// Node is a znode of the data tree
+type Node struct {
+	Path string
+	Data []byte
+	ACL  []zk.ACL
+	Stat zk.Stat
+
+	children map[string]struct{}
+}
+
+// DataTree is the znodes and sessions of a snapshot, with the transactions
+// applied until Zxid
+type DataTree struct {
+	// Zxid is the last zxid of the snapshot or the applied transactions
+	Zxid int64
+	// Sessions are the timeouts of the sessions
+	Sessions map[int64]int32
+	// MissingTxns is true if the transaction logs are replayed but none of
+	// them covers the zxid of the snapshot, the transactions after the
+	// snapshot may be missing
+	MissingTxns bool
+
+	nodes map[string]*Node
+}
+
+func newDataTree() *DataTree {
+	return &DataTree{
+		Sessions: make(map[int64]int32),
+		nodes:    make(map[string]*Node),
+	}
+}
+
+// Open read the snapshot file, the gzip and snappy snapshots are decompressed,
+// the zxid of the data tree is parsed from the file name like snapshot.100000000
+func Open(file string) (*DataTree, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := decompress(bufio.NewReader(f))
+	if err != nil {
+		return nil, errors.Wrap(err, file)
+	}
+
+	t, err := Read(r)
+	if err != nil {
+		return nil, errors.Wrap(err, file)
+	}
+
+	if zxid, ok := parseFileZxid(path.Base(file), snapshotPrefix); ok {
+		t.Zxid = zxid
+	}
+
+	return t, nil
+}
+
+// decompress detect the compression by the magic number
+func decompress(r *bufio.Reader) (io.Reader, error) {
+	head, _ := r.Peek(len(xerialMagic))
+
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		return gzip.NewReader(r)
+	case bytes.Equal(head, xerialMagic):
+		return newXerialReader(r)
+	}
+
+	return r, nil
+}
+
+// Read read the snapshot, the zxid of the data tree is unknown
+func Read(r io.Reader) (*DataTree, error) {
+	j := newJuteReader(r)
+	j.readFileHeader(SnapshotMagic)
+
+	t := newDataTree()
+
+	sessions := j.readInt()
+	for i := int32(0); i < sessions && j.err == nil; i++ {
+		id := j.readLong()
+		t.Sessions[id] = j.readInt()
+	}
+
+	aclCache := make(map[int64][]zk.ACL)
+	aclCount := j.readInt()
+	for i := int32(0); i < aclCount && j.err == nil; i++ {
+		id := j.readLong()
+		aclCache[id] = j.readACLs()
+	}
+
+	// the znodes are in pre-order, the root path is "" and the end is "/"
+	for j.err == nil {
+		p := j.readString()
+		if p == "/" || j.err != nil {
+			break
+		}
+		if p == "" {
+			p = "/"
+		}
+
+		n := &Node{Path: p, Data: j.readBuffer()}
+
+		aclID := j.readLong()
+		n.ACL = aclCache[aclID]
+		if aclID == openACLID {
+			n.ACL = zk.WorldACL(zk.PermAll)
+		}
+
+		n.Stat = zk.Stat{
+			Czxid:          j.readLong(),
+			Mzxid:          j.readLong(),
+			Ctime:          j.readLong(),
+			Mtime:          j.readLong(),
+			Version:        j.readInt(),
+			Cversion:       j.readInt(),
+			Aversion:       j.readInt(),
+			EphemeralOwner: clientEphemeralOwner(j.readLong()),
+			Pzxid:          j.readLong(),
+		}
+
+		t.add(n)
+	}
+
+	if j.err != nil {
+		return nil, errors.Wrap(j.err, "read snapshot")
+	}
+
+	if _, ok := t.nodes["/"]; !ok {
+		return nil, errors.New("read snapshot: no root znode")
+	}
+
+	return t, nil
+}
+
+// clientEphemeralOwner return the ephemeral owner seen by the clients, it is
+// 0 for the container and TTL znodes
+func clientEphemeralOwner(owner int64) int64 {
+	if owner == containerOwner || owner&ttlOwnerMask == ttlOwnerMask {
+		return 0
+	}
+
+	return owner
+}
+
+// add add the znode to its parent
+func (t *DataTree) add(n *Node) {
+	n.children = make(map[string]struct{})
+	t.nodes[n.Path] = n
+
+	if n.Path == "/" {
+		return
+	}
+
+	if parent, ok := t.nodes[path.Dir(n.Path)]; ok {
+		parent.children[path.Base(n.Path)] = struct{}{}
+	}
+}
+
+// remove remove the znode from its parent
+func (t *DataTree) remove(p string) {
+	delete(t.nodes, p)
+
+	if parent, ok := t.nodes[path.Dir(p)]; ok {
+		delete(parent.children, path.Base(p))
+	}
+}
+
+// Len return the number of znodes
+func (t *DataTree) Len() int {
+	return len(t.nodes)
+}
+
+// Get return the data and the stat of the znode
+func (t *DataTree) Get(p string) ([]byte, *zk.Stat, error) {
+	n, ok := t.nodes[p]
+	if !ok {
+		return nil, nil, zk.ErrNoNode
+	}
+
+	return n.Data, n.stat(), nil
+}
+
+// GetACL return the ACL and the stat of the znode
+func (t *DataTree) GetACL(p string) ([]zk.ACL, *zk.Stat, error) {
+	n, ok := t.nodes[p]
+	if !ok {
+		return nil, nil, zk.ErrNoNode
+	}
+
+	return n.ACL, n.stat(), nil
+}
+
+// Children return the sorted children names and the stat of the znode
+func (t *DataTree) Children(p string) ([]string, *zk.Stat, error) {
+	n, ok := t.nodes[p]
+	if !ok {
+		return nil, nil, zk.ErrNoNode
+	}
+
+	return n.childNames(), n.stat(), nil
+}
+
+func (n *Node) childNames() []string {
+	names := make([]string, 0, len(n.children))
+	for c := range n.children {
+		names = append(names, c)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// stat return the stat with the data length and the number of children
+func (n *Node) stat() *zk.Stat {
+	s := n.Stat
+	s.DataLength = int32(len(n.Data))
+	s.NumChildren = int32(len(n.children))
+
+	return &s
+}
+
+// Walk walk the root and its descendants in pre-order with children in name
+// order, like zookeeper.Client.Walk, the data and ACL are always reported.
+// The Include, Exclude and MaxDepth of the options are used.
+func (t *DataTree) Walk(root string, opts zookeeper.WalkOptions, fn zookeeper.WalkFunc) error {
+	if err := zookeeper.ValidatePath(root, false); err != nil {
+		return err
+	}
+
+	n, ok := t.nodes[root]
+	if !ok {
+		return zk.ErrNoNode
+	}
+
+	return t.walk(n, 0, opts, fn)
+}
+
+func (t *DataTree) walk(n *Node, depth int, opts zookeeper.WalkOptions, fn zookeeper.WalkFunc) error {
+	wn := &zookeeper.WalkNode{Path: n.Path, Depth: depth, Stat: n.stat(), Data: n.Data, ACL: n.ACL}
+
+	children := n.childNames()
+	if opts.MaxDepth <= 0 || depth < opts.MaxDepth {
+		wn.Children = children
+	} else {
+		children = nil
+	}
+
+	if len(opts.Include) == 0 || matchAny(opts.Include, n.Path) {
+		if err := fn(wn); err != nil {
+			return err
+		}
+	}
+
+	for _, c := range children {
+		p := path.Join(n.Path, c)
+		if matchAny(opts.Exclude, p) {
+			continue
+		}
+
+		if err := t.walk(t.nodes[p], depth+1, opts, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func matchAny(patterns []string, p string) bool {
+	for _, pattern := range patterns {
+		if zookeeper.MatchPattern(pattern, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Export export the path and all its descendants like zookeeper.Client.Export,
+// the zookeeper internal znodes are not exported if the path is the root
+func (t *DataTree) Export(root string, opts zookeeper.WalkOptions) (*zookeeper.Dump, error) {
+	d := &zookeeper.Dump{
+		Version: zookeeper.DumpVersion,
+		Path:    root,
+		Time:    time.Now(),
+		Znodes:  make([]zookeeper.DumpZnode, 0),
+	}
+
+	if root == "/" {
+		opts.Exclude = append([]string{"/zookeeper"}, opts.Exclude...)
+	}
+
+	err := t.Walk(root, opts, func(n *zookeeper.WalkNode) error {
+		d.Znodes = append(d.Znodes, zookeeper.NewDumpZnode(root, n.Path, n.Data, n.ACL, n.Stat))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// xerialReader read the snappy stream of org.xerial.snappy.SnappyOutputStream,
+// the header is followed by the blocks prefixed by the length
+type xerialReader struct {
+	r   io.Reader
+	buf []byte
+}
+
+func newXerialReader(r io.Reader) (*xerialReader, error) {
+	// the magic, version and compatible version
+	header := make([]byte, len(xerialMagic)+8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	return &xerialReader{r: r}, nil
+}
+
+func (x *xerialReader) Read(p []byte) (int, error) {
+	for len(x.buf) == 0 {
+		var size [4]byte
+		if _, err := io.ReadFull(x.r, size[:]); err != nil {
+			return 0, err
+		}
+
+		n := binary.BigEndian.Uint32(size[:])
+		if n > maxBufferLen {
+			return 0, errors.Errorf("invalid snappy block length %d", n)
+		}
+
+		block := make([]byte, n)
+		if _, err := io.ReadFull(x.r, block); err != nil {
+			return 0, err
+		}
+
+		var err error
+		if x.buf, err = snappy.Decode(nil, block); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, x.buf)
+	x.buf = x.buf[n:]
+
+	return n, nil
+}
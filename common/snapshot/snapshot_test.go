@@ -0,0 +1,267 @@
+package snapshot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-zookeeper/zk"
+	"github.com/golang/snappy"
+)
+
+// juteWriter write the jute binary archive of the fixtures
+type juteWriter struct {
+	bytes.Buffer
+}
+
+func (w *juteWriter) writeInt(v int32) {
+	_ = binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *juteWriter) writeLong(v int64) {
+	_ = binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *juteWriter) writeBool(v bool) {
+	if v {
+		w.WriteByte(1)
+	} else {
+		w.WriteByte(0)
+	}
+}
+
+// writeBuffer write the length and the bytes, -1 if b is nil
+func (w *juteWriter) writeBuffer(b []byte) {
+	if b == nil {
+		w.writeInt(-1)
+		return
+	}
+
+	w.writeInt(int32(len(b)))
+	w.Write(b)
+}
+
+func (w *juteWriter) writeString(s string) {
+	w.writeBuffer([]byte(s))
+}
+
+func (w *juteWriter) writeACLs(acls []zk.ACL) {
+	w.writeInt(int32(len(acls)))
+	for _, a := range acls {
+		w.writeInt(a.Perms)
+		w.writeString(a.Scheme)
+		w.writeString(a.ID)
+	}
+}
+
+// testNode is a znode of the snapshot fixture, the ACL is world:anyone:r if
+// readOnly, otherwise the open ACL
+type testNode struct {
+	path     string
+	data     string
+	czxid    int64
+	owner    int64
+	readOnly bool
+}
+
+// readOnlyACLID is the ACL cache id of world:anyone:r in the fixture
+const readOnlyACLID = 1
+
+// buildSnapshot build the snapshot of the sessions and the znodes in
+// pre-order
+func buildSnapshot(sessions map[int64]int32, nodes []testNode) []byte {
+	w := &juteWriter{}
+	w.writeInt(SnapshotMagic)
+	w.writeInt(2)
+	w.writeLong(-1)
+
+	w.writeInt(int32(len(sessions)))
+	for id, timeout := range sessions {
+		w.writeLong(id)
+		w.writeInt(timeout)
+	}
+
+	w.writeInt(1)
+	w.writeLong(readOnlyACLID)
+	w.writeACLs(zk.WorldACL(zk.PermRead))
+
+	for _, n := range nodes {
+		p := n.path
+		if p == "/" {
+			p = ""
+		}
+		w.writeString(p)
+		w.writeBuffer([]byte(n.data))
+
+		aclID := int64(openACLID)
+		if n.readOnly {
+			aclID = readOnlyACLID
+		}
+		w.writeLong(aclID)
+
+		// czxid, mzxid, ctime, mtime, version, cversion, aversion,
+		// ephemeralOwner, pzxid
+		w.writeLong(n.czxid)
+		w.writeLong(n.czxid)
+		w.writeLong(1700000000000)
+		w.writeLong(1700000000000)
+		w.writeInt(0)
+		w.writeInt(0)
+		w.writeInt(0)
+		w.writeLong(n.owner)
+		w.writeLong(n.czxid)
+	}
+	w.writeString("/")
+
+	return w.Bytes()
+}
+
+// gzipSnapshot compress the snapshot by gzip
+func gzipSnapshot(t *testing.T, b []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// xerialSnapshot compress the snapshot like org.xerial.snappy.SnappyOutputStream
+// in the blocks of blockSize
+func xerialSnapshot(b []byte, blockSize int) []byte {
+	w := &juteWriter{}
+	w.Write(xerialMagic)
+	w.writeInt(1)
+	w.writeInt(1)
+
+	for len(b) > 0 {
+		n := blockSize
+		if n > len(b) {
+			n = len(b)
+		}
+		w.writeBuffer(snappy.Encode(nil, b[:n]))
+		b = b[n:]
+	}
+
+	return w.Bytes()
+}
+
+// writeFiles write the files under dir, the names may have the sub dir
+func writeFiles(t *testing.T, dir string, files map[string][]byte) {
+	t.Helper()
+
+	for name, b := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// the snapshot fixture of zxid 0x100000002: the session 0x11 owns the
+// ephemeral /app/e, and /app/t is a TTL znode
+var (
+	testSessions = map[int64]int32{0x11: 30000}
+	testNodes    = []testNode{
+		{path: "/"},
+		{path: "/zookeeper"},
+		{path: "/app", data: "v1", czxid: 0x100000001},
+		{path: "/app/e", data: "e", czxid: 0x100000002, owner: 0x11},
+		{path: "/app/r", data: "r", czxid: 0x100000002, readOnly: true},
+		{path: "/app/t", data: "t", czxid: 0x100000002, owner: ttlOwnerMask | 60000},
+	}
+)
+
+func TestOpen(t *testing.T) {
+	raw := buildSnapshot(testSessions, testNodes)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "plain", data: raw},
+		{name: "gzip", data: gzipSnapshot(t, raw)},
+		{name: "xerial snappy", data: xerialSnapshot(raw, 64)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string][]byte{"snapshot.100000002": tt.data})
+
+			tree, err := Open(filepath.Join(dir, "snapshot.100000002"))
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+
+			if tree.Zxid != 0x100000002 {
+				t.Errorf("Zxid = 0x%x, want 0x100000002", tree.Zxid)
+			}
+			if tree.Len() != len(testNodes) {
+				t.Errorf("Len() = %d, want %d", tree.Len(), len(testNodes))
+			}
+			if tree.Sessions[0x11] != 30000 {
+				t.Errorf("Sessions = %v", tree.Sessions)
+			}
+
+			children, _, err := tree.Children("/app")
+			if err != nil || len(children) != 3 {
+				t.Errorf("Children(/app) = %v, %v", children, err)
+			}
+
+			data, stat, err := tree.Get("/app/e")
+			if err != nil || string(data) != "e" || stat.EphemeralOwner != 0x11 {
+				t.Errorf("Get(/app/e) = %q, %+v, %v", data, stat, err)
+			}
+
+			// the TTL znodes are not ephemeral for the clients
+			if _, stat, _ := tree.Get("/app/t"); stat == nil || stat.EphemeralOwner != 0 {
+				t.Errorf("Get(/app/t) stat = %+v", stat)
+			}
+
+			acl, _, err := tree.GetACL("/app/r")
+			if err != nil || len(acl) != 1 || acl[0].Perms != zk.PermRead {
+				t.Errorf("GetACL(/app/r) = %v, %v", acl, err)
+			}
+			if acl, _, _ := tree.GetACL("/app"); len(acl) != 1 || acl[0].Perms != zk.PermAll {
+				t.Errorf("GetACL(/app) = %v", acl)
+			}
+		})
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	raw := buildSnapshot(testSessions, testNodes)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "bad magic", data: append([]byte("ZKLG"), raw[4:]...)},
+		{name: "truncated", data: raw[:len(raw)-10]},
+		{name: "truncated xerial", data: xerialSnapshot(raw, 64)[:40]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string][]byte{"snapshot.1": tt.data})
+
+			if _, err := Open(filepath.Join(dir, "snapshot.1")); err == nil {
+				t.Errorf("Open() error = nil")
+			}
+		})
+	}
+}
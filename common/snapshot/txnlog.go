@@ -0,0 +1,331 @@
+package snapshot
+
+import (
+	"bufio"
+	"bytes"
+	"hash/adler32"
+	"io"
+	"os"
+	"path"
+
+	"github.com/go-zookeeper/zk"
+	"github.com/pkg/errors"
+)
+
+// the transaction types of org.apache.zookeeper.ZooDefs.OpCode
+const (
+	opCreate          = 1
+	opDelete          = 2
+	opSetData         = 5
+	opSetACL          = 7
+	opCheck           = 13
+	opMulti           = 14
+	opCreate2         = 15
+	opReconfig        = 16
+	opCreateContainer = 19
+	opDeleteContainer = 20
+	opCreateTTL       = 21
+	opCreateSession   = -10
+	opCloseSession    = -11
+	opError           = -1
+)
+
+// endOfRecord is the byte after every transaction of the log
+const endOfRecord = 0x42
+
+var opNames = map[int32]string{
+	opCreate:          "create",
+	opDelete:          "delete",
+	opSetData:         "setData",
+	opSetACL:          "setACL",
+	opCheck:           "check",
+	opMulti:           "multi",
+	opCreate2:         "create2",
+	opReconfig:        "reconfig",
+	opCreateContainer: "createContainer",
+	opDeleteContainer: "deleteContainer",
+	opCreateTTL:       "createTTL",
+	opCreateSession:   "createSession",
+	opCloseSession:    "closeSession",
+	opError:           "error",
+}
+
+// OpName return the name of the transaction type
+func OpName(op int32) string {
+	if name, ok := opNames[op]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+// Txn is a transaction of the log, the fields are set by the type
+type Txn struct {
+	Session int64
+	Cxid    int32
+	Zxid    int64
+	// Time is the milliseconds since epoch
+	Time int64
+	Type int32
+
+	Path string
+	Data []byte
+	ACL  []zk.ACL
+	// Version is the expected version of setData, setACL and check
+	Version int32
+	// Ephemeral is true for the ephemeral znodes of create
+	Ephemeral bool
+	// ParentCversion is the cversion of the parent after create, -1 means
+	// increase by one
+	ParentCversion int32
+	// TTL is the milliseconds of createTTL
+	TTL int64
+	// Timeout is the session timeout of createSession
+	Timeout int32
+	// Err is the error code of the error transaction
+	Err int32
+	// Ops are the transactions of multi, the Session, Cxid, Zxid and Time
+	// are the same as the multi
+	Ops []*Txn
+}
+
+// Op return the name of the transaction type
+func (t *Txn) Op() string {
+	return OpName(t.Type)
+}
+
+// Failed report whether the transaction or one of the multi ops is an error
+func (t *Txn) Failed() bool {
+	if t.Type == opError {
+		return true
+	}
+
+	for _, op := range t.Ops {
+		if op.Failed() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TxnLog read the transactions of a log file
+type TxnLog struct {
+	File string
+	// Zxid is the first zxid of the log parsed from the file name
+	Zxid int64
+
+	f *os.File
+	j *juteReader
+}
+
+// OpenTxnLog open the transaction log file and read the file header
+func OpenTxnLog(file string) (*TxnLog, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &TxnLog{File: file, f: f, j: newJuteReader(bufio.NewReader(f))}
+	l.Zxid, _ = parseFileZxid(path.Base(file), txnLogPrefix)
+
+	l.j.readFileHeader(TxnLogMagic)
+	if l.j.err != nil {
+		f.Close()
+		return nil, errors.Wrap(l.j.err, file)
+	}
+
+	return l, nil
+}
+
+// Next return the next transaction, io.EOF if there is no more. The log is
+// preallocated with zeros, the zero length entry is the end.
+func (l *TxnLog) Next() (*Txn, error) {
+	j := l.j
+
+	crc := j.readLong()
+	b := j.readBuffer()
+	if j.err == io.EOF || j.err == io.ErrUnexpectedEOF || (j.err == nil && len(b) == 0) {
+		return nil, io.EOF
+	}
+
+	eor := j.readByte()
+	if j.err != nil {
+		// the last transaction may be partially written
+		if j.err == io.ErrUnexpectedEOF || j.err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, errors.Wrap(j.err, l.File)
+	}
+
+	if uint32(crc) != adler32.Checksum(b) {
+		return nil, errors.Errorf("%s: checksum mismatch", l.File)
+	}
+	if eor != endOfRecord {
+		return nil, errors.Errorf("%s: invalid end of record 0x%x", l.File, eor)
+	}
+
+	txn, err := readTxn(b)
+	if err != nil {
+		return nil, errors.Wrap(err, l.File)
+	}
+
+	return txn, nil
+}
+
+// Close close the log file
+func (l *TxnLog) Close() error {
+	return l.f.Close()
+}
+
+// readTxn read the header and the record, the digest after the record is ignored
+func readTxn(b []byte) (*Txn, error) {
+	j := newJuteReader(bytes.NewReader(b))
+
+	t := &Txn{
+		Session: j.readLong(),
+		Cxid:    j.readInt(),
+		Zxid:    j.readLong(),
+		Time:    j.readLong(),
+		Type:    j.readInt(),
+	}
+	j.readRecord(t)
+
+	if j.err != nil {
+		return nil, errors.Wrapf(j.err, "read transaction %s of zxid 0x%x", t.Op(), t.Zxid)
+	}
+
+	return t, nil
+}
+
+// readRecord read the record of the transaction type
+func (j *juteReader) readRecord(t *Txn) {
+	switch t.Type {
+	case opCreate, opCreate2:
+		t.Path, t.Data, t.ACL = j.readString(), j.readBuffer(), j.readACLs()
+		t.Ephemeral = j.readBool()
+		t.ParentCversion = j.readInt()
+	case opCreateContainer:
+		t.Path, t.Data, t.ACL = j.readString(), j.readBuffer(), j.readACLs()
+		t.ParentCversion = j.readInt()
+	case opCreateTTL:
+		t.Path, t.Data, t.ACL = j.readString(), j.readBuffer(), j.readACLs()
+		t.ParentCversion = j.readInt()
+		t.TTL = j.readLong()
+	case opDelete, opDeleteContainer:
+		t.Path = j.readString()
+	case opSetData, opReconfig:
+		t.Path, t.Data = j.readString(), j.readBuffer()
+		t.Version = j.readInt()
+	case opSetACL:
+		t.Path, t.ACL = j.readString(), j.readACLs()
+		t.Version = j.readInt()
+	case opCheck:
+		t.Path = j.readString()
+		t.Version = j.readInt()
+	case opCreateSession:
+		t.Timeout = j.readInt()
+	case opError:
+		t.Err = j.readInt()
+	case opMulti:
+		n := j.readInt()
+		for i := int32(0); i < n && j.err == nil; i++ {
+			op := &Txn{Session: t.Session, Cxid: t.Cxid, Zxid: t.Zxid, Time: t.Time, Type: j.readInt()}
+			sub := newJuteReader(bytes.NewReader(j.readBuffer()))
+			sub.readRecord(op)
+			if j.err == nil && sub.err != nil {
+				j.err = sub.err
+			}
+			t.Ops = append(t.Ops, op)
+		}
+	}
+}
+
+// Apply apply the transaction to the data tree like the server, the zxid of
+// the tree is updated. The failed multi is not applied.
+func (t *DataTree) Apply(txn *Txn) {
+	if txn.Zxid > t.Zxid {
+		t.Zxid = txn.Zxid
+	}
+
+	switch txn.Type {
+	case opMulti:
+		if txn.Failed() {
+			return
+		}
+		for _, op := range txn.Ops {
+			t.Apply(op)
+		}
+	case opCreate, opCreate2, opCreateContainer, opCreateTTL:
+		t.create(txn)
+	case opDelete, opDeleteContainer:
+		t.delete(txn)
+	case opSetData:
+		if n, ok := t.nodes[txn.Path]; ok {
+			n.Data = txn.Data
+			n.Stat.Version = txn.Version
+			n.Stat.Mzxid = txn.Zxid
+			n.Stat.Mtime = txn.Time
+		}
+	case opSetACL:
+		if n, ok := t.nodes[txn.Path]; ok {
+			n.ACL = txn.ACL
+			n.Stat.Aversion = txn.Version
+		}
+	case opCreateSession:
+		t.Sessions[txn.Session] = txn.Timeout
+	case opCloseSession:
+		delete(t.Sessions, txn.Session)
+		for p, n := range t.nodes {
+			if n.Stat.EphemeralOwner == txn.Session {
+				t.delete(&Txn{Zxid: txn.Zxid, Path: p})
+			}
+		}
+	}
+}
+
+func (t *DataTree) create(txn *Txn) {
+	parent, ok := t.nodes[path.Dir(txn.Path)]
+	if !ok {
+		return
+	}
+	if _, ok := t.nodes[txn.Path]; ok {
+		return
+	}
+
+	n := &Node{
+		Path: txn.Path,
+		Data: txn.Data,
+		ACL:  txn.ACL,
+		Stat: zk.Stat{
+			Czxid: txn.Zxid,
+			Mzxid: txn.Zxid,
+			Ctime: txn.Time,
+			Mtime: txn.Time,
+			Pzxid: txn.Zxid,
+		},
+	}
+	if txn.Ephemeral {
+		n.Stat.EphemeralOwner = txn.Session
+	}
+	t.add(n)
+
+	if txn.ParentCversion == -1 {
+		parent.Stat.Cversion++
+	} else {
+		parent.Stat.Cversion = txn.ParentCversion
+	}
+	parent.Stat.Pzxid = txn.Zxid
+}
+
+func (t *DataTree) delete(txn *Txn) {
+	if _, ok := t.nodes[txn.Path]; !ok {
+		return
+	}
+	t.remove(txn.Path)
+
+	if parent, ok := t.nodes[path.Dir(txn.Path)]; ok {
+		parent.Stat.Cversion++
+		parent.Stat.Pzxid = txn.Zxid
+	}
+}
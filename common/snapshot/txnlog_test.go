@@ -0,0 +1,191 @@
+package snapshot
+
+import (
+	"bytes"
+	"hash/adler32"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-zookeeper/zk"
+)
+
+// testTxn is a transaction of the log fixture
+type testTxn struct {
+	session int64
+	zxid    int64
+	typ     int32
+	record  []byte
+}
+
+// testOp is an op of the multi fixture
+type testOp struct {
+	typ    int32
+	record []byte
+}
+
+func record(fn func(w *juteWriter)) []byte {
+	w := &juteWriter{}
+	fn(w)
+
+	return w.Bytes()
+}
+
+func createRecord(p, data string, ephemeral bool) []byte {
+	return record(func(w *juteWriter) {
+		w.writeString(p)
+		w.writeBuffer([]byte(data))
+		w.writeACLs(zk.WorldACL(zk.PermAll))
+		w.writeBool(ephemeral)
+		w.writeInt(-1)
+	})
+}
+
+func createTTLRecord(p, data string, ttl int64) []byte {
+	return record(func(w *juteWriter) {
+		w.writeString(p)
+		w.writeBuffer([]byte(data))
+		w.writeACLs(zk.WorldACL(zk.PermAll))
+		w.writeInt(-1)
+		w.writeLong(ttl)
+	})
+}
+
+func setDataRecord(p, data string, version int32) []byte {
+	return record(func(w *juteWriter) {
+		w.writeString(p)
+		w.writeBuffer([]byte(data))
+		w.writeInt(version)
+	})
+}
+
+func errorRecord(code int32) []byte {
+	return record(func(w *juteWriter) { w.writeInt(code) })
+}
+
+func createSessionRecord(timeout int32) []byte {
+	return record(func(w *juteWriter) { w.writeInt(timeout) })
+}
+
+func multiRecord(ops ...testOp) []byte {
+	return record(func(w *juteWriter) {
+		w.writeInt(int32(len(ops)))
+		for _, op := range ops {
+			w.writeInt(op.typ)
+			w.writeBuffer(op.record)
+		}
+	})
+}
+
+// buildTxnLog build the log of the transactions, the end is preallocated
+// with zeros like the server
+func buildTxnLog(txns []testTxn) []byte {
+	w := &juteWriter{}
+	w.writeInt(TxnLogMagic)
+	w.writeInt(2)
+	w.writeLong(0)
+
+	for _, txn := range txns {
+		b := record(func(w *juteWriter) {
+			w.writeLong(txn.session)
+			w.writeInt(1)
+			w.writeLong(txn.zxid)
+			w.writeLong(1700000000000 + txn.zxid&0xffffffff)
+			w.writeInt(txn.typ)
+			w.Write(txn.record)
+		})
+
+		w.writeLong(int64(adler32.Checksum(b)))
+		w.writeBuffer(b)
+		w.WriteByte(endOfRecord)
+	}
+	w.Write(make([]byte, 64))
+
+	return w.Bytes()
+}
+
+// testTxns are the transactions after the snapshot fixture, the first two
+// are in the fuzzy snapshot
+var testTxns = []testTxn{
+	{session: 0x11, zxid: 0x100000001, typ: opCreate, record: createRecord("/app", "v1", false)},
+	{session: 0x11, zxid: 0x100000002, typ: opCreate, record: createRecord("/app/e", "e", true)},
+	{session: 0x22, zxid: 0x100000003, typ: opCreateSession, record: createSessionRecord(4000)},
+	{session: 0x22, zxid: 0x100000004, typ: opMulti, record: multiRecord(
+		testOp{typ: opCreate, record: createRecord("/app/a", "a", false)},
+		testOp{typ: opSetData, record: setDataRecord("/app", "v2", 1)},
+	)},
+	{session: 0x22, zxid: 0x100000005, typ: opCreateTTL, record: createTTLRecord("/app/ttl", "t", 60000)},
+	{session: 0x22, zxid: 0x100000006, typ: opMulti, record: multiRecord(
+		testOp{typ: opError, record: errorRecord(0)},
+		testOp{typ: opError, record: errorRecord(-101)}, // ZNONODE
+	)},
+	{session: 0x11, zxid: 0x100000007, typ: opCloseSession},
+}
+
+func TestTxnLog(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string][]byte{"log.100000001": buildTxnLog(testTxns)})
+
+	logs, err := TxnLogs(dir)
+	if err != nil || len(logs) != 1 {
+		t.Fatalf("TxnLogs() = %v, %v", logs, err)
+	}
+
+	var txns []*Txn
+	err = ReadTxns(logs, func(txn *Txn) error {
+		txns = append(txns, txn)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadTxns() error = %v", err)
+	}
+	if len(txns) != len(testTxns) {
+		t.Fatalf("ReadTxns() read %d transactions, want %d", len(txns), len(testTxns))
+	}
+
+	for i, txn := range txns {
+		if txn.Zxid != testTxns[i].zxid || txn.Type != testTxns[i].typ || txn.Session != testTxns[i].session {
+			t.Errorf("txn %d = %s 0x%x of 0x%x", i, txn.Op(), txn.Zxid, txn.Session)
+		}
+	}
+
+	if e := txns[1]; e.Path != "/app/e" || !e.Ephemeral || e.ParentCversion != -1 {
+		t.Errorf("create = %+v", e)
+	}
+	if s := txns[2]; s.Timeout != 4000 {
+		t.Errorf("createSession timeout = %d", s.Timeout)
+	}
+
+	multi := txns[3]
+	if len(multi.Ops) != 2 || multi.Failed() {
+		t.Fatalf("multi ops = %d, failed = %v", len(multi.Ops), multi.Failed())
+	}
+	if op := multi.Ops[0]; op.Op() != "create" || op.Path != "/app/a" || string(op.Data) != "a" || op.Zxid != multi.Zxid {
+		t.Errorf("multi/create = %+v", op)
+	}
+	if op := multi.Ops[1]; op.Op() != "setData" || op.Path != "/app" || string(op.Data) != "v2" || op.Version != 1 {
+		t.Errorf("multi/setData = %+v", op)
+	}
+
+	if ttl := txns[4]; ttl.Path != "/app/ttl" || ttl.TTL != 60000 || len(ttl.ACL) != 1 {
+		t.Errorf("createTTL = %+v", ttl)
+	}
+	if failed := txns[5]; !failed.Failed() || failed.Ops[1].Err != -101 {
+		t.Errorf("failed multi = %+v", failed)
+	}
+	if c := txns[6]; c.Op() != "closeSession" {
+		t.Errorf("closeSession = %+v", c)
+	}
+}
+
+func TestTxnLogChecksum(t *testing.T) {
+	b := buildTxnLog(testTxns[:1])
+	b[bytes.Index(b, []byte("/app"))] ^= 0xff
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string][]byte{"log.100000001": b})
+
+	err := ReadTxns([]string{filepath.Join(dir, "log.100000001")}, func(*Txn) error { return nil })
+	if err == nil {
+		t.Errorf("ReadTxns() error = nil, want checksum mismatch")
+	}
+}
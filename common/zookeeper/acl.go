@@ -0,0 +1,172 @@
+package zookeeper
+
+import (
+	"sort"
+
+	"github.com/go-zookeeper/zk"
+	"github.com/pkg/errors"
+)
+
+const (
+	ACLModeReplace = "replace"
+	ACLModeAdd     = "add"
+	ACLModeRemove  = "remove"
+)
+
+// MergeACL return the ACL of applying acls to the current ACL by the mode:
+// replace the ACL, add the entries and perms, or remove the perms and the
+// entries without perms. The entries are matched by the scheme and id.
+func MergeACL(current, acls []zk.ACL, mode string) ([]zk.ACL, error) {
+	var res []zk.ACL
+	switch mode {
+	case ACLModeReplace:
+		res = append(res, acls...)
+	case ACLModeAdd:
+		res = append(res, current...)
+		for _, a := range acls {
+			if i := indexACL(res, a); i >= 0 {
+				res[i].Perms |= a.Perms
+			} else {
+				res = append(res, a)
+			}
+		}
+	case ACLModeRemove:
+		res = append(res, current...)
+		for _, a := range acls {
+			if i := indexACL(res, a); i >= 0 {
+				res[i].Perms &^= a.Perms
+			}
+		}
+
+		kept := res[:0]
+		for _, a := range res {
+			if a.Perms != 0 {
+				kept = append(kept, a)
+			}
+		}
+		res = kept
+	default:
+		return nil, errors.Errorf("unknown ACL mode: %s", mode)
+	}
+
+	if len(res) == 0 {
+		return nil, errors.New("the ACL would be empty")
+	}
+
+	return res, nil
+}
+
+func indexACL(acls []zk.ACL, a zk.ACL) int {
+	for i, b := range acls {
+		if b.Scheme == a.Scheme && b.ID == a.ID {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// EqualACL report whether the ACLs have the same entries in any order
+func EqualACL(a, b []zk.ACL) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	return ACLKey(a) == ACLKey(b)
+}
+
+// ACLKey return the formatted ACL with the entries sorted, the equal ACLs
+// have the same key
+func ACLKey(acls []zk.ACL) string {
+	sorted := append([]zk.ACL(nil), acls...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Scheme != sorted[j].Scheme {
+			return sorted[i].Scheme < sorted[j].Scheme
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	return FormatACLs(sorted)
+}
+
+// ACLChange is the ACL change of a znode
+type ACLChange struct {
+	Path string
+	// Aversion is the ACL version before the change
+	Aversion int32
+	Old      []zk.ACL
+	New      []zk.ACL
+	// Err is the error of setting the ACL, the other znodes are still set
+	Err error
+}
+
+// ExpandAuthACL replace the auth ACL by the digest ACL of the digest
+// credentials added by AddAuth, as the server does. The ACL is not changed if
+// the session has the SASL or x509 identities, which are only known by the
+// server.
+func (c *Client) ExpandAuthACL(acls []zk.ACL) ([]zk.ACL, error) {
+	c.authLock.Lock()
+	defer c.authLock.Unlock()
+
+	if c.identified {
+		return acls, nil
+	}
+
+	return ExpandAuthACL(acls, c.digests)
+}
+
+// SetACLs merge acls to the ACL of the root and its descendants by the mode,
+// see MergeACL. The znodes are walked first, then the changed ACLs are set
+// with the walked ACL versions, so a znode whose ACL is changed by others
+// meanwhile fails with zk.ErrBadVersion. The changes are only computed if
+// dryRun. The auth ACL is expanded by ExpandAuthACL before merging, so the
+// znodes with the expanded ACL are not changed. The zookeeper internal znodes
+// are skipped if the root is "/". The error of a znode is in its change, and
+// the returned error is the error of walking.
+func (c *Client) SetACLs(root string, opts WalkOptions, acls []zk.ACL, mode string, dryRun bool) ([]*ACLChange, error) {
+	switch mode {
+	case ACLModeReplace, ACLModeAdd, ACLModeRemove:
+	default:
+		return nil, errors.Errorf("unknown ACL mode: %s", mode)
+	}
+
+	acls, err := c.ExpandAuthACL(acls)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.ACL = true
+	if root == "/" {
+		opts.Exclude = append([]string{systemPath}, opts.Exclude...)
+	}
+
+	changes := make([]*ACLChange, 0)
+	err = c.Walk(root, opts, func(n *WalkNode) error {
+		ch := &ACLChange{Path: n.Path, Aversion: n.Stat.Aversion, Old: n.ACL}
+
+		ch.New, ch.Err = MergeACL(n.ACL, acls, mode)
+		if ch.Err == nil && EqualACL(n.ACL, ch.New) {
+			return nil
+		}
+
+		changes = append(changes, ch)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		return changes, nil
+	}
+
+	for _, ch := range changes {
+		if ch.Err != nil {
+			continue
+		}
+
+		_, ch.Err = c.SetACL(ch.Path, ch.New, ch.Aversion)
+	}
+
+	return changes, nil
+}
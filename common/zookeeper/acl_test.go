@@ -0,0 +1,125 @@
+package zookeeper
+
+import (
+	"testing"
+
+	"github.com/go-zookeeper/zk"
+)
+
+func TestClientExpandAuthACL(t *testing.T) {
+	acls := []zk.ACL{
+		{Perms: zk.PermAll, Scheme: SchemeAuth},
+		{Perms: zk.PermRead, Scheme: "world", ID: "anyone"},
+	}
+
+	tests := []struct {
+		name       string
+		digests    []string
+		identified bool
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:    "digest",
+			digests: []string{"alice:secret", "bob:secret"},
+			want:    "digest:" + DigestID("alice", "secret") + ":adcwr,digest:" + DigestID("bob", "secret") + ":adcwr,world:anyone:r",
+		},
+		{name: "no credential", want: "auth::adcwr,world:anyone:r"},
+		{name: "sasl or x509", digests: []string{"alice:secret"}, identified: true, want: "auth::adcwr,world:anyone:r"},
+		{name: "invalid credential", digests: []string{"alice"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{digests: tt.digests, identified: tt.identified}
+
+			got, err := c.ExpandAuthACL(acls)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExpandAuthACL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if s := FormatACLs(got); s != tt.want {
+				t.Errorf("ExpandAuthACL() = %s, want %s", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeACL(t *testing.T) {
+	current := "world:anyone:r,digest:" + DigestID("alice", "secret") + ":adcwr"
+
+	tests := []struct {
+		name    string
+		acl     string
+		mode    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "replace",
+			acl:  "ip:10.0.0.0/8:rw",
+			mode: ACLModeReplace,
+			want: "ip:10.0.0.0/8:wr",
+		},
+		{
+			name: "add the perms to the entry",
+			acl:  "world:anyone:cw",
+			mode: ACLModeAdd,
+			want: "world:anyone:cwr,digest:" + DigestID("alice", "secret") + ":adcwr",
+		},
+		{
+			name: "add the entry",
+			acl:  "ip:10.0.0.1:r",
+			mode: ACLModeAdd,
+			want: current + ",ip:10.0.0.1:r",
+		},
+		{
+			name: "remove the perms",
+			acl:  "digest-plain:alice:secret:ad",
+			mode: ACLModeRemove,
+			want: "world:anyone:r,digest:" + DigestID("alice", "secret") + ":cwr",
+		},
+		{
+			name: "remove the entry without perms",
+			acl:  "world:anyone:cdrwa",
+			mode: ACLModeRemove,
+			want: "digest:" + DigestID("alice", "secret") + ":adcwr",
+		},
+		{
+			name: "remove the unknown entry",
+			acl:  "ip:10.0.0.1:r",
+			mode: ACLModeRemove,
+			want: current,
+		},
+		{name: "remove all", acl: current, mode: ACLModeRemove, wantErr: true},
+		{name: "unknown mode", acl: "ip:10.0.0.1:r", mode: "set", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cur, err := ParseACL(current)
+			if err != nil {
+				t.Fatal(err)
+			}
+			acls, err := ParseACL(tt.acl)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := MergeACL(cur, acls, tt.mode)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MergeACL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && FormatACLs(got) != tt.want {
+				t.Errorf("MergeACL() = %s, want %s", FormatACLs(got), tt.want)
+			}
+
+			// the current ACL is not modified
+			if FormatACLs(cur) != current {
+				t.Errorf("the current ACL is modified: %s", FormatACLs(cur))
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package zookeeper
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-zookeeper/zk"
+)
+
+// the severities of the audit findings, from the most severe
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
+)
+
+var severityRanks = map[string]int{
+	SeverityCritical: 0,
+	SeverityHigh:     1,
+	SeverityMedium:   2,
+	SeverityLow:      3,
+}
+
+// AuditRule is a check of the ACL audit
+type AuditRule struct {
+	ID          string `json:"id" yaml:"id"`
+	Severity    string `json:"severity" yaml:"severity"`
+	Description string `json:"description" yaml:"description"`
+}
+
+// the rules of the ACL audit
+var (
+	RuleWorldAll = AuditRule{"world-all", SeverityCritical,
+		"the znode is world:anyone:cdrwa, anyone can read, change, delete and set its ACL"}
+	RuleWorldWritable = AuditRule{"world-writable", SeverityHigh,
+		"world:anyone has the create, delete, write or admin permission"}
+	RuleNoAdmin = AuditRule{"no-admin", SeverityHigh,
+		"no one has the admin permission, the ACL can only be recovered by a superuser"}
+	RuleUnknownDigest = AuditRule{"unknown-digest", SeverityMedium,
+		"the digest id does not match any known user"}
+	RuleUnreadable = AuditRule{"unreadable", SeverityMedium,
+		"the znode is not readable with the current credentials, its descendants are not audited"}
+	RuleParentDiffers = AuditRule{"acl-differs-from-parent", SeverityLow,
+		"the ACL differs from the ACL of the parent"}
+)
+
+// AuditRules are all the rules of the ACL audit
+var AuditRules = []AuditRule{RuleWorldAll, RuleWorldWritable, RuleNoAdmin, RuleUnknownDigest, RuleUnreadable, RuleParentDiffers}
+
+// AuditOptions control the ACL audit
+type AuditOptions struct {
+	// KnownDigests are the known digest ids like user:base64(sha1(user:password)),
+	// see DigestID. The digest ids are not checked if it is empty.
+	KnownDigests []string
+}
+
+// AuditFinding is a problem of the ACL of a znode
+type AuditFinding struct {
+	Rule     string `json:"rule" yaml:"rule"`
+	Severity string `json:"severity" yaml:"severity"`
+	Path     string `json:"path" yaml:"path"`
+	Message  string `json:"message" yaml:"message"`
+}
+
+// AuditReport is the findings of the ACL audit sorted by severity and path
+type AuditReport struct {
+	Path     string          `json:"path" yaml:"path"`
+	Znodes   int             `json:"znodes" yaml:"znodes"`
+	Findings []*AuditFinding `json:"findings" yaml:"findings"`
+}
+
+// Audit walk the root and its descendants and check the ACL of the znodes by
+// the AuditRules. The unreadable znodes are reported instead of failing.
+func (c *Client) Audit(root string, opts WalkOptions, audit AuditOptions) (*AuditReport, error) {
+	opts.ACL, opts.NoAuth, opts.Unordered = true, true, false
+
+	known := make(map[string]bool)
+	for _, id := range audit.KnownDigests {
+		known[id] = true
+	}
+
+	report := &AuditReport{Path: root, Findings: make([]*AuditFinding, 0)}
+	// the ACL keys of the walked znodes to compare with the children
+	keys := make(map[string]string)
+
+	err := c.Walk(root, opts, func(n *WalkNode) error {
+		report.Znodes++
+
+		add := func(rule AuditRule, format string, args ...interface{}) {
+			report.Findings = append(report.Findings, &AuditFinding{
+				Rule:     rule.ID,
+				Severity: rule.Severity,
+				Path:     n.Path,
+				Message:  fmt.Sprintf(format, args...),
+			})
+		}
+
+		if n.NoAuth {
+			add(RuleUnreadable, "not readable with the current credentials")
+		}
+		if n.ACL == nil {
+			return nil
+		}
+
+		key := ACLKey(n.ACL)
+		if len(n.Children) > 0 {
+			keys[n.Path] = key
+		}
+		if parent, ok := keys[path.Dir(n.Path)]; ok && n.Path != root && parent != key {
+			add(RuleParentDiffers, "%s, the parent is %s", FormatACLs(n.ACL), parent)
+		}
+
+		admin := false
+		for _, a := range n.ACL {
+			admin = admin || a.Perms&zk.PermAdmin != 0
+
+			switch {
+			case a.Scheme == SchemeWorld && a.ID == "anyone" && a.Perms&zk.PermAll == zk.PermAll:
+				add(RuleWorldAll, "world:anyone:%s", FormatPerms(a.Perms))
+			case a.Scheme == SchemeWorld && a.ID == "anyone" && a.Perms&^zk.PermRead != 0:
+				add(RuleWorldWritable, "world:anyone:%s", FormatPerms(a.Perms))
+			case a.Scheme == SchemeDigest && len(known) > 0 && !known[a.ID]:
+				add(RuleUnknownDigest, "digest:%s:%s, the user %s is not known", a.ID, FormatPerms(a.Perms), digestUser(a.ID))
+			}
+		}
+
+		if !admin {
+			add(RuleNoAdmin, "%s", FormatACLs(n.ACL))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if severityRanks[a.Severity] != severityRanks[b.Severity] {
+			return severityRanks[a.Severity] < severityRanks[b.Severity]
+		}
+
+		return a.Path < b.Path
+	})
+
+	return report, nil
+}
+
+// digestUser return the user of the digest id
+func digestUser(id string) string {
+	if i := strings.LastIndex(id, ":"); i >= 0 {
+		return id[:i]
+	}
+
+	return id
+}
@@ -1,84 +1,272 @@
 package zookeeper
 
 import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"net"
 	"path/filepath"
-	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-zookeeper/zk"
 	"github.com/pkg/errors"
 )
 
+// sessionTimeout is the session timeout requested to the server
+const sessionTimeout = 10 * time.Second
+
 type Client struct {
 	*zk.Conn
+
+	listenersLock sync.Mutex
+	listeners     map[chan zk.Event]struct{}
+
+	// the negotiated session timeout and the last time a packet is received
+	// from the server in nanoseconds, see sessionConn
+	timeout  atomic.Int64
+	lastRecv atomic.Int64
+
+	watches persistentWatches
+
+	// the digest credentials added by AddAuth, and identified is true if the
+	// session has the SASL or x509 identities, see ExpandAuthACL
+	authLock   sync.Mutex
+	digests    []string
+	identified bool
+}
+
+type options struct {
+	tlsConfig    *tls.Config
+	saslUser     string
+	saslPassword string
+}
+
+// Option configure the connection of the client
+type Option func(o *options)
+
+// WithTLS connect the servers by TLS
+func WithTLS(cfg *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = cfg
+	}
+}
+
+// WithSASL authenticate by SASL DIGEST-MD5 on every connection
+func WithSASL(user, password string) Option {
+	return func(o *options) {
+		o.saslUser = user
+		o.saslPassword = password
+	}
 }
 
-func New(servers []string) (*Client, error) {
-	c, _, err := zk.Connect(servers, 10*time.Second)
+func New(servers []string, opts ...Option) (*Client, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	c := &Client{listeners: make(map[chan zk.Event]struct{})}
+	c.watches.paths = make(map[string]int)
+	c.watches.listeners = make(map[*nodeListener]struct{})
+	c.timeout.Store(int64(sessionTimeout))
+	c.identified = o.saslUser != "" || (o.tlsConfig != nil && (len(o.tlsConfig.Certificates) > 0 || o.tlsConfig.GetClientCertificate != nil))
+
+	// the result of the first sasl authentication
+	saslResult := make(chan error, 1)
+
+	dialer := func(network, address string, timeout time.Duration) (net.Conn, error) {
+		var (
+			conn net.Conn
+			err  error
+		)
+
+		if o.tlsConfig != nil {
+			conn, err = tls.DialWithDialer(&net.Dialer{Timeout: timeout}, network, address, o.tlsConfig)
+		} else {
+			conn, err = net.DialTimeout(network, address, timeout)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		conn = &sessionConn{Conn: conn, c: c}
+		if o.saslUser != "" {
+			conn = &saslConn{
+				Conn:     conn,
+				user:     o.saslUser,
+				password: o.saslPassword,
+				done: func(err error) {
+					select {
+					case saslResult <- err:
+					default:
+					}
+				},
+			}
+		}
+
+		return newWatchConn(conn, c), nil
+	}
+
+	conn, _, err := zk.Connect(servers, sessionTimeout, zk.WithEventCallback(c.dispatchEvent), zk.WithDialer(dialer))
 	if err != nil {
 		return nil, errors.Wrap(err, "fail to connect zk")
 	}
 
-	return &Client{c}, nil
-}
+	c.Conn = conn
 
-func (c *Client) EnableLogging(enable bool) {
-	c.SetLogger(logger{enable})
+	if o.saslUser != "" {
+		select {
+		case err = <-saslResult:
+		case <-time.After(10 * time.Second):
+			err = errors.New("sasl authentication timeout")
+		}
+
+		if err != nil {
+			conn.Close()
+			return nil, errors.Wrap(err, "fail to connect zk")
+		}
+	}
+
+	return c, nil
 }
 
-func (c *Client) walkNodes(path string, paths *[]string) error {
-	var s string
-	l, stat, err := c.Children(path)
-	if err != nil {
+// AddAuth add the auth of the session, the digest credentials are kept for
+// ExpandAuthACL
+func (c *Client) AddAuth(scheme string, auth []byte) error {
+	if err := c.Conn.AddAuth(scheme, auth); err != nil {
 		return err
 	}
 
-	if stat.NumChildren == 0 {
-		*paths = append(*paths, path)
-		return nil
+	if scheme == SchemeDigest {
+		c.authLock.Lock()
+		c.digests = append(c.digests, string(auth))
+		c.authLock.Unlock()
 	}
 
-	for _, key := range l {
-		if path == "/" {
-			s = "/" + key
-		} else {
-			s = path + "/" + key
-		}
+	return nil
+}
 
-		_, stat, err := c.Exists(s)
-		if err != nil {
-			return err
+// SessionTimeout return the session timeout negotiated with the server, it is
+// the requested timeout before connected
+func (c *Client) SessionTimeout() time.Duration {
+	return time.Duration(c.timeout.Load())
+}
+
+// LastReceived return the last time a packet is received from the server, the
+// server expires the session if it hears nothing from the client for the
+// session timeout, so the session is considered expired at LastReceived plus
+// SessionTimeout while disconnected
+func (c *Client) LastReceived() time.Time {
+	return time.Unix(0, c.lastRecv.Load())
+}
+
+// sessionConn record the negotiated session timeout of the connect response
+// and the time of the packets received from the server
+type sessionConn struct {
+	net.Conn
+
+	c *Client
+	// head is the first bytes of the connect response: length, protocol
+	// version and timeout
+	head []byte
+}
+
+func (s *sessionConn) Read(b []byte) (int, error) {
+	n, err := s.Conn.Read(b)
+	if n == 0 {
+		return n, err
+	}
+
+	s.c.lastRecv.Store(time.Now().UnixNano())
+
+	if need := 12 - len(s.head); need > 0 {
+		if need > n {
+			need = n
 		}
 
-		if stat.NumChildren == 0 {
-			*paths = append(*paths, s)
-		} else {
-			if err := c.walkNodes(s, paths); err != nil {
-				return err
+		s.head = append(s.head, b[:need]...)
+		if len(s.head) == 12 {
+			// the timeout is 0 if the session is expired
+			if ms := int32(binary.BigEndian.Uint32(s.head[8:12])); ms > 0 {
+				s.c.timeout.Store(int64(time.Duration(ms) * time.Millisecond))
 			}
 		}
 	}
 
+	return n, err
+}
+
+// WaitSession wait until the client has a session with the server, like after
+// reconnected from a network partition
+func (c *Client) WaitSession(ctx context.Context) error {
+	events, unsubscribe := c.SessionEvents()
+	defer unsubscribe()
+
+	for c.State() != zk.StateHasSession {
+		// the events may be dropped, check the state periodically
+		select {
+		case <-events:
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
 	return nil
 }
 
-// GetZnodes get zk nodes in the path
-func (c *Client) GetZnodes(path string) ([]string, error) {
-	paths := make([]string, 0)
+// SessionEvents subscribe the session state events, call the returned func to unsubscribe.
+// Events are dropped if the channel is not drained in time.
+func (c *Client) SessionEvents() (<-chan zk.Event, func()) {
+	ch := make(chan zk.Event, 16)
 
-	_, _, err := c.Exists(path)
-	if err != nil {
-		return paths, err
+	c.listenersLock.Lock()
+	c.listeners[ch] = struct{}{}
+	c.listenersLock.Unlock()
+
+	return ch, func() {
+		c.listenersLock.Lock()
+		delete(c.listeners, ch)
+		c.listenersLock.Unlock()
 	}
+}
 
-	err = c.walkNodes(path, &paths)
-	if err != nil {
-		return paths, err
+func (c *Client) dispatchEvent(ev zk.Event) {
+	if ev.Type != zk.EventSession {
+		c.watches.dispatch(ev)
+		return
 	}
 
-	sort.Strings(paths)
+	c.listenersLock.Lock()
+	defer c.listenersLock.Unlock()
+
+	for ch := range c.listeners {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+func (c *Client) EnableLogging(enable bool) {
+	c.SetLogger(logger{enable})
+}
+
+// GetZnodes get the znodes which have no children in the path
+func (c *Client) GetZnodes(path string) ([]string, error) {
+	paths := make([]string, 0)
+
+	err := c.Walk(path, WalkOptions{}, func(n *WalkNode) error {
+		if n.Depth > 0 && n.Stat.NumChildren == 0 {
+			paths = append(paths, n.Path)
+		}
+
+		return nil
+	})
 
-	return paths, nil
+	return paths, err
 }
 
 func (c *Client) DefaultCreate(path string, data []byte) (string, error) {
@@ -122,55 +310,67 @@ func (c *Client) forceCreate(path, srcPath string, data []byte, flags int32, acl
 	return err
 }
 
-// ForceDelete force delete multi-level node
+// ForceDelete force delete multi-level node, the deepest znodes are deleted first
 func (c *Client) ForceDelete(path string) error {
-	return c.forceDelete(path)
-}
-
-func (c *Client) forceDelete(path string) error {
 	if path == "/" {
 		return zk.ErrInvalidPath
 	}
 
-	var s string
-	l, stat, err := c.Children(path)
+	// the paths of every depth
+	levels := make([][]string, 0)
+	err := c.Walk(path, WalkOptions{Unordered: true}, func(n *WalkNode) error {
+		for len(levels) <= n.Depth {
+			levels = append(levels, nil)
+		}
+		levels[n.Depth] = append(levels[n.Depth], n.Path)
+
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
-	if stat.NumChildren == 0 {
-		return c.Delete(path, stat.Version)
-	}
-
-	for _, key := range l {
-		s = path + "/" + key
-
-		if err := c.forceDelete(s); err != nil {
-			return err
-		}
-
-		exist, _, err := c.Exists(s)
-		if err != nil {
+	for i := len(levels) - 1; i >= 0; i-- {
+		if err := c.deleteAll(levels[i]); err != nil {
 			return err
 		}
+	}
 
-		if !exist {
-			continue
-		}
+	return nil
+}
 
-		if err := c.Delete(s, stat.Version); err != nil {
-			return err
-		}
+// deleteAll delete the paths concurrently, the deleted paths are ignored
+func (c *Client) deleteAll(paths []string) error {
+	ch := make(chan string)
+	errs := make(chan error, DefaultWalkConcurrency)
+
+	var wg sync.WaitGroup
+	for i := 0; i < DefaultWalkConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for p := range ch {
+				if err := c.Delete(p, -1); err != nil && err != zk.ErrNoNode {
+					select {
+					case errs <- errors.Wrapf(err, "delete %s", p):
+					default:
+					}
+				}
+			}
+		}()
 	}
 
-	_, stat, err = c.Exists(path)
-	if err != nil {
-		return err
+	for _, p := range paths {
+		ch <- p
 	}
+	close(ch)
+	wg.Wait()
 
-	if stat.NumChildren == 0 {
-		return c.Delete(path, stat.Version)
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
 	}
-
-	return nil
 }
@@ -0,0 +1,97 @@
+package zookeeper
+
+import (
+	"sort"
+)
+
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+)
+
+// ZnodeChange is a difference of the znode between two dumps, A is nil if
+// added and B is nil if removed
+type ZnodeChange struct {
+	Path        string
+	Type        string
+	A           *DumpZnode
+	B           *DumpZnode
+	DataChanged bool
+	ACLChanged  bool
+}
+
+// CompareDumps compare the znodes of the dumps by the paths relative to the
+// dump paths, return the changes from a to b in path order
+func CompareDumps(a, b *Dump) []ZnodeChange {
+	znodesA := make(map[string]*DumpZnode, len(a.Znodes))
+	for i := range a.Znodes {
+		znodesA[a.Znodes[i].Path] = &a.Znodes[i]
+	}
+
+	znodesB := make(map[string]*DumpZnode, len(b.Znodes))
+	for i := range b.Znodes {
+		znodesB[b.Znodes[i].Path] = &b.Znodes[i]
+	}
+
+	changes := make([]ZnodeChange, 0)
+	for p, na := range znodesA {
+		nb, ok := znodesB[p]
+		if !ok {
+			changes = append(changes, ZnodeChange{Path: p, Type: ChangeRemoved, A: na})
+			continue
+		}
+
+		dataChanged := na.Data != nb.Data || na.DataEncoding != nb.DataEncoding
+		aclChanged := !equalACLs(na.ACL, nb.ACL)
+		if dataChanged || aclChanged {
+			changes = append(changes, ZnodeChange{
+				Path:        p,
+				Type:        ChangeModified,
+				A:           na,
+				B:           nb,
+				DataChanged: dataChanged,
+				ACLChanged:  aclChanged,
+			})
+		}
+	}
+
+	for p, nb := range znodesB {
+		if _, ok := znodesA[p]; !ok {
+			changes = append(changes, ZnodeChange{Path: p, Type: ChangeAdded, B: nb})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes
+}
+
+// SortedACLs return the ACL strings like scheme:id:perms in order, the order
+// of ACL has no effect on the permissions
+func SortedACLs(acls []DumpACL) []string {
+	s := make([]string, len(acls))
+	for i, a := range acls {
+		s[i] = a.Scheme + ":" + a.ID + ":" + a.Perms
+	}
+	sort.Strings(s)
+
+	return s
+}
+
+func equalACLs(a, b []DumpACL) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	sa, sb := SortedACLs(a), SortedACLs(b)
+	for i := range sa {
+		if sa[i] != sb[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,311 @@
+package zookeeper
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DumpVersion is the version of the dump format
+	DumpVersion = 1
+
+	// systemPath is the zookeeper internal znode, it is not exported
+	systemPath = "/zookeeper"
+)
+
+const (
+	ConflictFail      = "fail"
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+)
+
+var sequentialSuffix = regexp.MustCompile(`\d{10}$`)
+
+// Dump is a portable copy of a znode subtree
+type Dump struct {
+	Version int         `json:"version" yaml:"version"`
+	Path    string      `json:"path" yaml:"path"`
+	Time    time.Time   `json:"time" yaml:"time"`
+	Znodes  []DumpZnode `json:"znodes" yaml:"znodes"`
+}
+
+// DumpZnode is a znode of the dump, the path is relative to the dump path
+// and the root znode of the dump is "/"
+type DumpZnode struct {
+	Path         string    `json:"path" yaml:"path"`
+	Data         string    `json:"data" yaml:"data"`
+	DataEncoding string    `json:"dataEncoding,omitempty" yaml:"dataEncoding,omitempty"`
+	ACL          []DumpACL `json:"acl" yaml:"acl"`
+	// Ephemeral is true if the znode is owned by a session
+	Ephemeral bool `json:"ephemeral,omitempty" yaml:"ephemeral,omitempty"`
+	// Sequential is true if the znode name has a sequence suffix
+	Sequential bool `json:"sequential,omitempty" yaml:"sequential,omitempty"`
+}
+
+type DumpACL struct {
+	Scheme string `json:"scheme" yaml:"scheme"`
+	ID     string `json:"id" yaml:"id"`
+	Perms  string `json:"perms" yaml:"perms"`
+}
+
+// ImportOptions control how a dump is imported
+type ImportOptions struct {
+	// OnConflict is the policy if the znode exists: fail, skip or overwrite
+	OnConflict string
+	// ACL override the ACL of the dump if non-nil
+	ACL []zk.ACL
+	// Ephemeral import the ephemeral znodes as persistent znodes, they are
+	// skipped by default
+	Ephemeral bool
+}
+
+// ImportResult is the target paths of imported znodes
+type ImportResult struct {
+	Created []string `json:"created" yaml:"created"`
+	Updated []string `json:"updated" yaml:"updated"`
+	Skipped []string `json:"skipped" yaml:"skipped"`
+}
+
+// NewDumpZnode new a dump znode of the path relative to root
+func NewDumpZnode(root, path string, data []byte, acls []zk.ACL, stat *zk.Stat) DumpZnode {
+	n := DumpZnode{
+		Path:       relativePath(root, path),
+		ACL:        NewDumpACLs(acls),
+		Ephemeral:  stat.EphemeralOwner != 0,
+		Sequential: sequentialSuffix.MatchString(path),
+	}
+
+	var enc string
+	n.Data, enc = EncodeData(data)
+	if enc != DataEncodingUTF8 {
+		n.DataEncoding = enc
+	}
+
+	return n
+}
+
+func NewDumpACLs(acls []zk.ACL) []DumpACL {
+	das := make([]DumpACL, len(acls))
+	for i, a := range acls {
+		das[i] = DumpACL{Scheme: a.Scheme, ID: a.ID, Perms: FormatPerms(a.Perms)}
+	}
+
+	return das
+}
+
+// ZKACL convert the dump ACL to zk ACL
+func (n *DumpZnode) ZKACL() ([]zk.ACL, error) {
+	acls := make([]zk.ACL, len(n.ACL))
+	for i, a := range n.ACL {
+		perms, err := ParsePerms(a.Perms)
+		if err != nil {
+			return nil, err
+		}
+
+		acls[i] = zk.ACL{Scheme: a.Scheme, ID: a.ID, Perms: perms}
+	}
+
+	return acls, nil
+}
+
+// Export export the path and all its descendants, the zookeeper internal
+// znodes are not exported if the path is the root. The data and ACL are
+// always fetched whatever the options.
+func (c *Client) Export(path string, opts WalkOptions) (*Dump, error) {
+	if err := ValidatePath(path, false); err != nil {
+		return nil, err
+	}
+
+	d := &Dump{
+		Version: DumpVersion,
+		Path:    path,
+		Time:    time.Now(),
+		Znodes:  make([]DumpZnode, 0),
+	}
+
+	opts.Data, opts.ACL, opts.Unordered = true, true, false
+	if path == "/" {
+		opts.Exclude = append([]string{systemPath}, opts.Exclude...)
+	}
+
+	err := c.Walk(path, opts, func(n *WalkNode) error {
+		d.Znodes = append(d.Znodes, NewDumpZnode(path, n.Path, n.Data, n.ACL, n.Stat))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// Import create the znodes of the dump under the target path, parents of the
+// target path are created if not exist. The conflicts are checked before any
+// write, so the import fails with the fail policy without writing. The znodes
+// are created with the open ACL, then the ACL are set from the deepest, so an
+// ACL without the create permission does not block creating the children.
+func (c *Client) Import(d *Dump, target string, opts ImportOptions) (*ImportResult, error) {
+	if d.Version != DumpVersion {
+		return nil, fmt.Errorf("unsupported dump version: %d", d.Version)
+	}
+
+	if err := ValidatePath(target, false); err != nil {
+		return nil, err
+	}
+
+	switch opts.OnConflict {
+	case "":
+		opts.OnConflict = ConflictFail
+	case ConflictFail, ConflictSkip, ConflictOverwrite:
+	default:
+		return nil, fmt.Errorf("unknown conflict policy: %s", opts.OnConflict)
+	}
+
+	znodes := make([]DumpZnode, len(d.Znodes))
+	copy(znodes, d.Znodes)
+	sort.SliceStable(znodes, func(i, j int) bool {
+		return znodes[i].Path < znodes[j].Path
+	})
+
+	res := &ImportResult{
+		Created: make([]string, 0),
+		Updated: make([]string, 0),
+		Skipped: make([]string, 0),
+	}
+
+	// decode the znodes and check the conflicts before any write
+	imports := make([]*importZnode, 0, len(znodes))
+	for _, n := range znodes {
+		p := JoinDumpPath(target, n.Path)
+		if err := ValidatePath(p, false); err != nil {
+			return res, errors.Wrapf(err, "invalid path %s", p)
+		}
+
+		// the root znode always exists and is not imported
+		if p == "/" || (n.Ephemeral && !opts.Ephemeral) {
+			res.Skipped = append(res.Skipped, p)
+			continue
+		}
+
+		z, err := c.newImportZnode(p, n, opts)
+		if err != nil {
+			return res, errors.Wrapf(err, "import %s", p)
+		}
+		if z.exist && opts.OnConflict == ConflictFail {
+			return res, errors.Wrapf(zk.ErrNodeExists, "import %s", p)
+		}
+
+		imports = append(imports, z)
+	}
+
+	for _, z := range imports {
+		if z.exist && opts.OnConflict == ConflictSkip {
+			res.Skipped = append(res.Skipped, z.path)
+			continue
+		}
+
+		if err := c.importData(z); err != nil {
+			return res, errors.Wrapf(err, "import %s", z.path)
+		}
+
+		if z.exist {
+			res.Updated = append(res.Updated, z.path)
+		} else {
+			res.Created = append(res.Created, z.path)
+		}
+	}
+
+	// the children are after the parents
+	for i := len(imports) - 1; i >= 0; i-- {
+		z := imports[i]
+		if z.exist && opts.OnConflict == ConflictSkip {
+			continue
+		}
+
+		if _, err := c.SetACL(z.path, z.acls, -1); err != nil {
+			return res, errors.Wrapf(err, "set the ACL of %s", z.path)
+		}
+	}
+
+	return res, nil
+}
+
+// importZnode is a znode of the dump to import
+type importZnode struct {
+	path  string
+	data  []byte
+	acls  []zk.ACL
+	exist bool
+}
+
+func (c *Client) newImportZnode(path string, n DumpZnode, opts ImportOptions) (*importZnode, error) {
+	z := &importZnode{path: path, acls: opts.ACL}
+
+	var err error
+	if z.data, err = DecodeData(n.Data, n.DataEncoding); err != nil {
+		return nil, err
+	}
+
+	if z.acls == nil {
+		if z.acls, err = n.ZKACL(); err != nil {
+			return nil, err
+		}
+	}
+
+	if z.exist, _, err = c.Exists(path); err != nil {
+		return nil, err
+	}
+
+	return z, nil
+}
+
+// importData create the znode with the open ACL or set the data of the
+// existing znode, the ACL is set after the children are created
+func (c *Client) importData(z *importZnode) error {
+	if z.exist {
+		_, err := c.Set(z.path, z.data, -1)
+		return err
+	}
+
+	// the parents of the target path may not exist
+	if err := c.ForceCreate(filepath.Dir(z.path), nil, 0, zk.WorldACL(zk.PermAll)); err != nil {
+		return err
+	}
+
+	_, err := c.Create(z.path, z.data, 0, zk.WorldACL(zk.PermAll))
+	return err
+}
+
+// relativePath return the path relative to root, the root itself is "/"
+func relativePath(root, path string) string {
+	if root == "/" {
+		return path
+	}
+
+	rel := strings.TrimPrefix(path, root)
+	if rel == "" {
+		return "/"
+	}
+
+	return rel
+}
+
+// JoinDumpPath join the path relative to the dump path to root
+func JoinDumpPath(root, rel string) string {
+	if rel == "/" {
+		return root
+	}
+
+	if root == "/" {
+		return rel
+	}
+
+	return root + rel
+}
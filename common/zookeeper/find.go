@@ -0,0 +1,125 @@
+package zookeeper
+
+import (
+	"path"
+	"regexp"
+	"time"
+)
+
+// Range is an inclusive range of integers
+type Range struct {
+	Min int64
+	Max int64
+}
+
+// Contains report whether v is in the range
+func (r *Range) Contains(v int64) bool {
+	return r == nil || (v >= r.Min && v <= r.Max)
+}
+
+// TimeRange is a range of time, the zero After or Before is unbounded
+type TimeRange struct {
+	After  time.Time
+	Before time.Time
+}
+
+// Contains report whether t is in the range
+func (r TimeRange) Contains(t time.Time) bool {
+	return (r.After.IsZero() || !t.Before(r.After)) && (r.Before.IsZero() || !t.After(r.Before))
+}
+
+// FindFilter filter the znodes of Find, the zero value matches all znodes and
+// the nil ranges match any value
+type FindFilter struct {
+	// Name are the glob patterns of the znode name, see MatchPattern
+	Name []string
+	// NameRegexp match the znode name
+	NameRegexp *regexp.Regexp
+	// Data match the data of the znode
+	Data *regexp.Regexp
+	// Size is the range of the data length
+	Size *Range
+	// Version, Cversion and Aversion are the ranges of the data, children
+	// and ACL versions
+	Version  *Range
+	Cversion *Range
+	Aversion *Range
+	// Children is the range of the number of children
+	Children *Range
+	// Ctime and Mtime are the ranges of the created and modified time
+	Ctime TimeRange
+	Mtime TimeRange
+	// Ephemeral only match the ephemeral znodes if true, or the persistent
+	// znodes if false
+	Ephemeral *bool
+	// Owner only match the ephemeral znodes of the session if it is not 0
+	Owner int64
+	// ACLSchemes match the znodes with an ACL of one of the schemes
+	ACLSchemes []string
+}
+
+// Match report whether the walked znode matches the filter, the data and ACL
+// must be fetched if they are filtered
+func (f *FindFilter) Match(n *WalkNode) bool {
+	name := path.Base(n.Path)
+	if len(f.Name) > 0 && !matchAny(f.Name, n.Path) {
+		return false
+	}
+	if f.NameRegexp != nil && !f.NameRegexp.MatchString(name) {
+		return false
+	}
+
+	s := n.Stat
+	switch {
+	case !f.Size.Contains(int64(s.DataLength)),
+		!f.Version.Contains(int64(s.Version)),
+		!f.Cversion.Contains(int64(s.Cversion)),
+		!f.Aversion.Contains(int64(s.Aversion)),
+		!f.Children.Contains(int64(s.NumChildren)),
+		!f.Ctime.Contains(time.UnixMilli(s.Ctime)),
+		!f.Mtime.Contains(time.UnixMilli(s.Mtime)):
+		return false
+	case f.Ephemeral != nil && *f.Ephemeral != (s.EphemeralOwner != 0):
+		return false
+	case f.Owner != 0 && s.EphemeralOwner != f.Owner:
+		return false
+	}
+
+	if len(f.ACLSchemes) > 0 && !matchACLScheme(n, f.ACLSchemes) {
+		return false
+	}
+
+	// the data is matched at last, it is the most expensive
+	return f.Data == nil || f.Data.Match(n.Data)
+}
+
+func matchACLScheme(n *WalkNode, schemes []string) bool {
+	for _, a := range n.ACL {
+		for _, scheme := range schemes {
+			if a.Scheme == scheme {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Find walk the root and its descendants, fn is called for the znodes matched
+// by the filter, the data and ACL are fetched if they are filtered
+func (c *Client) Find(root string, opts WalkOptions, filter FindFilter, fn WalkFunc) error {
+	if filter.Data != nil {
+		opts.Data = true
+	}
+	if len(filter.ACLSchemes) > 0 {
+		opts.ACL = true
+	}
+
+	return c.Walk(root, opts, func(n *WalkNode) error {
+		if !filter.Match(n) {
+			return nil
+		}
+
+		return fn(n)
+	})
+}
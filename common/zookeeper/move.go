@@ -0,0 +1,305 @@
+package zookeeper
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-zookeeper/zk"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxBatchOps and maxBatchBytes limit the size of a multi request, the
+	// request must be less than jute.maxbuffer of the server
+	maxBatchOps   = 1000
+	maxBatchBytes = 512 * 1024
+
+	// batchOpOverhead is the estimated bytes of an operation except its path and data
+	batchOpOverhead = 64
+)
+
+// MoveResult is the paths of moved znodes
+type MoveResult struct {
+	// Created are the target paths in pre-order
+	Created []string `json:"created" yaml:"created"`
+	// Deleted are the source paths, the children are before their parent
+	Deleted []string `json:"deleted" yaml:"deleted"`
+	// Batches is the number of multi requests, the move is atomic if it is 1
+	Batches int `json:"batches" yaml:"batches"`
+}
+
+// Copy copy the path, and its descendants if recursive, to the target path of
+// the dst client, dst can be the same client or a client of another cluster
+func (c *Client) Copy(dst *Client, path, target string, recursive bool, opts ImportOptions) (*ImportResult, error) {
+	if c == dst && recursive && isSubtree(path, target) {
+		return nil, fmt.Errorf("cannot copy %s to its subtree %s", path, target)
+	}
+
+	d, err := c.Export(path, subtreeOptions(path, recursive))
+	if err != nil {
+		return nil, err
+	}
+
+	return dst.Import(d, target, opts)
+}
+
+// Move move the path, and its descendants if recursive, to the target path of
+// the dst client. The znodes are created and deleted by multi requests, if
+// they are too many for one request, the committed batches are rolled back
+// when a batch failed. The parents of the target are created if not exist,
+// and the ephemeral znodes are moved as persistent if opts.Ephemeral.
+// opts.OnConflict is ignored, the move fails if the target exists. The znodes
+// are created with the open ACL like Import, then the ACL are set from the
+// deepest after moved.
+func (c *Client) Move(dst *Client, path, target string, recursive bool, opts ImportOptions) (*MoveResult, error) {
+	if path == "/" {
+		return nil, zk.ErrInvalidPath
+	}
+
+	if err := ValidatePath(target, false); err != nil {
+		return nil, err
+	}
+
+	if c == dst && isSubtree(path, target) {
+		return nil, fmt.Errorf("cannot move %s to %s", path, target)
+	}
+
+	nodes := make([]*WalkNode, 0)
+	err := c.Walk(path, subtreeOptions(path, recursive), func(n *WalkNode) error {
+		if n.Depth == 0 && !recursive && n.Stat.NumChildren > 0 {
+			return errors.Wrapf(zk.ErrNotEmpty, "%s has children", path)
+		}
+
+		if n.Stat.EphemeralOwner != 0 && !opts.Ephemeral {
+			return fmt.Errorf("%s is ephemeral", n.Path)
+		}
+
+		nodes = append(nodes, n)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := dst.ForceCreate(filepath.Dir(target), nil, 0, zk.WorldACL(zk.PermAll)); err != nil {
+		return nil, errors.Wrapf(err, "create parents of %s", target)
+	}
+
+	res := &MoveResult{
+		Created: make([]string, len(nodes)),
+		Deleted: make([]string, len(nodes)),
+	}
+
+	creates := make([]interface{}, len(nodes))
+	deletes := make([]interface{}, len(nodes))
+	acls := make([][]zk.ACL, len(nodes))
+	for i, n := range nodes {
+		acls[i] = opts.ACL
+		if acls[i] == nil {
+			acls[i] = n.ACL
+		}
+
+		p := JoinDumpPath(target, relativePath(path, n.Path))
+		res.Created[i] = p
+		creates[i] = &zk.CreateRequest{Path: p, Data: n.Data, Acl: zk.WorldACL(zk.PermAll)}
+
+		// the children are deleted before their parent
+		j := len(nodes) - 1 - i
+		res.Deleted[j] = n.Path
+		deletes[j] = &zk.DeleteRequest{Path: n.Path, Version: n.Stat.Version}
+	}
+
+	// all the operations in one request if possible
+	if c == dst {
+		if all := append(append([]interface{}{}, creates...), deletes...); len(batchOps(all)) == 1 {
+			res.Batches = 1
+			if err := c.multi(all); err != nil {
+				return res, err
+			}
+
+			return res, dst.setACLs(res.Created, acls)
+		}
+	}
+
+	createBatches, deleteBatches := batchOps(creates), batchOps(deletes)
+	res.Batches = len(createBatches) + len(deleteBatches)
+
+	for i, batch := range createBatches {
+		if err := dst.multi(batch); err != nil {
+			return res, rollbackMove(err, dst.undoCreates(createBatches[:i]))
+		}
+	}
+
+	for i, batch := range deleteBatches {
+		if err := c.multi(batch); err != nil {
+			rbErr := c.undoDeletes(nodes, deleteBatches[:i])
+			if rbErr == nil {
+				rbErr = dst.undoCreates(createBatches)
+			}
+
+			return res, rollbackMove(err, rbErr)
+		}
+	}
+
+	return res, dst.setACLs(res.Created, acls)
+}
+
+// setACLs set the ACL of the paths in pre-order from the deepest, so an ACL
+// without the create or delete permission does not block its descendants
+func (c *Client) setACLs(paths []string, acls [][]zk.ACL) error {
+	for i := len(paths) - 1; i >= 0; i-- {
+		if EqualACL(acls[i], zk.WorldACL(zk.PermAll)) {
+			continue
+		}
+
+		if _, err := c.SetACL(paths[i], acls[i], -1); err != nil {
+			return errors.Wrapf(err, "set the ACL of %s", paths[i])
+		}
+	}
+
+	return nil
+}
+
+func rollbackMove(err, rbErr error) error {
+	if rbErr != nil {
+		return errors.Wrapf(err, "move failed and rollback failed: %v", rbErr)
+	}
+
+	return errors.Wrap(err, "move failed and rolled back")
+}
+
+// undoCreates delete the created znodes of the batches
+func (c *Client) undoCreates(batches [][]interface{}) error {
+	for i := len(batches) - 1; i >= 0; i-- {
+		batch := batches[i]
+
+		deletes := make([]interface{}, len(batch))
+		for j, op := range batch {
+			deletes[len(batch)-1-j] = &zk.DeleteRequest{Path: op.(*zk.CreateRequest).Path, Version: -1}
+		}
+
+		if err := c.multi(deletes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// undoDeletes recreate the deleted znodes of the batches
+func (c *Client) undoDeletes(nodes []*WalkNode, batches [][]interface{}) error {
+	deleted := make(map[string]bool)
+	for _, batch := range batches {
+		for _, op := range batch {
+			deleted[op.(*zk.DeleteRequest).Path] = true
+		}
+	}
+
+	creates := make([]interface{}, 0, len(deleted))
+	paths := make([]string, 0, len(deleted))
+	acls := make([][]zk.ACL, 0, len(deleted))
+	for _, n := range nodes {
+		if deleted[n.Path] {
+			creates = append(creates, &zk.CreateRequest{Path: n.Path, Data: n.Data, Acl: zk.WorldACL(zk.PermAll)})
+			paths = append(paths, n.Path)
+			acls = append(acls, n.ACL)
+		}
+	}
+
+	for _, batch := range batchOps(creates) {
+		if err := c.multi(batch); err != nil {
+			return err
+		}
+	}
+
+	return c.setACLs(paths, acls)
+}
+
+// multi execute the operations in one multi request, return the error of the
+// failed operation
+func (c *Client) multi(ops []interface{}) error {
+	resps, err := c.Multi(ops...)
+	for i, resp := range resps {
+		if resp.Error != nil {
+			return errors.Wrap(resp.Error, opPath(ops[i]))
+		}
+	}
+
+	return err
+}
+
+// batchOps split the operations to batches by the size limits of multi request
+func batchOps(ops []interface{}) [][]interface{} {
+	batches := make([][]interface{}, 0)
+
+	var (
+		batch []interface{}
+		size  int
+	)
+	for _, op := range ops {
+		n := len(opPath(op)) + batchOpOverhead
+		if create, ok := op.(*zk.CreateRequest); ok {
+			n += len(create.Data)
+		}
+
+		if len(batch) > 0 && (len(batch) >= maxBatchOps || size+n > maxBatchBytes) {
+			batches = append(batches, batch)
+			batch, size = nil, 0
+		}
+
+		batch = append(batch, op)
+		size += n
+	}
+
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+
+	return batches
+}
+
+func opPath(op interface{}) string {
+	switch o := op.(type) {
+	case *zk.CreateRequest:
+		return o.Path
+	case *zk.DeleteRequest:
+		return o.Path
+	case *zk.SetDataRequest:
+		return o.Path
+	case *zk.CheckVersionRequest:
+		return o.Path
+	}
+
+	return ""
+}
+
+// subtreeOptions return the walk options of the path, the descendants are
+// excluded if not recursive
+func subtreeOptions(path string, recursive bool) WalkOptions {
+	opts := WalkOptions{Data: true, ACL: true}
+	if !recursive {
+		opts.Exclude = []string{strings.TrimSuffix(escapeGlob(path), "/") + "/*"}
+	}
+
+	return opts
+}
+
+// isSubtree report whether p is the root or in the subtree of root
+func isSubtree(root, p string) bool {
+	return p == root || root == "/" || strings.HasPrefix(p, root+"/")
+}
+
+// escapeGlob escape the special chars of the glob pattern
+func escapeGlob(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
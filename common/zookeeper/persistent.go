@@ -0,0 +1,306 @@
+package zookeeper
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+	"github.com/pkg/errors"
+)
+
+const (
+	// opAddWatch and opRemoveWatches are the opcodes of the persistent watch
+	// requests of ZooKeeper 3.6+, they are not supported by the zk client
+	opAddWatch      = 106
+	opRemoveWatches = 18
+
+	// addWatchModePersistentRecursive is the mode of addWatch, and
+	// watcherTypePersistentRecursive is the watcher type of removeWatches
+	addWatchModePersistentRecursive = 1
+	watcherTypePersistentRecursive  = 5
+
+	// watchXid is the xid of the persistent watch requests, the zk client
+	// uses the non-negative xids and the special negative ones above -10
+	watchXid = -100
+
+	// errUnimplemented is the error code of the unknown opcodes
+	errUnimplemented = -6
+
+	// nodeEventsSize is the buffer size of the znode events of a watch
+	nodeEventsSize = 1024
+)
+
+// ErrPersistentWatchUnsupported is returned if the server is before
+// ZooKeeper 3.6 and does not support the persistent watches
+var ErrPersistentWatchUnsupported = errors.New("zk: the persistent watches are not supported by the server")
+
+// persistentWatches are the persistent recursive watches of the client, they
+// are added again on every connection since the zk client does not restore
+// them after reconnected
+type persistentWatches struct {
+	lock sync.Mutex
+	// paths are the watched paths with the number of the watchers
+	paths map[string]int
+	conn  *watchConn
+	// unsupported is set if the server replied unimplemented, the server
+	// before 3.6 closes the connection after that
+	unsupported bool
+
+	listenersLock sync.Mutex
+	listeners     map[*nodeListener]struct{}
+}
+
+// nodeListener receive the znode events of the persistent watches
+type nodeListener struct {
+	ch chan zk.Event
+	// dropped is set if an event is dropped since ch is full
+	dropped atomic.Bool
+}
+
+// addPersistentWatch add the persistent recursive watch of the path and
+// subscribe the znode events, call the returned func to remove the watch.
+// ErrPersistentWatchUnsupported is returned for the servers before 3.6.
+func (c *Client) addPersistentWatch(path string) (*nodeListener, func(), error) {
+	p := &c.watches
+
+	p.lock.Lock()
+	if p.unsupported {
+		p.lock.Unlock()
+		return nil, nil, ErrPersistentWatchUnsupported
+	}
+	p.paths[path]++
+	conn := p.conn
+	p.lock.Unlock()
+
+	l := &nodeListener{ch: make(chan zk.Event, nodeEventsSize)}
+	p.listenersLock.Lock()
+	p.listeners[l] = struct{}{}
+	p.listenersLock.Unlock()
+
+	unregister := func() bool {
+		p.listenersLock.Lock()
+		delete(p.listeners, l)
+		p.listenersLock.Unlock()
+
+		p.lock.Lock()
+		defer p.lock.Unlock()
+
+		p.paths[path]--
+		if p.paths[path] > 0 {
+			return false
+		}
+		delete(p.paths, path)
+
+		return true
+	}
+
+	remove := func() {
+		if !unregister() {
+			return
+		}
+
+		p.lock.Lock()
+		conn := p.conn
+		p.lock.Unlock()
+
+		if conn != nil {
+			_ = conn.request(opRemoveWatches, encodeWatchRequest(path, watcherTypePersistentRecursive))
+		}
+	}
+
+	// added on the next connection if not connected, the client should wait
+	// the session before, see WaitSession
+	if conn == nil {
+		return l, remove, nil
+	}
+
+	switch err := conn.request(opAddWatch, encodeWatchRequest(path, addWatchModePersistentRecursive)); err {
+	case nil, zk.ErrConnectionClosed:
+	case ErrPersistentWatchUnsupported:
+		p.lock.Lock()
+		p.unsupported = true
+		p.lock.Unlock()
+
+		unregister()
+		return nil, nil, err
+	default:
+		remove()
+		return nil, nil, err
+	}
+
+	return l, remove, nil
+}
+
+// connected set the new connection and return the paths to add on it, it is
+// called before the zk client knows the session is established
+func (p *persistentWatches) connected(conn *watchConn) []string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.conn = conn
+	paths := make([]string, 0, len(p.paths))
+	for path := range p.paths {
+		paths = append(paths, path)
+	}
+
+	return paths
+}
+
+// restore add the persistent watches of the paths on the connection
+func restore(conn *watchConn, paths []string) {
+	for _, path := range paths {
+		if err := conn.request(opAddWatch, encodeWatchRequest(path, addWatchModePersistentRecursive)); err != nil {
+			return
+		}
+	}
+}
+
+// dispatch send the znode event to the listeners, the event is dropped if
+// the listener is full
+func (p *persistentWatches) dispatch(ev zk.Event) {
+	p.listenersLock.Lock()
+	defer p.listenersLock.Unlock()
+
+	for l := range p.listeners {
+		select {
+		case l.ch <- ev:
+		default:
+			l.dropped.Store(true)
+		}
+	}
+}
+
+// inSubtree report whether the path is the root or its descendant
+func inSubtree(root, path string) bool {
+	return root == "/" || path == root || strings.HasPrefix(path, root+"/")
+}
+
+// encodeWatchRequest encode the request of addWatch and removeWatches: the
+// path and the mode or the watcher type
+func encodeWatchRequest(path string, mode int32) []byte {
+	b := make([]byte, 8+len(path))
+	binary.BigEndian.PutUint32(b[0:], uint32(len(path)))
+	copy(b[4:], path)
+	binary.BigEndian.PutUint32(b[4+len(path):], uint32(mode))
+
+	return b
+}
+
+// watchConn send the persistent watch requests on the connection beside the
+// zk client, the replies of them are taken out before the zk client reads
+type watchConn struct {
+	net.Conn
+
+	c *Client
+
+	writeLock sync.Mutex
+	// reqLock serialize the requests, so one reply is pending at most
+	reqLock sync.Mutex
+	reply   chan int32
+
+	connected bool
+	buf       []byte
+}
+
+func newWatchConn(conn net.Conn, c *Client) *watchConn {
+	return &watchConn{Conn: conn, c: c, reply: make(chan int32, 1)}
+}
+
+// Write write the requests of the zk client, the zk client writes a request
+// by one call
+func (w *watchConn) Write(b []byte) (int, error) {
+	w.writeLock.Lock()
+	defer w.writeLock.Unlock()
+
+	return w.Conn.Write(b)
+}
+
+func (w *watchConn) Read(b []byte) (int, error) {
+	for len(w.buf) == 0 {
+		frame, err := readFrame(w.Conn)
+		if err != nil {
+			return 0, err
+		}
+
+		if !w.connected {
+			w.connected = true
+
+			// the connect response: length, protocol version and timeout, the
+			// timeout is 0 if the session is expired
+			if len(frame) >= 12 && int32(binary.BigEndian.Uint32(frame[8:12])) > 0 && w.c != nil {
+				// the replies are read after the connect response is passed
+				go restore(w, w.c.watches.connected(w))
+			}
+		} else if len(frame) >= 20 && int32(binary.BigEndian.Uint32(frame[4:8])) == watchXid {
+			// the reply header: xid, zxid and err
+			select {
+			case w.reply <- int32(binary.BigEndian.Uint32(frame[16:20])):
+			default:
+			}
+			continue
+		}
+
+		w.buf = frame
+	}
+
+	n := copy(b, w.buf)
+	w.buf = w.buf[n:]
+
+	return n, nil
+}
+
+// request send the request of the opcode and wait the reply,
+// zk.ErrConnectionClosed is returned if the request is not replied
+func (w *watchConn) request(op int32, body []byte) error {
+	w.reqLock.Lock()
+	defer w.reqLock.Unlock()
+
+	// the reply of the timed out request
+	select {
+	case <-w.reply:
+	default:
+	}
+
+	xid := int32(watchXid)
+	req := make([]byte, 12+len(body))
+	binary.BigEndian.PutUint32(req[0:], uint32(8+len(body)))
+	binary.BigEndian.PutUint32(req[4:], uint32(xid))
+	binary.BigEndian.PutUint32(req[8:], uint32(op))
+	copy(req[12:], body)
+
+	if _, err := w.Write(req); err != nil {
+		return zk.ErrConnectionClosed
+	}
+
+	timeout := sessionTimeout
+	if w.c != nil {
+		timeout = w.c.SessionTimeout()
+	}
+
+	select {
+	case code := <-w.reply:
+		return watchError(code)
+	case <-time.After(timeout):
+		return zk.ErrConnectionClosed
+	}
+}
+
+// watchError return the error of the reply error code
+func watchError(code int32) error {
+	switch code {
+	case 0:
+		return nil
+	case errUnimplemented:
+		return ErrPersistentWatchUnsupported
+	case -101:
+		return zk.ErrNoNode
+	case -102:
+		return zk.ErrNoAuth
+	}
+
+	return errors.Errorf("zk: persistent watch error code %d", code)
+}
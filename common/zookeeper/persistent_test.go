@@ -0,0 +1,131 @@
+package zookeeper
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/go-zookeeper/zk"
+)
+
+// replyFrame build the reply frame of the server: length, xid, zxid, err,
+// then the body
+func replyFrame(xid, code int32, body []byte) []byte {
+	frame := make([]byte, 20+len(body))
+	binary.BigEndian.PutUint32(frame[0:], uint32(16+len(body)))
+	binary.BigEndian.PutUint32(frame[4:], uint32(xid))
+	binary.BigEndian.PutUint64(frame[8:], 0x100000042)
+	binary.BigEndian.PutUint32(frame[16:], uint32(code))
+	copy(frame[20:], body)
+
+	return frame
+}
+
+// connectFrame build the connect response: length, protocol version, timeout,
+// session id and password
+func connectFrame(timeout int32) []byte {
+	frame := make([]byte, 40)
+	binary.BigEndian.PutUint32(frame[0:], 36)
+	binary.BigEndian.PutUint32(frame[8:], uint32(timeout))
+	binary.BigEndian.PutUint64(frame[12:], 0x1000001)
+	binary.BigEndian.PutUint32(frame[20:], 16)
+
+	return frame
+}
+
+func TestWatchConnRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    int32
+		wantErr bool
+		errIs   error
+	}{
+		{name: "added", code: 0},
+		{name: "before 3.6", code: errUnimplemented, wantErr: true, errIs: ErrPersistentWatchUnsupported},
+		{name: "no auth", code: -102, wantErr: true, errIs: zk.ErrNoAuth},
+		{name: "other error", code: -2, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			defer server.Close()
+
+			w := newWatchConn(client, nil)
+
+			// the zk client reads the frames passed by the watch conn
+			frames := make(chan []byte, 4)
+			go func() {
+				for {
+					frame, err := readFrame(w)
+					if err != nil {
+						close(frames)
+						return
+					}
+					frames <- frame
+				}
+			}()
+
+			go func() {
+				_, _ = server.Write(connectFrame(4000))
+
+				req, err := readFrame(server)
+				if err != nil {
+					t.Errorf("read the request: %v", err)
+					return
+				}
+				if xid := int32(binary.BigEndian.Uint32(req[4:8])); xid != watchXid {
+					t.Errorf("xid = %d, want %d", xid, watchXid)
+				}
+				if op := binary.BigEndian.Uint32(req[8:12]); op != opAddWatch {
+					t.Errorf("opcode = %d, want %d", op, opAddWatch)
+				}
+				if body := string(req[12:]); body != string(encodeWatchRequest("/app", addWatchModePersistentRecursive)) {
+					t.Errorf("request body = %x", body)
+				}
+
+				// the reply of the zk client request is passed before
+				_, _ = server.Write(replyFrame(7, 0, []byte("data")))
+				_, _ = server.Write(replyFrame(watchXid, tt.code, nil))
+				server.Close()
+			}()
+
+			if frame := <-frames; string(frame) != string(connectFrame(4000)) {
+				t.Fatalf("connect response = %x", frame)
+			}
+
+			err := w.request(opAddWatch, encodeWatchRequest("/app", addWatchModePersistentRecursive))
+			if (err != nil) != tt.wantErr || (tt.errIs != nil && err != tt.errIs) {
+				t.Errorf("request() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var got [][]byte
+			for frame := range frames {
+				got = append(got, frame)
+			}
+			if len(got) != 1 || int32(binary.BigEndian.Uint32(got[0][4:8])) != 7 {
+				t.Errorf("the zk client read %d frames: %x", len(got), got)
+			}
+		})
+	}
+}
+
+func TestInSubtree(t *testing.T) {
+	tests := []struct {
+		root, path string
+		want       bool
+	}{
+		{"/app", "/app", true},
+		{"/app", "/app/a/b", true},
+		{"/app", "/apple", false},
+		{"/app", "/", false},
+		{"/", "/zookeeper", true},
+	}
+
+	for _, tt := range tests {
+		if got := inSubtree(tt.root, tt.path); got != tt.want {
+			t.Errorf("inSubtree(%s, %s) = %v, want %v", tt.root, tt.path, got, tt.want)
+		}
+	}
+}
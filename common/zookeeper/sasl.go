@@ -0,0 +1,238 @@
+package zookeeper
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// opSASL is the opcode of the sasl request, it is not supported by the zk client
+	opSASL = 102
+
+	// saslXid is the xid of the sasl requests, they are sent before the
+	// requests of the zk client on the connection
+	saslXid = 1
+
+	// saslDigestRealm and saslDigestURI are the realm and digest-uri of the
+	// DIGEST-MD5 mechanism of the ZooKeeper server
+	saslDigestRealm = "zk-sasl-md5"
+	saslDigestURI   = "zookeeper/zk-sasl-md5"
+
+	// errAuthFailed is the error code of sasl authentication failure
+	errAuthFailed = -115
+)
+
+// ErrSASLAuthFailed is returned if the server rejected the sasl credentials
+var ErrSASLAuthFailed = errors.New("zk: sasl authentication failed")
+
+// saslConn authenticate by SASL DIGEST-MD5 after the session is established,
+// the zk client does not support SASL. The connect response is held until the
+// authentication is done, so that no request of the zk client is sent before.
+type saslConn struct {
+	net.Conn
+
+	user     string
+	password string
+	done     func(err error)
+
+	once sync.Once
+	buf  *bytes.Reader
+	err  error
+}
+
+func (c *saslConn) Read(b []byte) (int, error) {
+	c.once.Do(func() {
+		var frame []byte
+		frame, c.err = readFrame(c.Conn)
+		if c.err != nil {
+			return
+		}
+
+		c.buf = bytes.NewReader(frame)
+
+		// the session timeout is 0 if the session is expired, the zk client
+		// will reconnect with a new session
+		if len(frame) < 12 || int32(binary.BigEndian.Uint32(frame[8:12])) <= 0 {
+			return
+		}
+
+		c.err = c.authenticate()
+		c.done(c.err)
+	})
+
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	if c.buf != nil && c.buf.Len() > 0 {
+		return c.buf.Read(b)
+	}
+
+	return c.Conn.Read(b)
+}
+
+// authenticate run the DIGEST-MD5 exchange, see RFC 2831
+func (c *saslConn) authenticate() error {
+	challenge, err := c.exchange(nil)
+	if err != nil {
+		return err
+	}
+
+	params := parseDigestChallenge(string(challenge))
+	nonce := params["nonce"]
+	if nonce == "" {
+		return errors.Errorf("invalid sasl challenge: %s", challenge)
+	}
+
+	realm, ok := params["realm"]
+	if !ok {
+		realm = saslDigestRealm
+	}
+
+	cnonceBytes := make([]byte, 16)
+	if _, err := rand.Read(cnonceBytes); err != nil {
+		return err
+	}
+	cnonce := hex.EncodeToString(cnonceBytes)
+
+	const nc, qop = "00000001", "auth"
+	response := fmt.Sprintf(
+		`charset=utf-8,username="%s",realm="%s",nonce="%s",nc=%s,cnonce="%s",digest-uri="%s",maxbuf=65536,response=%s,qop=%s`,
+		c.user, realm, nonce, nc, cnonce, saslDigestURI,
+		digestResponse(c.user, realm, c.password, nonce, cnonce, nc, qop, "AUTHENTICATE:"+saslDigestURI),
+		qop,
+	)
+
+	rspauth, err := c.exchange([]byte(response))
+	if err != nil {
+		return err
+	}
+
+	expected := "rspauth=" + digestResponse(c.user, realm, c.password, nonce, cnonce, nc, qop, ":"+saslDigestURI)
+	if string(rspauth) != expected {
+		return errors.New("zk: invalid sasl rspauth of the server")
+	}
+
+	return nil
+}
+
+// exchange send the sasl token and return the token of the server
+func (c *saslConn) exchange(token []byte) ([]byte, error) {
+	req := make([]byte, 16+len(token))
+	binary.BigEndian.PutUint32(req[0:], uint32(12+len(token)))
+	binary.BigEndian.PutUint32(req[4:], saslXid)
+	binary.BigEndian.PutUint32(req[8:], opSASL)
+	binary.BigEndian.PutUint32(req[12:], uint32(len(token)))
+	copy(req[16:], token)
+
+	if _, err := c.Conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	// the frame is the length, the reply header: xid, zxid, err, then the
+	// token buffer
+	resp, err := readFrame(c.Conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 20 {
+		return nil, errors.New("zk: invalid sasl response")
+	}
+
+	if code := int32(binary.BigEndian.Uint32(resp[16:20])); code != 0 {
+		if code == errAuthFailed {
+			return nil, ErrSASLAuthFailed
+		}
+
+		return nil, errors.Errorf("zk: sasl error code %d", code)
+	}
+
+	if len(resp) < 24 {
+		return nil, nil
+	}
+
+	n := int32(binary.BigEndian.Uint32(resp[20:24]))
+	if n < 0 {
+		return nil, nil
+	}
+	if int(n) > len(resp)-24 {
+		return nil, errors.New("zk: invalid sasl response")
+	}
+
+	return resp[24 : 24+n], nil
+}
+
+// readFrame read a length prefixed frame, the length is kept in the frame
+func readFrame(r io.Reader) ([]byte, error) {
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+
+	n := binary.BigEndian.Uint32(head)
+	if n > 1<<24 {
+		return nil, errors.Errorf("zk: frame too large: %d", n)
+	}
+
+	frame := make([]byte, 4+n)
+	copy(frame, head)
+	if _, err := io.ReadFull(r, frame[4:]); err != nil {
+		return nil, err
+	}
+
+	return frame, nil
+}
+
+// parseDigestChallenge parse the comma separated key=value pairs, the values may be quoted
+func parseDigestChallenge(s string) map[string]string {
+	params := make(map[string]string)
+
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '=')
+		if i < 0 {
+			break
+		}
+		key := strings.TrimSpace(s[:i])
+		s = s[i+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				value, s = s[1:], ""
+			} else {
+				value, s = s[1:end+1], s[end+2:]
+			}
+		} else if end := strings.IndexByte(s, ','); end >= 0 {
+			value, s = s[:end], s[end:]
+		} else {
+			value, s = s, ""
+		}
+
+		params[key] = value
+		s = strings.TrimPrefix(strings.TrimSpace(s), ",")
+	}
+
+	return params
+}
+
+func digestResponse(user, realm, password, nonce, cnonce, nc, qop, a2 string) string {
+	h := md5.Sum([]byte(user + ":" + realm + ":" + password))
+	a1 := string(h[:]) + ":" + nonce + ":" + cnonce
+
+	ha1 := md5.Sum([]byte(a1))
+	ha2 := md5.Sum([]byte(a2))
+	kd := md5.Sum([]byte(hex.EncodeToString(ha1[:]) + ":" + nonce + ":" + nc + ":" + cnonce + ":" + qop + ":" + hex.EncodeToString(ha2[:])))
+
+	return hex.EncodeToString(kd[:])
+}
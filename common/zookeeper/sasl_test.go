@@ -0,0 +1,142 @@
+package zookeeper
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+// saslReply build the sasl reply frame of the server: length, xid, zxid, err,
+// then the token buffer if err is 0
+func saslReply(code int32, token []byte) []byte {
+	body := make([]byte, 16)
+	binary.BigEndian.PutUint32(body[0:], saslXid)
+	binary.BigEndian.PutUint64(body[4:], 0x100000042)
+	binary.BigEndian.PutUint32(body[12:], uint32(code))
+	if code == 0 {
+		n := make([]byte, 4)
+		binary.BigEndian.PutUint32(n, uint32(len(token)))
+		body = append(append(body, n...), token...)
+	}
+
+	frame := make([]byte, 4)
+	binary.BigEndian.PutUint32(frame, uint32(len(body)))
+
+	return append(frame, body...)
+}
+
+// readSASLRequest read the sasl request of the client and return its token
+func readSASLRequest(t *testing.T, r io.Reader) []byte {
+	t.Helper()
+
+	frame, err := readFrame(r)
+	if err != nil {
+		t.Errorf("read the sasl request: %v", err)
+		return nil
+	}
+	if len(frame) < 16 {
+		t.Errorf("invalid sasl request: %x", frame)
+		return nil
+	}
+	if op := binary.BigEndian.Uint32(frame[8:12]); op != opSASL {
+		t.Errorf("opcode = %d, want %d", op, opSASL)
+	}
+
+	n := binary.BigEndian.Uint32(frame[12:16])
+	return frame[16 : 16+n]
+}
+
+func TestSASLExchange(t *testing.T) {
+	challenge := []byte(`realm="zk-sasl-md5",nonce="OA6MG9tEQGm2hh",qop="auth",charset=utf-8,algorithm=md5-sess`)
+
+	tests := []struct {
+		name    string
+		reply   []byte
+		want    string
+		wantErr bool
+		errIs   error
+	}{
+		{name: "challenge", reply: saslReply(0, challenge), want: string(challenge)},
+		{name: "empty token", reply: saslReply(0, nil), want: ""},
+		{name: "auth failed", reply: saslReply(errAuthFailed, nil), wantErr: true, errIs: ErrSASLAuthFailed},
+		{name: "other error", reply: saslReply(-4, nil), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			defer server.Close()
+
+			go func() {
+				if token := readSASLRequest(t, server); string(token) != "token" {
+					t.Errorf("request token = %q, want %q", token, "token")
+				}
+				_, _ = server.Write(tt.reply)
+			}()
+
+			c := &saslConn{Conn: client}
+			got, err := c.exchange([]byte("token"))
+
+			if (err != nil) != tt.wantErr || (tt.errIs != nil && err != tt.errIs) {
+				t.Fatalf("exchange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("exchange() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSASLAuthenticate(t *testing.T) {
+	const user, password, nonce = "app", "secret", "OA6MG9tEQGm2hh"
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		readSASLRequest(t, server)
+		_, _ = server.Write(saslReply(0, []byte(`realm="zk-sasl-md5",nonce="`+nonce+`",qop="auth"`)))
+
+		params := parseDigestChallenge(string(readSASLRequest(t, server)))
+		if params["username"] != user || params["nonce"] != nonce || params["digest-uri"] != saslDigestURI {
+			t.Errorf("unexpected digest response: %v", params)
+		}
+
+		want := digestResponse(user, saslDigestRealm, password, nonce, params["cnonce"], params["nc"], "auth", "AUTHENTICATE:"+saslDigestURI)
+		if params["response"] != want {
+			_, _ = server.Write(saslReply(errAuthFailed, nil))
+			return
+		}
+
+		rspauth := digestResponse(user, saslDigestRealm, password, nonce, params["cnonce"], params["nc"], "auth", ":"+saslDigestURI)
+		_, _ = server.Write(saslReply(0, []byte("rspauth="+rspauth)))
+	}()
+
+	c := &saslConn{Conn: client, user: user, password: password}
+	if err := c.authenticate(); err != nil {
+		t.Fatalf("authenticate() error = %v", err)
+	}
+}
+
+func TestParseDigestChallenge(t *testing.T) {
+	got := parseDigestChallenge(`realm="zk-sasl-md5",nonce="a,b=c", qop="auth",charset=utf-8,algorithm=md5-sess`)
+	want := map[string]string{
+		"realm":     "zk-sasl-md5",
+		"nonce":     "a,b=c",
+		"qop":       "auth",
+		"charset":   "utf-8",
+		"algorithm": "md5-sess",
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("parseDigestChallenge() = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("parseDigestChallenge()[%s] = %q, want %q", k, got[k], v)
+		}
+	}
+}
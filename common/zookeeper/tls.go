@@ -0,0 +1,46 @@
+package zookeeper
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// NewTLSConfig new the client TLS config, the client certificate is used if
+// certFile and keyFile are not empty, the system CAs are used if caFile is empty.
+// The server name is verified against the certificate of the server, it is
+// the host of the server address if empty.
+func NewTLSConfig(certFile, keyFile, caFile, serverName string, insecure bool) (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: insecure,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "load client certificate")
+		}
+
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "read CA bundle")
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificate in CA bundle %s", caFile)
+		}
+
+		cfg.RootCAs = pool
+	}
+
+	return cfg, nil
+}
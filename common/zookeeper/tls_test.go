@@ -0,0 +1,98 @@
+package zookeeper
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert write a self-signed certificate and its key to dir
+func writeCert(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir)
+
+	badCA := filepath.Join(dir, "bad.pem")
+	if err := os.WriteFile(badCA, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := NewTLSConfig(certFile, keyFile, certFile, "zk1", true)
+	if err != nil {
+		t.Fatalf("NewTLSConfig() error = %v", err)
+	}
+	if len(cfg.Certificates) != 1 || cfg.RootCAs == nil {
+		t.Errorf("NewTLSConfig() certificates = %d, RootCAs = %v", len(cfg.Certificates), cfg.RootCAs)
+	}
+	if cfg.ServerName != "zk1" || !cfg.InsecureSkipVerify || cfg.MinVersion != tls.VersionTLS12 {
+		t.Errorf("NewTLSConfig() = %+v", cfg)
+	}
+
+	cfg, err = NewTLSConfig("", "", "", "", false)
+	if err != nil {
+		t.Fatalf("NewTLSConfig() error = %v", err)
+	}
+	if len(cfg.Certificates) != 0 || cfg.RootCAs != nil {
+		t.Errorf("NewTLSConfig() without files = %+v", cfg)
+	}
+
+	errCases := []struct {
+		name          string
+		cert, key, ca string
+	}{
+		{"missing key", certFile, "", ""},
+		{"missing cert file", filepath.Join(dir, "none.pem"), keyFile, ""},
+		{"missing CA file", "", "", filepath.Join(dir, "none.pem")},
+		{"invalid CA", "", "", badCA},
+	}
+	for _, tt := range errCases {
+		if _, err := NewTLSConfig(tt.cert, tt.key, tt.ca, "", false); err == nil {
+			t.Errorf("%s: NewTLSConfig() error = nil, want an error", tt.name)
+		}
+	}
+}
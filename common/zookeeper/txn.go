@@ -0,0 +1,295 @@
+package zookeeper
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/go-zookeeper/zk"
+	"github.com/pkg/errors"
+)
+
+const (
+	TxnCreate = "create"
+	TxnSet    = "set"
+	TxnDelete = "delete"
+	TxnCheck  = "check"
+)
+
+const (
+	TxnStatusOK         = "ok"
+	TxnStatusFailed     = "failed"
+	TxnStatusRolledBack = "rolled back"
+	TxnStatusSkipped    = "skipped"
+)
+
+// TxnOp is an operation of the transaction
+type TxnOp struct {
+	Op           string `json:"op" yaml:"op"`
+	Path         string `json:"path" yaml:"path"`
+	Data         string `json:"data,omitempty" yaml:"data,omitempty"`
+	DataEncoding string `json:"dataEncoding,omitempty" yaml:"dataEncoding,omitempty"`
+	// Version is the expected data version of set, delete and check, nil is any version
+	Version *int32 `json:"version,omitempty" yaml:"version,omitempty"`
+	// ACL is the ACL of create, like: world:anyone:cdrwa. (default world:anyone:cdrwa)
+	ACL        string `json:"acl,omitempty" yaml:"acl,omitempty"`
+	Ephemeral  bool   `json:"ephemeral,omitempty" yaml:"ephemeral,omitempty"`
+	Sequential bool   `json:"sequential,omitempty" yaml:"sequential,omitempty"`
+}
+
+// TxnResult is the result of an operation of the transaction
+type TxnResult struct {
+	Op     string
+	Path   string
+	Status string
+	// CreatedPath is the path of the created znode, it differs from Path if sequential
+	CreatedPath string
+	Stat        *zk.Stat
+	Err         error
+}
+
+func (o *TxnOp) version() int32 {
+	if o.Version == nil {
+		return -1
+	}
+
+	return *o.Version
+}
+
+// request convert the operation to the multi request
+func (o *TxnOp) request() (interface{}, error) {
+	if err := ValidatePath(o.Path, o.Op == TxnCreate && o.Sequential); err != nil {
+		return nil, err
+	}
+
+	data, err := DecodeData(o.Data, o.DataEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	switch o.Op {
+	case TxnCreate:
+		acls := zk.WorldACL(zk.PermAll)
+		if o.ACL != "" {
+			if acls, err = ParseACL(o.ACL); err != nil {
+				return nil, err
+			}
+		}
+
+		var flags int32
+		if o.Ephemeral {
+			flags |= zk.FlagEphemeral
+		}
+		if o.Sequential {
+			flags |= zk.FlagSequence
+		}
+
+		return &zk.CreateRequest{Path: o.Path, Data: data, Acl: acls, Flags: flags}, nil
+	case TxnSet:
+		return &zk.SetDataRequest{Path: o.Path, Data: data, Version: o.version()}, nil
+	case TxnDelete:
+		return &zk.DeleteRequest{Path: o.Path, Version: o.version()}, nil
+	case TxnCheck:
+		if o.Version == nil {
+			return nil, errors.New("version is required by check")
+		}
+
+		return &zk.CheckVersionRequest{Path: o.Path, Version: o.version()}, nil
+	}
+
+	return nil, fmt.Errorf("unknown operation: %s, must be one of: %s, %s, %s, %s",
+		o.Op, TxnCreate, TxnSet, TxnDelete, TxnCheck)
+}
+
+// Txn execute the operations in one multi request, all of them succeed or none
+// of them. The results are returned even if the transaction failed, the error
+// is the error of the operation which caused the rollback.
+func (c *Client) Txn(ops []TxnOp) ([]TxnResult, error) {
+	reqs := make([]interface{}, len(ops))
+	for i := range ops {
+		req, err := ops[i].request()
+		if err != nil {
+			return nil, errors.Wrapf(err, "op %d %s %s", i+1, ops[i].Op, ops[i].Path)
+		}
+
+		reqs[i] = req
+	}
+
+	resps, err := c.Multi(reqs...)
+	if len(resps) != len(ops) {
+		if err == nil {
+			err = zk.ErrAPIError
+		}
+
+		return nil, err
+	}
+
+	results := make([]TxnResult, len(ops))
+	failed := -1
+	for i, resp := range resps {
+		results[i] = TxnResult{Op: ops[i].Op, Path: ops[i].Path, Status: TxnStatusOK}
+
+		if failed < 0 && resp.Error != nil {
+			failed = i
+			results[i].Status, results[i].Err = TxnStatusFailed, resp.Error
+			continue
+		}
+
+		if ops[i].Op == TxnCreate {
+			results[i].CreatedPath = resp.String
+		}
+		results[i].Stat = resp.Stat
+	}
+
+	if failed < 0 {
+		return results, nil
+	}
+
+	// the operations before the failed one are rolled back, and the operations
+	// after it are not executed
+	for i := range results {
+		switch {
+		case i < failed:
+			results[i].Status = TxnStatusRolledBack
+		case i > failed:
+			results[i].Status = TxnStatusSkipped
+		default:
+			continue
+		}
+
+		results[i].CreatedPath, results[i].Stat = "", nil
+	}
+
+	return results, errors.Wrapf(results[failed].Err, "op %d %s %s", failed+1, ops[failed].Op, ops[failed].Path)
+}
+
+// txnNode is the simulated state of a znode in the dry run
+type txnNode struct {
+	exist       bool
+	version     int32
+	numChildren int32
+	ephemeral   bool
+}
+
+// DryRunTxn validate the existence and versions of the operations against the
+// current znodes without committing, the ACL and data size are not validated.
+func (c *Client) DryRunTxn(ops []TxnOp) ([]TxnResult, error) {
+	nodes := make(map[string]*txnNode)
+	lookup := func(path string) (*txnNode, error) {
+		if n, ok := nodes[path]; ok {
+			return n, nil
+		}
+
+		exist, stat, err := c.Exists(path)
+		if err != nil {
+			return nil, err
+		}
+
+		n := &txnNode{exist: exist}
+		if exist {
+			n.version, n.numChildren, n.ephemeral = stat.Version, stat.NumChildren, stat.EphemeralOwner != 0
+		}
+		nodes[path] = n
+
+		return n, nil
+	}
+
+	results := make([]TxnResult, len(ops))
+	for i := range ops {
+		results[i] = TxnResult{Op: ops[i].Op, Path: ops[i].Path, Status: TxnStatusSkipped}
+	}
+
+	for i, o := range ops {
+		if _, err := o.request(); err != nil {
+			return nil, errors.Wrapf(err, "op %d %s %s", i+1, o.Op, o.Path)
+		}
+
+		failure, err := dryRunTxnOp(o, lookup)
+		if err != nil {
+			return nil, err
+		}
+
+		if failure == nil {
+			results[i].Status = TxnStatusOK
+			continue
+		}
+
+		results[i].Status, results[i].Err = TxnStatusFailed, failure
+		for j := 0; j < i; j++ {
+			results[j].Status = TxnStatusRolledBack
+		}
+
+		return results, errors.Wrapf(failure, "op %d %s %s", i+1, o.Op, o.Path)
+	}
+
+	return results, nil
+}
+
+// dryRunTxnOp apply the operation to the simulated znodes, return the failure
+// of the operation, or the error of getting the current znodes
+func dryRunTxnOp(o TxnOp, lookup func(path string) (*txnNode, error)) (error, error) {
+	if o.Op == TxnCreate {
+		parent, err := lookup(filepath.Dir(o.Path))
+		if err != nil {
+			return nil, err
+		}
+
+		if !parent.exist {
+			return zk.ErrNoNode, nil
+		}
+		if parent.ephemeral {
+			return zk.ErrNoChildrenForEphemerals, nil
+		}
+
+		// the sequential znode is always new
+		if o.Sequential {
+			parent.numChildren++
+			return nil, nil
+		}
+
+		n, err := lookup(o.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		if n.exist {
+			return zk.ErrNodeExists, nil
+		}
+
+		parent.numChildren++
+		*n = txnNode{exist: true, ephemeral: o.Ephemeral}
+
+		return nil, nil
+	}
+
+	n, err := lookup(o.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !n.exist {
+		return zk.ErrNoNode, nil
+	}
+
+	if o.version() != -1 && o.version() != n.version {
+		return zk.ErrBadVersion, nil
+	}
+
+	switch o.Op {
+	case TxnSet:
+		n.version++
+	case TxnDelete:
+		if n.numChildren > 0 {
+			return zk.ErrNotEmpty, nil
+		}
+
+		parent, err := lookup(filepath.Dir(o.Path))
+		if err != nil {
+			return nil, err
+		}
+
+		parent.numChildren--
+		*n = txnNode{}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,66 @@
+package zookeeper
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// Usage is the total data length and number of znodes of a subtree
+type Usage struct {
+	Path       string `json:"path" yaml:"path"`
+	Depth      int    `json:"depth" yaml:"depth"`
+	Bytes      int64  `json:"bytes" yaml:"bytes"`
+	Znodes     int64  `json:"znodes" yaml:"znodes"`
+	Ephemerals int64  `json:"ephemerals" yaml:"ephemerals"`
+}
+
+// Usage walk the root and its descendants, and summarize the subtrees of the
+// root and its descendants until depth, the root is 0 and its children are 1.
+// The usages are sorted by bytes descending, the opts.MaxDepth is ignored.
+func (c *Client) Usage(root string, opts WalkOptions, depth int) ([]*Usage, error) {
+	opts.MaxDepth, opts.Unordered = 0, true
+
+	usages := make(map[string]*Usage)
+	err := c.Walk(root, opts, func(n *WalkNode) error {
+		// add to the subtree of the znode and its summarized ancestors
+		p := n.Path
+		for d := n.Depth; d >= 0; d-- {
+			if d <= depth {
+				u, ok := usages[p]
+				if !ok {
+					u = &Usage{Path: p, Depth: d}
+					usages[p] = u
+				}
+
+				u.Bytes += int64(n.Stat.DataLength)
+				u.Znodes++
+				if n.Stat.EphemeralOwner != 0 {
+					u.Ephemerals++
+				}
+			}
+
+			p = path.Dir(p)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*Usage, 0, len(usages))
+	for _, u := range usages {
+		res = append(res, u)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Bytes != res[j].Bytes {
+			return res[i].Bytes > res[j].Bytes
+		}
+
+		return strings.Compare(res[i].Path, res[j].Path) < 0
+	})
+
+	return res, nil
+}
@@ -1,13 +1,42 @@
 package zookeeper
 
 import (
+	"crypto/sha1"
+	"encoding/base64"
 	"fmt"
+	"net"
 	"strings"
 	"unicode/utf8"
 
 	"github.com/go-zookeeper/zk"
+	"github.com/pkg/errors"
 )
 
+const (
+	DataEncodingUTF8   = "utf8"
+	DataEncodingBase64 = "base64"
+)
+
+// ACL schemes of ZooKeeper
+const (
+	SchemeWorld  = "world"
+	SchemeAuth   = "auth"
+	SchemeDigest = "digest"
+	SchemeIP     = "ip"
+	SchemeX509   = "x509"
+	SchemeSASL   = "sasl"
+
+	// SchemeDigestPlain is the digest ACL with the plain password, it is
+	// hashed by ParseACL, like: digest-plain:user:password:cdrwa
+	SchemeDigestPlain = "digest-plain"
+)
+
+// ACLSchemes are the ACL schemes supported by ZooKeeper
+var ACLSchemes = []string{SchemeWorld, SchemeAuth, SchemeDigest, SchemeIP, SchemeX509, SchemeSASL}
+
+// aclInputSchemes are the schemes accepted by ParseACL
+var aclInputSchemes = append([]string{SchemeDigestPlain}, ACLSchemes...)
+
 // ValidatePath will make sure a path is valid before sending the request
 func ValidatePath(path string, isSequential bool) error {
 	if path == "" {
@@ -69,12 +98,24 @@ func ValidatePath(path string, isSequential bool) error {
 	return nil
 }
 
-// ParseACL parse acl string to []zk.ACL
+// ParseACL parse acl string to []zk.ACL, multiple ACL with a comma, like:
+//
+//	world:anyone:cdrwa
+//	auth::cdrwa
+//	digest:user:base64(sha1(user:password)):cdrwa
+//	digest-plain:user:password:cdrwa
+//	ip:10.0.0.0/8:r
+//	x509:CN=client,OU=zk,O=example:cdrwa
+//	sasl:user@EXAMPLE.COM:cdrwa
+//
+// The id of x509 is a DN which contains commas, so the ACL is only split at
+// the comma followed by a scheme. The password of digest-plain is hashed to
+// the digest id, the unknown schemes, the unknown or empty perms and the
+// invalid ids of world, digest and ip are rejected.
 func ParseACL(acl string) ([]zk.ACL, error) {
 	acls := make([]zk.ACL, 0)
 
-	aclstr := strings.Split(acl, ",")
-	for _, a := range aclstr {
+	for _, a := range splitACLs(acl) {
 		acl, err := parseACL(a)
 		if err != nil {
 			return nil, err
@@ -86,45 +127,149 @@ func ParseACL(acl string) ([]zk.ACL, error) {
 	return acls, nil
 }
 
+// splitACLs split the ACL string at the commas followed by a scheme
+func splitACLs(acl string) []string {
+	acls := make([]string, 0)
+	for _, s := range strings.Split(acl, ",") {
+		if len(acls) > 0 && !hasACLScheme(s) {
+			acls[len(acls)-1] += "," + s
+			continue
+		}
+
+		acls = append(acls, s)
+	}
+
+	return acls
+}
+
+func hasACLScheme(acl string) bool {
+	for _, scheme := range aclInputSchemes {
+		if strings.HasPrefix(acl, scheme+":") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseACL parse the ACL like scheme:id:perms, the id is between the first and
+// the last colon, it may contain colons, like: digest and ipv6 id
 func parseACL(acl string) (zk.ACL, error) {
-	as := strings.Split(acl, ":")
-	if len(as) < 3 || len(as) > 4 {
-		return zk.ACL{}, zk.ErrInvalidACL
+	i, j := strings.Index(acl, ":"), strings.LastIndex(acl, ":")
+	if i <= 0 || i == j {
+		return zk.ACL{}, errors.Errorf("invalid ACL %s: must be scheme:id:perms", acl)
+	}
+
+	perms, err := ParsePerms(acl[j+1:])
+	if err != nil {
+		return zk.ACL{}, errors.Wrapf(err, "invalid ACL %s", acl)
+	}
+	if perms == 0 {
+		return zk.ACL{}, errors.Errorf("invalid ACL %s: no permission, nobody can access the znode", acl)
 	}
 
-	var id string
-	if len(as) == 3 {
-		id = as[1]
+	a := zk.ACL{
+		Perms:  perms,
+		Scheme: acl[:i],
+		ID:     acl[i+1 : j],
 	}
-	if len(as) == 4 {
-		id = as[1] + ":" + as[2]
+
+	if a.Scheme == SchemeDigestPlain {
+		user, password, ok := strings.Cut(a.ID, ":")
+		if !ok || user == "" {
+			return zk.ACL{}, errors.Errorf("invalid ACL %s: must be digest-plain:user:password:perms", acl)
+		}
+
+		a.Scheme, a.ID = SchemeDigest, DigestID(user, password)
+	}
+
+	if err := validateACLID(a.Scheme, a.ID); err != nil {
+		return zk.ACL{}, errors.Wrapf(err, "invalid ACL %s", acl)
+	}
+
+	return a, nil
+}
+
+// validateACLID validate the id of the scheme like the authentication
+// providers of the server
+func validateACLID(scheme, id string) error {
+	switch scheme {
+	case SchemeWorld:
+		if id != "anyone" {
+			return errors.New("the id of world must be anyone")
+		}
+	case SchemeDigest:
+		user, hash, ok := strings.Cut(id, ":")
+		if b, err := base64.StdEncoding.DecodeString(hash); !ok || user == "" || err != nil || len(b) != sha1.Size {
+			return errors.New("the id of digest must be user:base64(sha1(user:password)), use digest-plain:user:password:perms for the plain password")
+		}
+	case SchemeIP:
+		if net.ParseIP(id) == nil {
+			if _, _, err := net.ParseCIDR(id); err != nil {
+				return errors.New("the id of ip must be an IP address or CIDR")
+			}
+		}
+	case SchemeAuth, SchemeX509, SchemeSASL:
+	default:
+		return errors.Errorf("unknown scheme %s, must be one of: %s", scheme, strings.Join(aclInputSchemes, ", "))
 	}
 
-	var perms int32
-	bs := []byte(as[len(as)-1])
+	return nil
+}
+
+// ParsePerms parse perms string to ACL perms, like: cdrwa
+func ParsePerms(perms string) (int32, error) {
+	var p int32
+	bs := []byte(perms)
 	for _, b := range bs {
 		switch b {
 		case 'c':
-			perms += zk.PermCreate
+			p |= zk.PermCreate
 		case 'd':
-			perms += zk.PermDelete
+			p |= zk.PermDelete
 		case 'r':
-			perms += zk.PermRead
+			p |= zk.PermRead
 		case 'w':
-			perms += zk.PermWrite
+			p |= zk.PermWrite
 		case 'a':
-			perms += zk.PermAdmin
+			p |= zk.PermAdmin
+		default:
+			return 0, errors.Errorf("unknown permission %q, must be the letters of crdwa", b)
 		}
 	}
 
-	return zk.ACL{
-		Perms:  perms,
-		Scheme: as[0],
-		ID:     id,
-	}, nil
+	return p, nil
 }
 
-// FormatACLs format ACL to string
+// ExpandAuthACL replace the auth ACL by the digest ACL of the credentials
+// like user:password, as the server does for the digest auth of the session.
+// The ACL is not changed if there is no credential.
+func ExpandAuthACL(acls []zk.ACL, credentials []string) ([]zk.ACL, error) {
+	if len(credentials) == 0 {
+		return acls, nil
+	}
+
+	res := make([]zk.ACL, 0, len(acls))
+	for _, a := range acls {
+		if a.Scheme != SchemeAuth {
+			res = append(res, a)
+			continue
+		}
+
+		for _, c := range credentials {
+			user, password, ok := strings.Cut(c, ":")
+			if !ok {
+				return nil, errors.Errorf("invalid digest credential %s, must be user:password", c)
+			}
+
+			res = append(res, zk.ACL{Perms: a.Perms, Scheme: SchemeDigest, ID: DigestID(user, password)})
+		}
+	}
+
+	return res, nil
+}
+
+// FormatACLs format ACL to string, it can be parsed by ParseACL
 func FormatACLs(acls []zk.ACL) string {
 	if len(acls) == 0 {
 		return ""
@@ -132,14 +277,15 @@ func FormatACLs(acls []zk.ACL) string {
 
 	aclstrs := make([]string, len(acls))
 	for i, a := range acls {
-		ps := permsToString(a.Perms)
+		ps := FormatPerms(a.Perms)
 		aclstrs[i] = a.Scheme + ":" + a.ID + ":" + ps
 	}
 
 	return strings.Join(aclstrs, ",")
 }
 
-func permsToString(perms int32) string {
+// FormatPerms format ACL perms to string, like: adcwr
+func FormatPerms(perms int32) string {
 	if perms == 0 {
 		return ""
 	}
@@ -174,3 +320,32 @@ func permsToString(perms int32) string {
 
 	return permstr
 }
+
+// DigestID return the id of the digest ACL of the user, like the server
+// DigestAuthenticationProvider: user:base64(sha1(user:password))
+func DigestID(user, password string) string {
+	sum := sha1.Sum([]byte(user + ":" + password))
+
+	return user + ":" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// EncodeData encode data to string, base64 is used if data is not valid UTF-8
+func EncodeData(data []byte) (string, string) {
+	if !utf8.Valid(data) {
+		return base64.StdEncoding.EncodeToString(data), DataEncodingBase64
+	}
+
+	return string(data), DataEncodingUTF8
+}
+
+// DecodeData decode the string encoded by EncodeData
+func DecodeData(data, encoding string) ([]byte, error) {
+	switch encoding {
+	case "", DataEncodingUTF8:
+		return []byte(data), nil
+	case DataEncodingBase64:
+		return base64.StdEncoding.DecodeString(data)
+	}
+
+	return nil, fmt.Errorf("unknown data encoding: %s", encoding)
+}
@@ -0,0 +1,127 @@
+package zookeeper
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-zookeeper/zk"
+)
+
+func TestSplitACLs(t *testing.T) {
+	tests := []struct {
+		acl  string
+		want []string
+	}{
+		{"world:anyone:cdrwa", []string{"world:anyone:cdrwa"}},
+		{"world:anyone:r,ip:10.0.0.0/8:cdrwa", []string{"world:anyone:r", "ip:10.0.0.0/8:cdrwa"}},
+		{"x509:CN=client,OU=zk,O=example:cdrwa", []string{"x509:CN=client,OU=zk,O=example:cdrwa"}},
+		{
+			"x509:CN=client,OU=zk:cdrwa,sasl:admin:cdrwa,digest-plain:app:secret:r",
+			[]string{"x509:CN=client,OU=zk:cdrwa", "sasl:admin:cdrwa", "digest-plain:app:secret:r"},
+		},
+		{"auth::cdrwa", []string{"auth::cdrwa"}},
+		{"", []string{""}},
+	}
+
+	for _, tt := range tests {
+		if got := splitACLs(tt.acl); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitACLs(%q) = %q, want %q", tt.acl, got, tt.want)
+		}
+	}
+}
+
+func TestDigestID(t *testing.T) {
+	tests := []struct {
+		user, password string
+		want           string
+	}{
+		{"user", "password", "user:tpUq/4Pn5A64fVZyQ0gOJ8ZWqkY="},
+		{"super", "admin", "super:xQJmxLMiHGwaqBvst5y6rkB6HQs="},
+	}
+
+	for _, tt := range tests {
+		if got := DigestID(tt.user, tt.password); got != tt.want {
+			t.Errorf("DigestID(%q, %q) = %s, want %s", tt.user, tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestParseACL(t *testing.T) {
+	tests := []struct {
+		name    string
+		acl     string
+		want    []zk.ACL
+		wantErr bool
+	}{
+		{
+			name: "world",
+			acl:  "world:anyone:cdrwa",
+			want: []zk.ACL{{Perms: zk.PermAll, Scheme: "world", ID: "anyone"}},
+		},
+		{
+			name: "multiple",
+			acl:  "auth::cdrwa,ip:10.0.0.1:r",
+			want: []zk.ACL{{Perms: zk.PermAll, Scheme: "auth"}, {Perms: zk.PermRead, Scheme: "ip", ID: "10.0.0.1"}},
+		},
+		{
+			name: "digest",
+			acl:  "digest:user:tpUq/4Pn5A64fVZyQ0gOJ8ZWqkY=:rw",
+			want: []zk.ACL{{Perms: zk.PermRead | zk.PermWrite, Scheme: "digest", ID: "user:tpUq/4Pn5A64fVZyQ0gOJ8ZWqkY="}},
+		},
+		{
+			name: "digest-plain is hashed",
+			acl:  "digest-plain:user:password:rw",
+			want: []zk.ACL{{Perms: zk.PermRead | zk.PermWrite, Scheme: "digest", ID: "user:tpUq/4Pn5A64fVZyQ0gOJ8ZWqkY="}},
+		},
+		{
+			name: "digest-plain password with colons",
+			acl:  "digest-plain:user:a:b:r",
+			want: []zk.ACL{{Perms: zk.PermRead, Scheme: "digest", ID: DigestID("user", "a:b")}},
+		},
+		{
+			name: "ip CIDR",
+			acl:  "ip:10.0.0.0/8:r",
+			want: []zk.ACL{{Perms: zk.PermRead, Scheme: "ip", ID: "10.0.0.0/8"}},
+		},
+		{
+			name: "ipv6",
+			acl:  "ip:fe80::1:r",
+			want: []zk.ACL{{Perms: zk.PermRead, Scheme: "ip", ID: "fe80::1"}},
+		},
+		{
+			name: "x509 DN with commas",
+			acl:  "x509:CN=client,OU=zk:cdrwa,sasl:admin@EXAMPLE.COM:a",
+			want: []zk.ACL{
+				{Perms: zk.PermAll, Scheme: "x509", ID: "CN=client,OU=zk"},
+				{Perms: zk.PermAdmin, Scheme: "sasl", ID: "admin@EXAMPLE.COM"},
+			},
+		},
+		{name: "unknown scheme", acl: "foo:bar:r", wantErr: true},
+		{name: "unknown perm", acl: "world:anyone:rx", wantErr: true},
+		{name: "upper case perm", acl: "world:anyone:R", wantErr: true},
+		{name: "empty perms", acl: "world:anyone:", wantErr: true},
+		{name: "empty perms of multiple", acl: "world:anyone:r,auth::", wantErr: true},
+		{name: "missing perms", acl: "world:anyone", wantErr: true},
+		{name: "empty", acl: "", wantErr: true},
+		{name: "world id", acl: "world:everyone:r", wantErr: true},
+		{name: "digest not hashed", acl: "digest:user:password:r", wantErr: true},
+		{name: "digest without user", acl: "digest::tpUq/4Pn5A64fVZyQ0gOJ8ZWqkY=:r", wantErr: true},
+		{name: "digest-plain without password", acl: "digest-plain:user:r", wantErr: true},
+		{name: "digest-plain without user", acl: "digest-plain::password:r", wantErr: true},
+		{name: "invalid ip", acl: "ip:10.0.0.256:r", wantErr: true},
+		{name: "invalid CIDR", acl: "ip:10.0.0.0/33:r", wantErr: true},
+		{name: "hostname", acl: "ip:zk1.example.com:r", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseACL(tt.acl)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseACL(%q) error = %v, wantErr %v", tt.acl, err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseACL(%q) = %v, want %v", tt.acl, got, tt.want)
+			}
+		})
+	}
+}
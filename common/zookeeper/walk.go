@@ -0,0 +1,335 @@
+package zookeeper
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-zookeeper/zk"
+	"github.com/pkg/errors"
+)
+
+// DefaultWalkConcurrency is the default number of outstanding requests of the walk
+const DefaultWalkConcurrency = 16
+
+// WalkOptions control how a tree is walked
+type WalkOptions struct {
+	// Concurrency is the number of outstanding requests. (default 16)
+	Concurrency int
+	// MaxDepth is the max depth to walk, the root is 0 and its children are 1.
+	// (default 0, no limit)
+	MaxDepth int
+	// Include only report the znodes matched by one of the patterns, the
+	// descendants of the unmatched znodes are still walked
+	Include []string
+	// Exclude skip the znodes matched by one of the patterns and their descendants
+	Exclude []string
+	// Data fetch the data of the znodes
+	Data bool
+	// ACL fetch the ACL of the znodes
+	ACL bool
+	// Unordered report the znodes in the order of fetched instead of pre-order,
+	// it uses less memory
+	Unordered bool
+	// NoAuth report the znodes not authorized to read with NoAuth instead of
+	// failing the walk, their descendants are not walked
+	NoAuth bool
+}
+
+// WalkNode is a znode of the walk, Children is nil if the depth is MaxDepth
+type WalkNode struct {
+	Path     string
+	Depth    int
+	Stat     *zk.Stat
+	Children []string
+	Data     []byte
+	ACL      []zk.ACL
+	// NoAuth is true if the children, data or ACL are not authorized to read,
+	// the ACL is nil if it is not authorized either
+	NoAuth bool
+}
+
+// WalkFunc is called for every reported znode, the calls are serialized. The
+// walk is stopped if it returns error.
+type WalkFunc func(n *WalkNode) error
+
+// MatchPattern report whether the path matches the glob pattern, the pattern
+// starting with "/" matches the whole path, otherwise it matches the znode name
+func MatchPattern(pattern, p string) bool {
+	if !strings.HasPrefix(pattern, "/") {
+		p = path.Base(p)
+	}
+
+	ok, _ := path.Match(pattern, p)
+	return ok
+}
+
+func matchAny(patterns []string, p string) bool {
+	for _, pattern := range patterns {
+		if MatchPattern(pattern, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type walkResult struct {
+	node *WalkNode
+	err  error
+}
+
+// walkFrame is a znode whose children are being reported in pre-order
+type walkFrame struct {
+	node     *WalkNode
+	children []string
+	next     int
+}
+
+type treeWalker struct {
+	c    *Client
+	opts WalkOptions
+	fn   WalkFunc
+
+	// fetched are the znodes waiting to be reported in pre-order, nil if the
+	// znode was deleted during the walk
+	fetched map[string]*WalkNode
+	stack   []*walkFrame
+}
+
+// Walk walk the root and its descendants by a pool of concurrent requests,
+// fn is called for every znode in pre-order with children in name order,
+// unless opts.Unordered. The znodes deleted during the walk are skipped.
+func (c *Client) Walk(root string, opts WalkOptions, fn WalkFunc) error {
+	if err := ValidatePath(root, false); err != nil {
+		return err
+	}
+
+	for _, pattern := range append(append([]string{}, opts.Include...), opts.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Wrapf(err, "invalid pattern %s", pattern)
+		}
+	}
+
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultWalkConcurrency
+	}
+
+	w := &treeWalker{
+		c:       c,
+		opts:    opts,
+		fn:      fn,
+		fetched: make(map[string]*WalkNode),
+	}
+
+	return w.walk(root)
+}
+
+func (w *treeWalker) walk(root string) error {
+	jobs := make(chan *WalkNode)
+	results := make(chan walkResult, w.opts.Concurrency)
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for n := range jobs {
+				results <- walkResult{n, w.fetch(n)}
+			}
+		}()
+	}
+
+	defer func() {
+		close(jobs)
+		wg.Wait()
+	}()
+
+	// the queue is LIFO so that the first subtree is walked first, and the
+	// pre-order znodes can be reported earlier
+	queue := []*WalkNode{{Path: root}}
+	inflight := 0
+
+	var walkErr error
+	for (len(queue) > 0 && walkErr == nil) || inflight > 0 {
+		var (
+			send chan *WalkNode
+			next *WalkNode
+		)
+		if len(queue) > 0 && walkErr == nil {
+			send, next = jobs, queue[len(queue)-1]
+		}
+
+		select {
+		case send <- next:
+			queue = queue[:len(queue)-1]
+			inflight++
+		case r := <-results:
+			inflight--
+			if walkErr != nil {
+				continue
+			}
+
+			// the znode of subtree has been deleted during the walk
+			deleted := r.err == zk.ErrNoNode && r.node.Depth > 0
+			if r.err != nil && !deleted {
+				walkErr = r.err
+				continue
+			}
+
+			if !deleted {
+				children := w.children(r.node)
+				for i := len(children) - 1; i >= 0; i-- {
+					queue = append(queue, &WalkNode{Path: path.Join(r.node.Path, children[i]), Depth: r.node.Depth + 1})
+				}
+			}
+
+			walkErr = w.report(r.node, deleted)
+		}
+	}
+
+	return walkErr
+}
+
+// fetch get the stat, children, data and ACL of the znode, only the stat and
+// the ACL if possible are got for the znode not authorized if opts.NoAuth
+func (w *treeWalker) fetch(n *WalkNode) error {
+	err := w.fetchNode(n)
+	if err != zk.ErrNoAuth || !w.opts.NoAuth {
+		return err
+	}
+
+	n.NoAuth, n.Children, n.Data, n.ACL = true, make([]string, 0), nil, nil
+
+	var exist bool
+	if exist, n.Stat, err = w.c.Exists(n.Path); err != nil {
+		return err
+	}
+	if !exist {
+		return zk.ErrNoNode
+	}
+
+	if w.opts.ACL {
+		if n.ACL, _, err = w.c.GetACL(n.Path); err != nil && err != zk.ErrNoAuth {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *treeWalker) fetchNode(n *WalkNode) error {
+	var err error
+
+	listChildren := w.opts.MaxDepth <= 0 || n.Depth < w.opts.MaxDepth
+	if w.opts.Data {
+		if n.Data, n.Stat, err = w.c.Get(n.Path); err != nil {
+			return err
+		}
+
+		// the leaf has no children to list
+		if listChildren && n.Stat.NumChildren == 0 {
+			n.Children, listChildren = make([]string, 0), false
+		}
+	}
+
+	switch {
+	case listChildren:
+		if n.Children, n.Stat, err = w.c.Children(n.Path); err != nil {
+			return err
+		}
+		sort.Strings(n.Children)
+	case n.Stat == nil:
+		var exist bool
+		if exist, n.Stat, err = w.c.Exists(n.Path); err != nil {
+			return err
+		}
+		if !exist {
+			return zk.ErrNoNode
+		}
+	}
+
+	if w.opts.ACL {
+		if n.ACL, _, err = w.c.GetACL(n.Path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// children return the children names of the znode to walk
+func (w *treeWalker) children(n *WalkNode) []string {
+	children := make([]string, 0, len(n.Children))
+	for _, child := range n.Children {
+		if !matchAny(w.opts.Exclude, path.Join(n.Path, child)) {
+			children = append(children, child)
+		}
+	}
+
+	return children
+}
+
+// report call fn for the fetched znode, the znodes are reported in pre-order
+// once all the znodes before them are reported
+func (w *treeWalker) report(n *WalkNode, deleted bool) error {
+	if w.opts.Unordered {
+		if deleted {
+			return nil
+		}
+
+		return w.call(n)
+	}
+
+	switch {
+	case n.Depth == 0:
+		if err := w.call(n); err != nil {
+			return err
+		}
+
+		w.stack = append(w.stack, &walkFrame{node: n, children: w.children(n)})
+	case deleted:
+		w.fetched[n.Path] = nil
+	default:
+		w.fetched[n.Path] = n
+	}
+
+	for len(w.stack) > 0 {
+		top := w.stack[len(w.stack)-1]
+		if top.next >= len(top.children) {
+			w.stack = w.stack[:len(w.stack)-1]
+			continue
+		}
+
+		p := path.Join(top.node.Path, top.children[top.next])
+		n, ok := w.fetched[p]
+		if !ok {
+			return nil
+		}
+
+		delete(w.fetched, p)
+		top.next++
+
+		if n == nil {
+			continue
+		}
+
+		if err := w.call(n); err != nil {
+			return err
+		}
+
+		w.stack = append(w.stack, &walkFrame{node: n, children: w.children(n)})
+	}
+
+	return nil
+}
+
+func (w *treeWalker) call(n *WalkNode) error {
+	if len(w.opts.Include) > 0 && !matchAny(w.opts.Include, n.Path) {
+		return nil
+	}
+
+	return w.fn(n)
+}
@@ -0,0 +1,350 @@
+package zookeeper
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+	"github.com/pkg/errors"
+)
+
+const watchRetryInterval = time.Second
+
+// WatchEvent is a change of the watched znode or the session
+type WatchEvent struct {
+	Time     time.Time
+	Type     zk.EventType
+	State    zk.State
+	Path     string
+	Data     []byte
+	Stat     *zk.Stat
+	Children []string
+	// Missed is true if the watch was lost, the changes before the watch
+	// was re-armed may have been missed
+	Missed bool
+	Err    error
+}
+
+// WatchHandler handle the watch events, the events are handled one by one
+type WatchHandler func(ev WatchEvent)
+
+type watcher struct {
+	ctx       context.Context
+	c         *Client
+	recursive bool
+	handler   WatchHandler
+
+	handlerLock sync.Mutex
+	wg          sync.WaitGroup
+
+	nodesLock sync.Mutex
+	nodes     map[string]context.CancelFunc
+}
+
+// Watch watch the data, children and existence of the path until ctx is done,
+// the watches are re-armed after every event. If recursive, all znodes of the
+// subtree are watched by the persistent recursive watch (addWatch) of
+// ZooKeeper 3.6+, the children changes are not reported since they are
+// reported as the created and deleted znodes. The servers before 3.6 do not
+// support it, then the subtree is watched by one-time watches of every znode,
+// and the new created znodes are watched too.
+func (c *Client) Watch(ctx context.Context, path string, recursive bool, handler WatchHandler) error {
+	if err := ValidatePath(path, false); err != nil {
+		return err
+	}
+
+	w := &watcher{
+		ctx:       ctx,
+		c:         c,
+		recursive: recursive,
+		handler:   handler,
+		nodes:     make(map[string]context.CancelFunc),
+	}
+
+	events, unsubscribe := c.SessionEvents()
+	defer unsubscribe()
+
+	if recursive {
+		// the server version is known after connected
+		if err := c.WaitSession(ctx); err != nil {
+			return nil
+		}
+
+		l, remove, err := c.addPersistentWatch(path)
+		switch err {
+		case nil:
+			defer remove()
+			w.watchPersistent(path, events, l)
+			return nil
+		case ErrPersistentWatchUnsupported:
+		default:
+			return err
+		}
+	}
+
+	w.watchNode(path, true, true)
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.wg.Wait()
+			return nil
+		case ev := <-events:
+			w.emitSession(path, ev, ev.State == zk.StateExpired)
+		}
+	}
+}
+
+// emitSession emit the session event, missed is true if the changes may
+// have been missed
+func (w *watcher) emitSession(path string, ev zk.Event, missed bool) {
+	switch ev.State {
+	case zk.StateDisconnected, zk.StateExpired, zk.StateHasSession, zk.StateAuthFailed:
+		w.emit(WatchEvent{
+			Type:   zk.EventSession,
+			State:  ev.State,
+			Path:   path,
+			Missed: missed,
+			Err:    ev.Err,
+		})
+	}
+}
+
+// watchPersistent emit the events of the persistent recursive watch of the
+// root until ctx is done. The watch is added again after reconnected, but the
+// changes while disconnected are not notified.
+func (w *watcher) watchPersistent(root string, events <-chan zk.Event, l *nodeListener) {
+	disconnected := false
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case ev := <-events:
+			switch ev.State {
+			case zk.StateDisconnected, zk.StateExpired:
+				disconnected = true
+				w.emitSession(root, ev, ev.State == zk.StateExpired)
+			case zk.StateHasSession:
+				w.emitSession(root, ev, disconnected)
+				disconnected = false
+			default:
+				w.emitSession(root, ev, false)
+			}
+		case ev := <-l.ch:
+			if l.dropped.Swap(false) {
+				w.emit(WatchEvent{Type: zk.EventNotWatching, Path: root, Missed: true, Err: errors.New("too many events")})
+			}
+			if !inSubtree(root, ev.Path) {
+				continue
+			}
+
+			switch ev.Type {
+			case zk.EventNodeCreated, zk.EventNodeDataChanged:
+				w.emitData(ev.Type, ev.Path)
+			case zk.EventNodeDeleted:
+				w.emit(WatchEvent{Type: ev.Type, Path: ev.Path})
+			}
+		}
+	}
+}
+
+func (w *watcher) emit(ev WatchEvent) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	w.handlerLock.Lock()
+	defer w.handlerLock.Unlock()
+
+	w.handler(ev)
+}
+
+// watchNode start watching data and children of the path, return false if
+// the path is already watched. The children of the path are reported as
+// created if it is not initial.
+func (w *watcher) watchNode(path string, root, initial bool) bool {
+	w.nodesLock.Lock()
+	defer w.nodesLock.Unlock()
+
+	if _, ok := w.nodes[path]; ok {
+		return false
+	}
+
+	ctx, cancel := context.WithCancel(w.ctx)
+	w.nodes[path] = cancel
+
+	w.wg.Add(2)
+	go w.watchData(ctx, path, root)
+	go w.watchChildren(ctx, path, root, initial)
+
+	return true
+}
+
+func (w *watcher) forgetNode(path string) {
+	w.nodesLock.Lock()
+	defer w.nodesLock.Unlock()
+
+	if cancel, ok := w.nodes[path]; ok {
+		cancel()
+		delete(w.nodes, path)
+	}
+}
+
+// retry wait to re-arm the watch, return false if should stop watching
+func (w *watcher) retry(ctx context.Context, path string, err error) bool {
+	switch err {
+	case zk.ErrClosing:
+		return false
+	case zk.ErrConnectionClosed, zk.ErrSessionExpired:
+		// reported by the session events
+	case zk.ErrNoAuth:
+		w.emit(WatchEvent{Type: zk.EventNotWatching, Path: path, Err: err})
+		return false
+	default:
+		w.emit(WatchEvent{Type: zk.EventNotWatching, Path: path, Missed: true, Err: err})
+	}
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(watchRetryInterval):
+		return true
+	}
+}
+
+func (w *watcher) watchData(ctx context.Context, path string, root bool) {
+	defer w.wg.Done()
+
+	for {
+		exist, _, ch, err := w.c.ExistsW(path)
+		if err != nil {
+			if !w.retry(ctx, path, err) {
+				return
+			}
+			continue
+		}
+
+		// the znode of subtree has been deleted before watching
+		if !exist && !root {
+			w.forgetNode(path)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-ch:
+			switch ev.Type {
+			case zk.EventNodeCreated, zk.EventNodeDataChanged:
+				w.emitData(ev.Type, path)
+			case zk.EventNodeDeleted:
+				w.emit(WatchEvent{Type: ev.Type, Path: path})
+				if !root {
+					w.forgetNode(path)
+					return
+				}
+			case zk.EventNotWatching:
+				if !w.retry(ctx, path, ev.Err) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (w *watcher) emitData(typ zk.EventType, path string) {
+	data, stat, err := w.c.Get(path)
+	if err == zk.ErrNoNode {
+		// deleted before get, the delete event will be emitted
+		return
+	}
+
+	w.emit(WatchEvent{Type: typ, Path: path, Data: data, Stat: stat, Err: err})
+}
+
+func (w *watcher) watchChildren(ctx context.Context, path string, root, initial bool) {
+	defer w.wg.Done()
+
+	changed := false
+	for {
+		children, stat, ch, err := w.c.ChildrenW(path)
+		if err == zk.ErrNoNode {
+			if !root {
+				return
+			}
+
+			// wait the root znode created, all children of it are new
+			if !w.waitCreated(ctx, path) {
+				return
+			}
+			initial = false
+			continue
+		}
+		if err != nil {
+			if !w.retry(ctx, path, err) {
+				return
+			}
+			continue
+		}
+
+		if changed {
+			w.emit(WatchEvent{Type: zk.EventNodeChildrenChanged, Path: path, Stat: stat, Children: children})
+		}
+
+		if w.recursive {
+			for _, child := range children {
+				p := filepath.Join(path, child)
+				if w.watchNode(p, false, initial) && !initial {
+					w.emitData(zk.EventNodeCreated, p)
+				}
+			}
+		}
+
+		initial, changed = false, false
+
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-ch:
+			switch ev.Type {
+			case zk.EventNodeChildrenChanged:
+				changed = true
+			case zk.EventNodeDeleted:
+				if !root {
+					return
+				}
+			case zk.EventNotWatching:
+				if !w.retry(ctx, path, ev.Err) {
+					return
+				}
+				// report the current children after the watch lost
+				changed = root
+			}
+		}
+	}
+}
+
+// waitCreated wait the path created, return false if ctx is done or the client is closed
+func (w *watcher) waitCreated(ctx context.Context, path string) bool {
+	for {
+		exist, _, ch, err := w.c.ExistsW(path)
+		if err != nil {
+			if !w.retry(ctx, path, err) {
+				return false
+			}
+			continue
+		}
+
+		if exist {
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ch:
+		}
+	}
+}